@@ -0,0 +1,289 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// The proto packages hold plain Go structs rather than generated messages,
+// so requests and responses are mapped to JSON by reflection, following the
+// protobuf JSON mapping grpc-gateway clients expect: lowerCamelCase names
+// (snake_case accepted on input), 64-bit integers as strings, and every
+// field present in responses. Fields that are real protobuf messages, such
+// as FieldMask and google.rpc.Status, go through protojson.
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// Codec is a grpc encoding.Codec using the same JSON mapping, for other
+// transports that carry JSON messages
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error)      { return marshalJSON(v) }
+func (Codec) Unmarshal(data []byte, v interface{}) error { return unmarshalJSON(data, v) }
+func (Codec) Name() string                               { return "json" }
+
+// marshalJSON encodes a response struct
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value) error {
+	if v.Type().Implements(protoMessageType) {
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		data, err := protojson.Marshal(v.Interface().(proto.Message))
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encodeValue(buf, v.Elem())
+	case reflect.Struct:
+		buf.WriteByte('{')
+		t := v.Type()
+		first := true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			name, _ := json.Marshal(jsonName(f.Name))
+			buf.Write(name)
+			buf.WriteByte(':')
+			if err := encodeValue(buf, v.Field(i)); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case reflect.Slice:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case reflect.Int64, reflect.Uint64:
+		buf.WriteByte('"')
+		buf.WriteString(fmt.Sprint(v.Interface()))
+		buf.WriteByte('"')
+		return nil
+	case reflect.String, reflect.Bool, reflect.Int32, reflect.Int, reflect.Uint32, reflect.Float32, reflect.Float64:
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+// unmarshalJSON decodes a request body into the struct v points to
+func unmarshalJSON(data []byte, v interface{}) error {
+	return decodeValue(bytes.TrimSpace(data), reflect.ValueOf(v).Elem())
+}
+
+func decodeValue(data []byte, v reflect.Value) error {
+	if string(data) == "null" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Type().Implements(protoMessageType) {
+		msg := reflect.New(v.Type().Elem())
+		if err := protojson.Unmarshal(data, msg.Interface().(proto.Message)); err != nil {
+			return err
+		}
+		v.Set(msg)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(data, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("expected an object")
+		}
+		for key, raw := range fields {
+			// Unknown fields are ignored, as grpc-gateway does
+			field, ok := fieldByName(v, key)
+			if !ok {
+				continue
+			}
+			if err := decodeValue(bytes.TrimSpace(raw), field); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("expected an array")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, raw := range items {
+			if err := decodeValue(bytes.TrimSpace(raw), slice.Index(i)); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.String:
+		return json.Unmarshal(data, v.Addr().Interface())
+	default:
+		// Numbers and bools may arrive quoted, as 64-bit integers always are
+		s := string(data)
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+		return setScalar(v, s)
+	}
+}
+
+// setField assigns a string value, from a path or query parameter, to the
+// field at a dotted path such as "price.currency_code". Repeated fields
+// get the value appended.
+func setField(v reflect.Value, path string, value string) error {
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr && !v.Type().Implements(protoMessageType) {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("%s: not a message", path)
+		}
+		field, ok := fieldByName(v, name)
+		if !ok {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		v = field
+	}
+
+	if v.Type().Implements(protoMessageType) {
+		// Well-known types such as FieldMask take a JSON string
+		return decodeValue([]byte(strconv.Quote(value)), v)
+	}
+	if v.Kind() == reflect.Slice {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := setScalar(elem, value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.Set(reflect.Append(v, elem))
+		return nil
+	}
+	if err := setScalar(v, value); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// setScalar parses s into a string, bool or numeric value, allocating
+// optional (pointer) scalars
+func setScalar(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setScalar(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// fieldByName finds the exported field of struct v named name in
+// lowerCamelCase, snake_case or Go spelling
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	key := foldName(name)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() && foldName(f.Name) == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// jsonName turns a Go field name such as "PageToken" into "pageToken"
+func jsonName(goName string) string {
+	r, size := utf8.DecodeRuneInString(goName)
+	return string(unicode.ToLower(r)) + goName[size:]
+}
+
+func foldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
@@ -0,0 +1,269 @@
+// Package gateway serves unary gRPC methods as REST/JSON endpoints, in the
+// manner of grpc-gateway, following the google.api.http annotations in the
+// service protos.
+//
+// Calls are dispatched in-process through the service descriptors, wrapped
+// in the same unary interceptor chain the gRPC server uses, so auth,
+// logging, recovery and deadlines behave identically on both transports.
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// maxBodySize matches the gRPC server's default receive limit
+const maxBodySize = 4 << 20
+
+// metadataHeaderPrefix marks HTTP headers forwarded as gRPC metadata, as in
+// grpc-gateway
+const metadataHeaderPrefix = "grpc-metadata-"
+
+// Route binds an HTTP method and path template to a unary RPC, mirroring
+// one google.api.http rule.
+type Route struct {
+	Method string
+	// Pattern is the path template, e.g. "/v1/products/{id}:restore"
+	Pattern string
+	RPC     string
+	// Body is "*" when the request body carries the request message;
+	// otherwise fields not bound by the path come from the query string
+	Body string
+}
+
+// methodHandler has the signature of grpc.MethodDesc.Handler
+type methodHandler = func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+
+type route struct {
+	Route
+	pattern pattern
+	handler methodHandler
+	srv     interface{}
+}
+
+// Gateway is an http.Handler for the registered routes
+type Gateway struct {
+	interceptor grpc.UnaryServerInterceptor
+	routes      []*route
+}
+
+// New creates a Gateway that runs every call through interceptor, which
+// may be nil
+func New(interceptor grpc.UnaryServerInterceptor) *Gateway {
+	return &Gateway{interceptor: interceptor}
+}
+
+// Register exposes routes of the service described by desc, implemented by
+// srv. Every route must name a unary method of the service.
+func (g *Gateway) Register(desc *grpc.ServiceDesc, srv interface{}, routes []Route) error {
+	for _, r := range routes {
+		var handler methodHandler
+		for _, m := range desc.Methods {
+			if m.MethodName == r.RPC {
+				handler = m.Handler
+				break
+			}
+		}
+		if handler == nil {
+			return fmt.Errorf("%s has no unary method %s", desc.ServiceName, r.RPC)
+		}
+
+		p, err := parsePattern(r.Pattern)
+		if err != nil {
+			return err
+		}
+		g.routes = append(g.routes, &route{Route: r, pattern: p, handler: handler, srv: srv})
+	}
+
+	// "/v1/products/{id}:restore" must win over "/v1/products/{id}"
+	sort.SliceStable(g.routes, func(i, j int) bool {
+		return g.routes[i].pattern.verb != "" && g.routes[j].pattern.verb == ""
+	})
+	return nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt, params, pathMatched := g.match(r.Method, r.URL.EscapedPath())
+	if rt == nil {
+		if pathMatched {
+			writeStatus(w, http.StatusMethodNotAllowed, status.New(codes.Unimplemented, "method not allowed"))
+			return
+		}
+		writeStatus(w, http.StatusNotFound, status.New(codes.NotFound, "not found"))
+		return
+	}
+
+	dec := func(req interface{}) error {
+		return decodeRequest(r, rt, params, req)
+	}
+
+	resp, err := rt.handler(rt.srv, incomingContext(r), dec, g.interceptor)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	body, err := marshalJSON(resp)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode gateway response")
+		writeError(w, status.Error(codes.Internal, "failed to encode response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// match finds the route for method and path. pathMatched reports whether
+// some route matched the path under another method.
+func (g *Gateway) match(method, path string) (rt *route, params map[string]string, pathMatched bool) {
+	for _, candidate := range g.routes {
+		p, ok := candidate.pattern.match(path)
+		if !ok {
+			continue
+		}
+		if candidate.Method == method {
+			return candidate, p, true
+		}
+		pathMatched = true
+	}
+	return nil, nil, pathMatched
+}
+
+// decodeRequest fills req from the body or query string, then the path
+func decodeRequest(r *http.Request, rt *route, params map[string]string, req interface{}) error {
+	if rt.Body == "*" {
+		data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "failed to read request body: %v", err)
+		}
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err := unmarshalJSON(data, req); err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+			}
+		}
+	} else {
+		v := reflect.ValueOf(req).Elem()
+		for key, values := range r.URL.Query() {
+			if _, bound := params[key]; bound {
+				continue
+			}
+			for _, value := range values {
+				if err := setField(v, key, value); err != nil {
+					return status.Errorf(codes.InvalidArgument, "invalid query parameter: %v", err)
+				}
+			}
+		}
+	}
+
+	v := reflect.ValueOf(req).Elem()
+	for name, value := range params {
+		if err := setField(v, name, value); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid path parameter: %v", err)
+		}
+	}
+	return nil
+}
+
+// incomingContext carries the request's Authorization header, and any
+// Grpc-Metadata-* headers, as incoming gRPC metadata, and the client's
+// address and TLS state as its peer
+func incomingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for key, values := range r.Header {
+		key = strings.ToLower(key)
+		switch {
+		case key == "authorization":
+			md.Append(key, values...)
+		case strings.HasPrefix(key, metadataHeaderPrefix):
+			md.Append(strings.TrimPrefix(key, metadataHeaderPrefix), values...)
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		md.Append("x-forwarded-for", host)
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	p := &peer.Peer{Addr: remoteAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{
+			State:          *r.TLS,
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+// remoteAddr is the client address of an HTTP request as a net.Addr
+type remoteAddr string
+
+func (a remoteAddr) Network() string { return "tcp" }
+func (a remoteAddr) String() string  { return string(a) }
+
+// writeError writes err as a JSON google.rpc.Status with the matching HTTP
+// status code
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	if st.Code() == codes.Unauthenticated {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	writeStatus(w, HTTPStatusFromCode(st.Code()), st)
+}
+
+func writeStatus(w http.ResponseWriter, code int, st *status.Status) {
+	body, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode gateway error")
+		body = []byte(`{"code":13,"message":"failed to encode error"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// HTTPStatusFromCode maps a gRPC code to the HTTP status grpc-gateway uses
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
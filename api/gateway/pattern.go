@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// pattern is a parsed google.api.http path template. Only single-segment
+// variables ("{id}") and a trailing custom verb (":restore") are supported,
+// which is all the annotated services use.
+type pattern struct {
+	segments []string // literal segments, or "" where a variable goes
+	vars     []string // variable field paths, by position among segments
+	verb     string
+}
+
+func parsePattern(template string) (pattern, error) {
+	if !strings.HasPrefix(template, "/") {
+		return pattern{}, fmt.Errorf("pattern %q must start with /", template)
+	}
+
+	var p pattern
+	path := template[1:]
+	if i := strings.LastIndex(path, ":"); i >= 0 && !strings.Contains(path[i:], "/") && !strings.Contains(path[i:], "}") {
+		path, p.verb = path[:i], path[i+1:]
+	}
+
+	for _, seg := range strings.Split(path, "/") {
+		switch {
+		case seg == "":
+			return pattern{}, fmt.Errorf("pattern %q has an empty segment", template)
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			name := seg[1 : len(seg)-1]
+			if name == "" || strings.ContainsAny(name, "=*{}") {
+				return pattern{}, fmt.Errorf("pattern %q: unsupported variable %s", template, seg)
+			}
+			p.segments = append(p.segments, "")
+			p.vars = append(p.vars, name)
+		case strings.ContainsAny(seg, "{}*"):
+			return pattern{}, fmt.Errorf("pattern %q: unsupported segment %s", template, seg)
+		default:
+			p.segments = append(p.segments, seg)
+		}
+	}
+	return p, nil
+}
+
+// match reports whether the escaped request path fits the template and, if
+// so, returns the unescaped variable values keyed by field path
+func (p pattern) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if p.verb != "" {
+		if !strings.HasSuffix(path, ":"+p.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+p.verb)
+	}
+
+	segs := strings.Split(path, "/")
+	if len(segs) != len(p.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string, len(p.vars))
+	v := 0
+	for i, seg := range segs {
+		if p.segments[i] != "" {
+			if seg != p.segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		value, err := url.PathUnescape(seg)
+		if err != nil || value == "" {
+			return nil, false
+		}
+		params[p.vars[v]] = value
+		v++
+	}
+	return params, true
+}
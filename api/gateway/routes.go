@@ -0,0 +1,35 @@
+package gateway
+
+import "net/http"
+
+// UserRoutes mirrors the google.api.http rules in proto/user/user.proto
+var UserRoutes = []Route{
+	{Method: http.MethodPost, Pattern: "/v1/auth/register", RPC: "Register", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/auth/login", RPC: "Login", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/auth/refresh", RPC: "RefreshToken", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/auth/logout", RPC: "Logout", Body: "*"},
+	{Method: http.MethodGet, Pattern: "/v1/users/me", RPC: "GetProfile"},
+	{Method: http.MethodPatch, Pattern: "/v1/users/me", RPC: "UpdateProfile", Body: "*"},
+	{Method: http.MethodGet, Pattern: "/v1/auth/keys", RPC: "GetPublicKeys"},
+	{Method: http.MethodDelete, Pattern: "/v1/users/{id}", RPC: "DeleteUser"},
+	{Method: http.MethodPost, Pattern: "/v1/users/{id}:restore", RPC: "RestoreUser", Body: "*"},
+}
+
+// ProductRoutes mirrors the google.api.http rules in
+// proto/product/product.proto
+var ProductRoutes = []Route{
+	{Method: http.MethodPost, Pattern: "/v1/products", RPC: "CreateProduct", Body: "*"},
+	{Method: http.MethodGet, Pattern: "/v1/products/{id}", RPC: "GetProduct"},
+	{Method: http.MethodGet, Pattern: "/v1/products", RPC: "ListProducts"},
+	{Method: http.MethodGet, Pattern: "/v1/products:search", RPC: "SearchProducts"},
+	{Method: http.MethodPatch, Pattern: "/v1/products/{id}", RPC: "UpdateProduct", Body: "*"},
+	{Method: http.MethodDelete, Pattern: "/v1/products/{id}", RPC: "DeleteProduct"},
+	{Method: http.MethodPost, Pattern: "/v1/products/{id}:restore", RPC: "RestoreProduct", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/products:batchCreate", RPC: "BatchCreateProducts", Body: "*"},
+	{Method: http.MethodGet, Pattern: "/v1/products:batchGet", RPC: "BatchGetProducts"},
+	{Method: http.MethodPost, Pattern: "/v1/products:batchDelete", RPC: "BatchDeleteProducts", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/products/{product_id}:adjustStock", RPC: "AdjustStock", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/products/{product_id}:reserveStock", RPC: "ReserveStock", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/reservations/{reservation_id}:release", RPC: "ReleaseStock", Body: "*"},
+	{Method: http.MethodPost, Pattern: "/v1/reservations/{reservation_id}:commit", RPC: "CommitReservation", Body: "*"},
+}
@@ -0,0 +1,321 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"google.golang.org/grpc/credentials"
+)
+
+// defaultSniffTimeout bounds how long a new connection may take to send
+// its first request when no read timeout is configured
+const defaultSniffTimeout = 10 * time.Second
+
+// connSplitter shares one listener between native gRPC and the browser
+// protocols. It reads the first request on each connection: HTTP/2 with an
+// application/grpc content type goes to the gRPC listener, anything else to
+// the web listener. Splitting connections rather than requests lets
+// grpc.Server serve native gRPC with its own transport, keepalive
+// enforcement and GracefulStop.
+type connSplitter struct {
+	root    net.Listener
+	timeout time.Duration
+	grpc    *connQueue
+	web     *connQueue
+}
+
+func newConnSplitter(root net.Listener, timeout time.Duration) *connSplitter {
+	if timeout <= 0 {
+		timeout = defaultSniffTimeout
+	}
+	return &connSplitter{
+		root:    root,
+		timeout: timeout,
+		grpc:    newConnQueue(root.Addr()),
+		web:     newConnQueue(root.Addr()),
+	}
+}
+
+// Serve routes connections until the root listener is closed. The gRPC and
+// web listeners are closed by the servers that accept from them.
+func (s *connSplitter) Serve() {
+	for {
+		conn, err := s.root.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.WithError(err).Error("gRPC listener stopped accepting connections")
+			}
+			return
+		}
+		go s.route(conn)
+	}
+}
+
+// route hands conn to the listener its first request belongs to
+func (s *connSplitter) route(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	routed, grpc, err := sniff(conn)
+	conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	queue := s.web
+	if grpc {
+		queue = s.grpc
+	}
+	if !queue.put(routed) {
+		routed.Close()
+	}
+}
+
+// sniff reads conn up to its first request headers and returns a
+// connection that replays what was read, and whether the request is native
+// gRPC
+func sniff(conn net.Conn) (net.Conn, bool, error) {
+	rec := &recorder{r: conn}
+	buf := make([]byte, len(http2.ClientPreface))
+	for len(rec.buf) < len(http2.ClientPreface) {
+		n, err := rec.Read(buf[:len(http2.ClientPreface)-len(rec.buf)])
+		if !strings.HasPrefix(http2.ClientPreface, string(rec.buf)) {
+			// HTTP/1.x, which only the browser protocols use
+			return newReplayConn(conn, rec.buf, false), false, nil
+		}
+		if err != nil && n == 0 {
+			return nil, false, err
+		}
+	}
+
+	framer := http2.NewFramer(conn, rec)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	sentSettings, acked := false, false
+	for {
+		start := len(rec.buf)
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				if sentSettings && !acked {
+					rec.buf = rec.buf[:start]
+					acked = true
+				}
+				continue
+			}
+			// gRPC clients wait for the server's settings before their
+			// first request
+			if !sentSettings {
+				if err := framer.WriteSettings(); err != nil {
+					return nil, false, err
+				}
+				sentSettings = true
+			}
+		case *http2.MetaHeadersFrame:
+			grpc := false
+			for _, field := range f.RegularFields() {
+				if field.Name == "content-type" {
+					grpc = isGRPC(field.Value)
+				}
+			}
+			return newReplayConn(conn, rec.buf, sentSettings && !acked), grpc, nil
+		}
+	}
+}
+
+// isGRPC matches application/grpc and its subtypes, but not gRPC-Web
+func isGRPC(contentType string) bool {
+	return contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;")
+}
+
+// recorder keeps every byte read through it
+type recorder struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// replayConn returns the bytes read while sniffing before reading on
+type replayConn struct {
+	net.Conn
+	r io.Reader
+}
+
+// newReplayConn replays read ahead of conn. dropAck removes the client's
+// acknowledgement of the settings sent while sniffing, if it is still to
+// come, since the server the connection is handed to never sent them.
+func newReplayConn(conn net.Conn, read []byte, dropAck bool) *replayConn {
+	r := io.MultiReader(bytes.NewReader(read), conn)
+	if dropAck {
+		r = &settingsAckFilter{r: r, pass: len(http2.ClientPreface)}
+	}
+	return &replayConn{Conn: conn, r: r}
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// tlsState is the state of the TLS connection underneath, if any
+func (c *replayConn) tlsState() (tls.ConnectionState, bool) {
+	tc, ok := c.Conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tc.ConnectionState(), true
+}
+
+// settingsAckFilter passes HTTP/2 frames through, except the first
+// SETTINGS acknowledgement
+type settingsAckFilter struct {
+	r       io.Reader
+	pass    int    // bytes before the next frame header
+	out     []byte // frame header read but not yet returned
+	dropped bool
+}
+
+func (f *settingsAckFilter) Read(p []byte) (int, error) {
+	for {
+		switch {
+		case len(f.out) > 0:
+			n := copy(p, f.out)
+			f.out = f.out[n:]
+			return n, nil
+		case f.dropped:
+			return f.r.Read(p)
+		case f.pass > 0:
+			if len(p) > f.pass {
+				p = p[:f.pass]
+			}
+			n, err := f.r.Read(p)
+			f.pass -= n
+			return n, err
+		}
+
+		var header [9]byte
+		if _, err := io.ReadFull(f.r, header[:]); err != nil {
+			return 0, err
+		}
+		if http2.FrameType(header[3]) == http2.FrameSettings && http2.Flags(header[4]).Has(http2.FlagSettingsAck) {
+			f.dropped = true
+			continue
+		}
+		f.out = append(f.out[:0], header[:]...)
+		f.pass = int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+	}
+}
+
+// connQueue is a net.Listener fed with connections by the splitter
+type connQueue struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnQueue(addr net.Addr) *connQueue {
+	return &connQueue{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// put hands conn to Accept, and reports false once the queue is closed
+func (q *connQueue) put(conn net.Conn) bool {
+	select {
+	case q.conns <- conn:
+		return true
+	case <-q.done:
+		return false
+	}
+}
+
+func (q *connQueue) Accept() (net.Conn, error) {
+	select {
+	case conn := <-q.conns:
+		return conn, nil
+	case <-q.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (q *connQueue) Close() error {
+	q.once.Do(func() { close(q.done) })
+	return nil
+}
+
+func (q *connQueue) Addr() net.Addr { return q.addr }
+
+// splitTLS gives gRPC the TLS state of connections the splitter has
+// already decrypted, so client certificates still authenticate services
+type splitTLS struct{}
+
+func (splitTLS) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	rc, ok := conn.(*replayConn)
+	if !ok {
+		return nil, nil, errors.New("connection did not come through the listener splitter")
+	}
+	state, ok := rc.tlsState()
+	if !ok {
+		return nil, nil, errors.New("connection is not TLS")
+	}
+	return conn, credentials.TLSInfo{
+		State:          state,
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+	}, nil
+}
+
+func (splitTLS) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("splitTLS only serves connections")
+}
+
+func (splitTLS) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls", SecurityVersion: "1.2"}
+}
+
+func (c splitTLS) Clone() credentials.TransportCredentials { return c }
+
+func (splitTLS) OverrideServerName(string) error { return nil }
+
+// tlsStateKey holds the TLS state of a split connection in its context
+type tlsStateKey struct{}
+
+// withTLSState sets r.TLS for requests on split TLS connections, which
+// net/http sees as plain connections
+func withTLSState(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if state, ok := r.Context().Value(tlsStateKey{}).(*tls.ConnectionState); ok && r.TLS == nil {
+			r.TLS = state
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// splitConnContext records the TLS state of a split connection for
+// withTLSState
+func splitConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if rc, ok := conn.(*replayConn); ok {
+		if state, ok := rc.tlsState(); ok {
+			return context.WithValue(ctx, tlsStateKey{}, &state)
+		}
+	}
+	return ctx
+}
@@ -1,14 +1,26 @@
 package grpc
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"sync"
 
+	"grpc-exmpl/api/gateway"
+	"grpc-exmpl/api/web"
+	"grpc-exmpl/internal/config"
 	handler "grpc-exmpl/internal/handler/grpc"
 	"grpc-exmpl/internal/middleware"
 	"grpc-exmpl/internal/service"
+	"grpc-exmpl/pkg/utils"
+	pbcategory "grpc-exmpl/proto/category"
+	pborder "grpc-exmpl/proto/order"
 	pbproduct "grpc-exmpl/proto/product"
 	pbuser "grpc-exmpl/proto/user"
+	pbwebhook "grpc-exmpl/proto/webhook"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/sirupsen/logrus"
@@ -17,81 +29,304 @@ import (
 )
 
 type Server struct {
-	grpcServer     *grpc.Server
-	userService    service.UserService
-	productService service.ProductService
-	port           string
+	grpcServer      *grpc.Server
+	webServer       *http.Server
+	gatewayServer   *http.Server
+	listener        net.Listener
+	stopped         chan struct{}
+	stopOnce        sync.Once
+	certReloader    *utils.CertReloader
+	userService     service.UserService
+	productService  service.ProductService
+	productWatcher  service.ProductWatcher
+	orderService    service.OrderService
+	categoryService service.CategoryService
+	webhookService  service.WebhookService
+	port            string
+	serverConfig    config.ServerConfig
+	authConfig      config.AuthConfig
 }
 
-func NewServer(userService service.UserService, productService service.ProductService, port string) *Server {
+func NewServer(userService service.UserService, productService service.ProductService, productWatcher service.ProductWatcher, orderService service.OrderService, categoryService service.CategoryService, webhookService service.WebhookService, serverConfig config.ServerConfig, authConfig config.AuthConfig) *Server {
 	return &Server{
-		userService:    userService,
-		productService: productService,
-		port:           port,
+		userService:     userService,
+		productService:  productService,
+		productWatcher:  productWatcher,
+		orderService:    orderService,
+		categoryService: categoryService,
+		webhookService:  webhookService,
+		port:            serverConfig.Port,
+		serverConfig:    serverConfig,
+		authConfig:      authConfig,
+		stopped:         make(chan struct{}),
 	}
 }
 
 func (s *Server) Start() error {
-	// Create listener
+	// Load per-method access policies
+	policies, err := middleware.NewPolicyTable(s.authConfig)
+	if err != nil {
+		return fmt.Errorf("invalid auth policy: %w", err)
+	}
+
+	// Load TLS certificates and keep them current as they are rotated
+	var tlsConfig *tls.Config
+	if s.serverConfig.TLS.Enabled {
+		s.certReloader, err = newCertReloader(s.serverConfig.TLS)
+		if err != nil {
+			return err
+		}
+		if err := s.certReloader.Watch(); err != nil {
+			return err
+		}
+		tlsConfig = s.certReloader.TLSConfig()
+	}
+
+	// Create listener. gRPC, gRPC-Web and Connect share it; TLS ends here
+	// so the splitter can see which protocol each connection speaks.
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.port))
 	if err != nil {
 		return fmt.Errorf("failed to listen on port %s: %w", s.port, err)
 	}
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+	s.listener = lis
+	splitter := newConnSplitter(lis, s.serverConfig.ReadTimeout)
 
 	// Create middleware
-	authMiddleware := middleware.NewAuthMiddleware(s.userService)
+	authMiddleware := middleware.NewAuthMiddleware(s.userService, policies)
 
 	// Create logrus entry for gRPC logging
 	logrusEntry := logrus.NewEntry(logrus.StandardLogger())
 	loggingMiddleware := middleware.NewLoggingMiddleware(logrusEntry)
 	recoveryMiddleware := middleware.NewRecoveryMiddleware(logrusEntry)
+	deadlineMiddleware := middleware.NewDeadlineMiddleware(s.serverConfig.RequestTimeout, s.serverConfig.MethodTimeouts)
 
-	// Create gRPC server with middleware
-	s.grpcServer = grpc.NewServer(
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-			loggingMiddleware.UnaryInterceptor,
-			recoveryMiddleware.UnaryInterceptor,
-			authMiddleware.UnaryInterceptor,
-		)),
-		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
-			loggingMiddleware.StreamInterceptor,
-			recoveryMiddleware.StreamInterceptor,
-			authMiddleware.StreamInterceptor,
-		)),
+	// The HTTP gateway and the browser protocols share the interceptor
+	// chains with the gRPC server
+	unaryInterceptor := grpc_middleware.ChainUnaryServer(
+		loggingMiddleware.UnaryInterceptor,
+		recoveryMiddleware.UnaryInterceptor,
+		deadlineMiddleware.UnaryInterceptor,
+		authMiddleware.UnaryInterceptor,
+	)
+	streamInterceptor := grpc_middleware.ChainStreamServer(
+		loggingMiddleware.StreamInterceptor,
+		recoveryMiddleware.StreamInterceptor,
+		deadlineMiddleware.StreamInterceptor,
+		authMiddleware.StreamInterceptor,
 	)
 
+	// Create gRPC server with middleware
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(unaryInterceptor),
+		grpc.StreamInterceptor(streamInterceptor),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(splitTLS{}))
+	}
+	s.grpcServer = grpc.NewServer(opts...)
+
 	// Register all services
-	s.registerServices()
+	gw := gateway.New(unaryInterceptor)
+	browser := web.New(unaryInterceptor, streamInterceptor)
+	if err := s.registerServices(registrars{s.grpcServer, browser}, gw); err != nil {
+		return err
+	}
 
 	// Enable reflection (for development/debugging)
 	reflection.Register(s.grpcServer)
 
-	logrus.Infof("gRPC server starting on port %s", s.port)
+	corsMiddleware := middleware.NewCORSMiddleware(s.serverConfig.CORS)
+
+	// Serve the REST/JSON gateway alongside, when configured
+	if s.serverConfig.HTTPPort != "" {
+		if err := s.startGateway(corsMiddleware.Handler(gw), tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	logrus.WithField("tls", tlsConfig != nil).Infof("gRPC server starting on port %s", s.port)
 
 	// Start server
-	if err := s.grpcServer.Serve(lis); err != nil {
+	s.startWeb(corsMiddleware.Handler(browser), splitter.web)
+	go splitter.Serve()
+	if err := s.grpcServer.Serve(splitter.grpc); err != nil {
 		return fmt.Errorf("failed to serve gRPC server: %w", err)
 	}
+	// Let Stop finish draining calls before returning
+	<-s.stopped
 
 	return nil
 }
 
 func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.serverConfig.ShutdownTimeout)
+	defer cancel()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	if s.gatewayServer != nil {
+		logrus.Info("Stopping HTTP gateway...")
+		if err := s.gatewayServer.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Warn("HTTP gateway did not shut down cleanly")
+		}
+	}
+
+	if s.webServer != nil {
+		logrus.Info("Stopping gRPC-Web and Connect server...")
+		if err := s.webServer.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Warn("gRPC-Web and Connect server did not shut down cleanly")
+		}
+	}
+
 	if s.grpcServer != nil {
 		logrus.Info("Stopping gRPC server...")
-		s.grpcServer.GracefulStop()
+		stopGRPC(ctx, s.grpcServer)
 		logrus.Info("gRPC server stopped")
+		s.stopOnce.Do(func() { close(s.stopped) })
+	}
+
+	if s.certReloader != nil {
+		s.certReloader.Close()
 	}
 }
 
-func (s *Server) registerServices() {
+// stopGRPC lets running calls finish, then cuts off whatever is still
+// running, such as open watch streams, once ctx is done
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logrus.Warn("gRPC calls still running after the shutdown timeout, stopping them")
+		srv.Stop()
+		<-done
+	}
+}
+
+// serve accepts connections on lis, over TLS when srv has a TLS config
+func serve(srv *http.Server, lis net.Listener) error {
+	if srv.TLSConfig != nil {
+		return srv.ServeTLS(lis, "", "")
+	}
+	return srv.Serve(lis)
+}
+
+// newCertReloader loads the configured certificates
+func newCertReloader(cfg config.TLSConfig) (*utils.CertReloader, error) {
+	var clientAuth tls.ClientAuthType
+	switch cfg.ClientAuth {
+	case "", "request":
+		clientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid tls client_auth %q", cfg.ClientAuth)
+	}
+
+	reloader, err := utils.NewCertReloader(utils.TLSFiles{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		ClientCAFile: cfg.ClientCAFile,
+	}, clientAuth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificates: %w", err)
+	}
+	return reloader, nil
+}
+
+// registrars registers each service with every transport
+type registrars []grpc.ServiceRegistrar
+
+func (r registrars) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	for _, registrar := range r {
+		registrar.RegisterService(desc, impl)
+	}
+}
+
+// startGateway serves gw on the HTTP port in the background, over TLS when
+// tlsConfig is set
+func (s *Server) startGateway(gw http.Handler, tlsConfig *tls.Config) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.serverConfig.HTTPPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %w", s.serverConfig.HTTPPort, err)
+	}
+
+	s.gatewayServer = &http.Server{
+		Handler:      gw,
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  s.serverConfig.ReadTimeout,
+		WriteTimeout: s.serverConfig.WriteTimeout,
+	}
+
+	logrus.Infof("HTTP gateway starting on port %s", s.serverConfig.HTTPPort)
+	go func() {
+		if err := serve(s.gatewayServer, lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("HTTP gateway stopped")
+		}
+	}()
+
+	return nil
+}
+
+// startWeb serves gRPC-Web and Connect on the connections the splitter
+// hands to lis, in the background. The splitter has already ended TLS, so
+// browsers may use HTTP/1.1 or HTTP/2 in either case. Read and write
+// timeouts are left unset since they would cut long-lived streams.
+func (s *Server) startWeb(browser http.Handler, lis net.Listener) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	s.webServer = &http.Server{
+		Handler:           withTLSState(browser),
+		Protocols:         &protocols,
+		ConnContext:       splitConnContext,
+		ReadHeaderTimeout: s.serverConfig.ReadTimeout,
+	}
+
+	go func() {
+		if err := s.webServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("gRPC-Web and Connect server stopped")
+		}
+	}()
+}
+
+func (s *Server) registerServices(registrar grpc.ServiceRegistrar, gw *gateway.Gateway) error {
 	// Register User service
 	userHandler := handler.NewUserHandler(s.userService)
-	pbuser.RegisterUserServiceServer(s.grpcServer, userHandler)
+	pbuser.RegisterUserServiceServer(registrar, userHandler)
+	if err := gw.Register(&pbuser.UserService_ServiceDesc, userHandler, gateway.UserRoutes); err != nil {
+		return fmt.Errorf("failed to register user gateway routes: %w", err)
+	}
 
 	// Register Product service
-	productHandler := handler.NewProductHandler(s.productService)
-	pbproduct.RegisterProductServiceServer(s.grpcServer, productHandler)
+	productHandler := handler.NewProductHandler(s.productService, s.productWatcher)
+	pbproduct.RegisterProductServiceServer(registrar, productHandler)
+	if err := gw.Register(&pbproduct.ProductService_ServiceDesc, productHandler, gateway.ProductRoutes); err != nil {
+		return fmt.Errorf("failed to register product gateway routes: %w", err)
+	}
+
+	// Register Order service
+	orderHandler := handler.NewOrderHandler(s.orderService)
+	pborder.RegisterOrderServiceServer(registrar, orderHandler)
+
+	// Register Category service
+	categoryHandler := handler.NewCategoryHandler(s.categoryService)
+	pbcategory.RegisterCategoryServiceServer(registrar, categoryHandler)
+
+	// Register Webhook service
+	webhookHandler := handler.NewWebhookHandler(s.webhookService)
+	pbwebhook.RegisterWebhookServiceServer(registrar, webhookHandler)
 
 	logrus.Info("gRPC services registered successfully")
+	return nil
 }
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// protoCodec encodes the +proto subtypes. It needs messages implementing
+// proto.Message; the plain structs in proto/ only work with +json.
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a protobuf message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a protobuf message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (protoCodec) Name() string {
+	return "proto"
+}
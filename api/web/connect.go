@@ -0,0 +1,176 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"grpc-exmpl/api/gateway"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// connectUnary is the Connect protocol for unary methods: a bare message
+// each way, with the outcome in the HTTP status and errors as a JSON body
+type connectUnary struct {
+	compressed bool
+	read       bool
+	response   []byte
+}
+
+func (p *connectUnary) streaming() bool {
+	return false
+}
+
+func (p *connectUnary) readMessage(s *stream) ([]byte, error) {
+	if p.read {
+		return nil, io.EOF
+	}
+	p.read = true
+	if p.compressed {
+		return nil, status.Error(codes.Unimplemented, "compressed requests are not supported")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(s.body, maxMessageSize+1))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to read message: %v", err)
+	}
+	if len(data) > maxMessageSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "message exceeds the %d byte limit", maxMessageSize)
+	}
+	// An empty body is the empty message in either encoding
+	if len(bytes.TrimSpace(data)) == 0 && s.codec.Name() == "json" {
+		data = []byte("{}")
+	}
+	return data, nil
+}
+
+// writeHeader is deferred to finish, when the status code is known
+func (p *connectUnary) writeHeader(s *stream) {}
+
+func (p *connectUnary) writeMessage(s *stream, data []byte) error {
+	p.response = data
+	return nil
+}
+
+func (p *connectUnary) finish(s *stream, err error) {
+	h := s.w.Header()
+	writeMetadata(h, "", s.header)
+	writeMetadata(h, "Trailer-", s.trailer)
+
+	if err == nil {
+		h.Set("Content-Type", s.contentType)
+		s.w.WriteHeader(http.StatusOK)
+		s.w.Write(p.response)
+		return
+	}
+
+	st := status.Convert(err)
+	body, _ := json.Marshal(newConnectError(st))
+	h.Set("Content-Type", "application/json")
+	s.w.WriteHeader(gateway.HTTPStatusFromCode(st.Code()))
+	s.w.Write(body)
+}
+
+// connectStream is the Connect protocol for streaming methods: enveloped
+// messages each way, ending with an end-stream envelope carrying the error
+// and trailers as JSON
+type connectStream struct {
+	compressed bool
+}
+
+func (p *connectStream) streaming() bool {
+	return true
+}
+
+func (p *connectStream) readMessage(s *stream) ([]byte, error) {
+	flags, data, err := readEnvelope(s.body)
+	if err != nil {
+		return nil, err
+	}
+	if p.compressed || flags&flagCompressed != 0 {
+		return nil, status.Error(codes.Unimplemented, "compressed messages are not supported")
+	}
+	if flags&flagEndStream != 0 {
+		return nil, io.EOF
+	}
+	return data, nil
+}
+
+func (p *connectStream) writeHeader(s *stream) {
+	h := s.w.Header()
+	h.Set("Content-Type", s.contentType)
+	writeMetadata(h, "", s.header)
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (p *connectStream) writeMessage(s *stream, data []byte) error {
+	err := writeEnvelope(s.w, 0, data)
+	flush(s.w)
+	return err
+}
+
+func (p *connectStream) finish(s *stream, err error) {
+	s.sendHeader()
+
+	end := struct {
+		Error    *connectError       `json:"error,omitempty"`
+		Metadata map[string][]string `json:"metadata,omitempty"`
+	}{}
+	if err != nil {
+		end.Error = newConnectError(status.Convert(err))
+	}
+	if len(s.trailer) > 0 {
+		h := http.Header{}
+		writeMetadata(h, "", s.trailer)
+		end.Metadata = h
+	}
+
+	body, _ := json.Marshal(end)
+	_ = writeEnvelope(s.w, flagEndStream, body)
+	flush(s.w)
+}
+
+// connectError is the JSON form of an error in the Connect protocol
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+// connectDetail carries one google.rpc.Status detail, base64 encoded
+type connectDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func newConnectError(st *status.Status) *connectError {
+	e := &connectError{Code: connectCode(st.Code()), Message: st.Message()}
+	for _, detail := range st.Proto().GetDetails() {
+		e.Details = append(e.Details, connectDetail{
+			Type:  strings.TrimPrefix(detail.GetTypeUrl(), "type.googleapis.com/"),
+			Value: base64.RawStdEncoding.EncodeToString(detail.GetValue()),
+		})
+	}
+	return e
+}
+
+// connectCode spells a gRPC code the Connect way, e.g. "invalid_argument"
+func connectCode(code codes.Code) string {
+	var b strings.Builder
+	for i, r := range code.String() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// protocolHeaders are request headers consumed by the protocols rather
+// than passed on as metadata
+var protocolHeaders = map[string]bool{
+	"accept":                   true,
+	"accept-encoding":          true,
+	"connection":               true,
+	"content-encoding":         true,
+	"content-length":           true,
+	"content-type":             true,
+	"te":                       true,
+	"grpc-accept-encoding":     true,
+	"grpc-encoding":            true,
+	"grpc-timeout":             true,
+	"x-grpc-web":               true,
+	"connect-accept-encoding":  true,
+	"connect-content-encoding": true,
+	"connect-protocol-version": true,
+	"connect-timeout-ms":       true,
+}
+
+// incomingContext gives the call the request headers as incoming metadata,
+// the client's address and TLS state as its peer, and the client's
+// deadline, if any
+func incomingContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	md := metadata.MD{}
+	for key, values := range r.Header {
+		key = strings.ToLower(key)
+		if protocolHeaders[key] {
+			continue
+		}
+		for _, v := range values {
+			if strings.HasSuffix(key, "-bin") {
+				if decoded, err := decodeBinaryHeader(v); err == nil {
+					v = decoded
+				}
+			}
+			md.Append(key, v)
+		}
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	p := &peer.Peer{Addr: remoteAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{
+			State:          *r.TLS,
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+	}
+	ctx = peer.NewContext(ctx, p)
+
+	timeout, ok, err := requestTimeout(r.Header)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	if ok {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// requestTimeout reads the gRPC-Web Grpc-Timeout or Connect
+// Connect-Timeout-Ms header
+func requestTimeout(h http.Header) (time.Duration, bool, error) {
+	if v := h.Get("Connect-Timeout-Ms"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ms <= 0 || len(v) > 10 {
+			return 0, false, status.Errorf(codes.InvalidArgument, "invalid Connect-Timeout-Ms %q", v)
+		}
+		return time.Duration(ms) * time.Millisecond, true, nil
+	}
+
+	v := h.Get("Grpc-Timeout")
+	if v == "" {
+		return 0, false, nil
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[v[len(v)-1]]
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if !ok || err != nil || n <= 0 || len(v) > 9 {
+		return 0, false, status.Errorf(codes.InvalidArgument, "invalid Grpc-Timeout %q", v)
+	}
+	return time.Duration(n) * unit, true, nil
+}
+
+// writeMetadata adds md to h, each key prefixed with prefix and binary
+// values base64 encoded
+func writeMetadata(h http.Header, prefix string, md metadata.MD) {
+	for key, values := range md {
+		for _, v := range values {
+			if strings.HasSuffix(key, "-bin") {
+				v = base64.RawStdEncoding.EncodeToString([]byte(v))
+			}
+			h.Add(prefix+key, v)
+		}
+	}
+}
+
+// decodeBinaryHeader decodes a -bin header value, padded or not
+func decodeBinaryHeader(v string) (string, error) {
+	if len(v)%4 == 0 {
+		b, err := base64.StdEncoding.DecodeString(v)
+		return string(b), err
+	}
+	b, err := base64.RawStdEncoding.DecodeString(v)
+	return string(b), err
+}
+
+// remoteAddr is the client address of an HTTP request as a net.Addr
+type remoteAddr string
+
+func (a remoteAddr) Network() string { return "tcp" }
+func (a remoteAddr) String() string  { return string(a) }
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcWeb is the gRPC-Web protocol: enveloped messages in both directions,
+// with the status and trailers sent as a final envelope in the body since
+// browsers cannot read HTTP trailers
+type grpcWeb struct {
+	text bool
+}
+
+func (p *grpcWeb) streaming() bool {
+	return true
+}
+
+func (p *grpcWeb) readMessage(s *stream) ([]byte, error) {
+	flags, data, err := readEnvelope(s.body)
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagCompressed != 0 {
+		return nil, status.Error(codes.Unimplemented, "compressed messages are not supported")
+	}
+	if flags&flagTrailers != 0 {
+		return nil, status.Error(codes.InvalidArgument, "unexpected trailers from client")
+	}
+	return data, nil
+}
+
+func (p *grpcWeb) writeHeader(s *stream) {
+	h := s.w.Header()
+	h.Set("Content-Type", s.contentType)
+	writeMetadata(h, "", s.header)
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (p *grpcWeb) writeMessage(s *stream, data []byte) error {
+	return p.writeEnvelope(s, 0, data)
+}
+
+func (p *grpcWeb) finish(s *stream, err error) {
+	s.sendHeader()
+
+	st := status.Convert(err)
+	var trailers bytes.Buffer
+	fmt.Fprintf(&trailers, "grpc-status: %d\r\n", st.Code())
+	if st.Message() != "" {
+		fmt.Fprintf(&trailers, "grpc-message: %s\r\n", encodeGrpcMessage(st.Message()))
+	}
+	if len(st.Details()) > 0 {
+		if bin, err := proto.Marshal(st.Proto()); err == nil {
+			fmt.Fprintf(&trailers, "grpc-status-details-bin: %s\r\n", base64.RawStdEncoding.EncodeToString(bin))
+		}
+	}
+	h := http.Header{}
+	writeMetadata(h, "", s.trailer)
+	for key, values := range h {
+		for _, v := range values {
+			fmt.Fprintf(&trailers, "%s: %s\r\n", strings.ToLower(key), v)
+		}
+	}
+
+	_ = p.writeEnvelope(s, flagTrailers, trailers.Bytes())
+}
+
+func (p *grpcWeb) writeEnvelope(s *stream, flags byte, data []byte) error {
+	var w io.Writer = s.w
+	var enc io.WriteCloser
+	if p.text {
+		// Each envelope is encoded separately, so padding may appear
+		// mid-body; gRPC-Web clients decode chunk by chunk
+		enc = base64.NewEncoder(base64.StdEncoding, s.w)
+		w = enc
+	}
+
+	err := writeEnvelope(w, flags, data)
+	if err == nil && enc != nil {
+		err = enc.Close()
+	}
+	flush(s.w)
+	return err
+}
+
+// encodeGrpcMessage percent-encodes a status message as the gRPC spec
+// requires for the grpc-message field
+func encodeGrpcMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// protocol frames one call's messages and outcome on the HTTP exchange
+type protocol interface {
+	// streaming reports whether streaming methods can be called
+	streaming() bool
+	// readMessage returns the next request message, or io.EOF after the last
+	readMessage(s *stream) ([]byte, error)
+	writeHeader(s *stream)
+	writeMessage(s *stream, data []byte) error
+	// finish ends the response with the call's outcome
+	finish(s *stream, err error)
+}
+
+// stream is one gRPC-Web or Connect call. It is the grpc.ServerStream
+// handed to streaming methods.
+type stream struct {
+	ctx         context.Context
+	method      string
+	w           http.ResponseWriter
+	body        io.Reader
+	contentType string
+	codec       encoding.Codec
+	protocol    protocol
+
+	header     metadata.MD
+	trailer    metadata.MD
+	headerSent bool
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream) SetHeader(md metadata.MD) error {
+	if s.headerSent {
+		return status.Error(codes.Internal, "headers already sent")
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *stream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	s.sendHeader()
+	return nil
+}
+
+func (s *stream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *stream) SendMsg(m interface{}) error {
+	data, err := s.codec.Marshal(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to encode response: %v", err)
+	}
+	s.sendHeader()
+	if err := s.protocol.writeMessage(s, data); err != nil {
+		return status.Errorf(codes.Unavailable, "failed to send message: %v", err)
+	}
+	return nil
+}
+
+func (s *stream) RecvMsg(m interface{}) error {
+	data, err := s.protocol.readMessage(s)
+	if err != nil {
+		return err
+	}
+	if err := s.codec.Unmarshal(data, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err)
+	}
+	return nil
+}
+
+func (s *stream) sendHeader() {
+	if !s.headerSent {
+		s.headerSent = true
+		s.protocol.writeHeader(s)
+	}
+}
+
+// transportStream backs grpc.SetHeader, grpc.SendHeader and grpc.SetTrailer
+// in unary methods
+type transportStream struct {
+	s *stream
+}
+
+func (t transportStream) Method() string                  { return t.s.method }
+func (t transportStream) SetHeader(md metadata.MD) error  { return t.s.SetHeader(md) }
+func (t transportStream) SendHeader(md metadata.MD) error { return t.s.SendHeader(md) }
+func (t transportStream) SetTrailer(md metadata.MD) error {
+	t.s.SetTrailer(md)
+	return nil
+}
+
+// Envelope flags shared by gRPC-Web and Connect streaming
+const (
+	flagCompressed byte = 0x01
+	flagEndStream  byte = 0x02 // Connect
+	flagTrailers   byte = 0x80 // gRPC-Web
+)
+
+// readEnvelope reads one length-prefixed message: a flags byte, then a
+// big-endian uint32 length
+func readEnvelope(r io.Reader) (byte, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, status.Errorf(codes.InvalidArgument, "failed to read message: %v", err)
+	}
+
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxMessageSize {
+		return 0, nil, status.Errorf(codes.ResourceExhausted, "message of %d bytes exceeds the %d byte limit", size, maxMessageSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, status.Errorf(codes.InvalidArgument, "failed to read message: %v", err)
+	}
+	return prefix[0], data, nil
+}
+
+func writeEnvelope(w io.Writer, flags byte, data []byte) error {
+	var prefix [5]byte
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// flush pushes buffered response bytes to the client, so streamed
+// messages arrive as they are sent
+func flush(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).Flush()
+}
@@ -0,0 +1,195 @@
+// Package web serves gRPC services to browsers over the gRPC-Web and
+// Connect protocols.
+//
+// Calls are dispatched in-process through the service descriptors and run
+// through the same unary and stream interceptor chains as native gRPC, so
+// auth, logging, recovery and deadlines behave identically.
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"grpc-exmpl/api/gateway"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// maxMessageSize matches the gRPC server's default receive limit
+const maxMessageSize = 4 << 20
+
+// methodHandler has the signature of grpc.MethodDesc.Handler
+type methodHandler = func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+
+type method struct {
+	srv    interface{}
+	unary  methodHandler
+	stream *grpc.StreamDesc
+}
+
+// Handler is an http.Handler for gRPC-Web and Connect requests. It is a
+// grpc.ServiceRegistrar, so services are added with the same
+// RegisterXServer functions as on the gRPC server.
+type Handler struct {
+	unaryInterceptor  grpc.UnaryServerInterceptor
+	streamInterceptor grpc.StreamServerInterceptor
+	methods           map[string]*method
+}
+
+// New creates a Handler that runs calls through the given interceptors,
+// either of which may be nil
+func New(unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) *Handler {
+	return &Handler{
+		unaryInterceptor:  unary,
+		streamInterceptor: stream,
+		methods:           make(map[string]*method),
+	}
+}
+
+// RegisterService registers a service and its implementation
+func (h *Handler) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	for _, m := range desc.Methods {
+		h.methods["/"+desc.ServiceName+"/"+m.MethodName] = &method{srv: impl, unary: m.Handler}
+	}
+	for i := range desc.Streams {
+		sd := &desc.Streams[i]
+		h.methods["/"+desc.ServiceName+"/"+sd.StreamName] = &method{srv: impl, stream: sd}
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s, ok := newStream(w, r)
+	if !ok {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel, err := incomingContext(r)
+	defer cancel()
+	if err == nil {
+		s.ctx = grpc.NewContextWithServerTransportStream(ctx, transportStream{s})
+		err = h.call(s)
+	}
+	s.protocol.finish(s, err)
+}
+
+// call runs the requested method to completion
+func (h *Handler) call(s *stream) error {
+	m, ok := h.methods[s.method]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", s.method)
+	}
+
+	if m.stream == nil {
+		dec := func(req interface{}) error {
+			if err := s.RecvMsg(req); err != nil {
+				if err == io.EOF {
+					return status.Error(codes.InvalidArgument, "missing request message")
+				}
+				return err
+			}
+			return nil
+		}
+		resp, err := m.unary(m.srv, s.ctx, dec, h.unaryInterceptor)
+		if err != nil {
+			return err
+		}
+		return s.SendMsg(resp)
+	}
+
+	if !s.protocol.streaming() {
+		return status.Errorf(codes.Unimplemented, "%s is a streaming method and needs a streaming content type", s.method)
+	}
+	info := &grpc.StreamServerInfo{
+		FullMethod:     s.method,
+		IsClientStream: m.stream.ClientStreams,
+		IsServerStream: m.stream.ServerStreams,
+	}
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		return m.stream.Handler(srv, ss)
+	}
+	if h.streamInterceptor == nil {
+		return handler(m.srv, s)
+	}
+	return h.streamInterceptor(m.srv, s, info, handler)
+}
+
+// newStream picks the protocol and codec from the request's content type:
+//
+//	application/grpc-web[+proto|+json]       gRPC-Web
+//	application/grpc-web-text[+proto|+json]  gRPC-Web, base64 encoded
+//	application/connect+proto|+json          Connect streaming
+//	application/proto, application/json      Connect unary
+func newStream(w http.ResponseWriter, r *http.Request) (*stream, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, false
+	}
+	base, subtype, hasSubtype := strings.Cut(mediaType, "+")
+
+	s := &stream{
+		ctx:         r.Context(),
+		method:      r.URL.Path,
+		w:           w,
+		body:        r.Body,
+		contentType: mediaType,
+	}
+	switch base {
+	case "application/grpc-web":
+		s.protocol = &grpcWeb{}
+	case "application/grpc-web-text":
+		s.protocol = &grpcWeb{text: true}
+		s.body = base64.NewDecoder(base64.StdEncoding, r.Body)
+	case "application/connect":
+		if !hasSubtype {
+			return nil, false
+		}
+		s.protocol = &connectStream{compressed: isCompressed(r.Header.Get("Connect-Content-Encoding"))}
+	case "application/proto", "application/json":
+		if hasSubtype {
+			return nil, false
+		}
+		subtype = strings.TrimPrefix(base, "application/")
+		s.protocol = &connectUnary{compressed: isCompressed(r.Header.Get("Content-Encoding"))}
+	default:
+		return nil, false
+	}
+
+	if s.codec = codecFor(subtype); s.codec == nil {
+		return nil, false
+	}
+	if s.protocol.streaming() {
+		// HTTP/1.1 would otherwise close the request body once the first
+		// streamed response message is flushed
+		_ = http.NewResponseController(w).EnableFullDuplex()
+	}
+	return s, true
+}
+
+// codecFor returns the codec for a content subtype, protobuf by default
+func codecFor(subtype string) encoding.Codec {
+	switch subtype {
+	case "", "proto":
+		return protoCodec{}
+	case "json":
+		return gateway.Codec{}
+	default:
+		return nil
+	}
+}
+
+func isCompressed(contentEncoding string) bool {
+	return contentEncoding != "" && contentEncoding != "identity"
+}
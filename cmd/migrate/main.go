@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"grpc-exmpl/internal/config"
+	"grpc-exmpl/pkg/database"
+	"grpc-exmpl/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: migrate [-config path] [-dir path] <command> [args]
+
+Commands:
+  up            Apply all pending migrations
+  down N        Roll back the N most recent migrations
+  status        Show applied and pending migrations
+  goto V        Migrate up or down to version V (0 rolls back everything)
+  create NAME   Create a new empty up/down migration pair in -dir
+`
+
+func main() {
+	configPath := flag.String("config", "configs/app.yaml", "path to the configuration file")
+	migrationsDir := flag.String("dir", "pkg/database/migrations", "migrations directory used by create")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// create only touches the filesystem
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		paths, err := database.CreateMigration(*migrationsDir, args[1])
+		if err != nil {
+			logrus.Fatalf("Failed to create migration: %v", err)
+		}
+		for _, p := range paths {
+			fmt.Println("Created", p)
+		}
+		return
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		logrus.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize logger
+	if err := logger.InitLogger(cfg.Log.Level, "text"); err != nil {
+		logrus.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	// Connect to the database
+	db, err := database.NewPostgresConnection(&database.Config{
+		Host:         cfg.Database.Host,
+		Port:         cfg.Database.Port,
+		User:         cfg.Database.User,
+		Password:     cfg.Database.Password,
+		Database:     cfg.Database.Database,
+		SSLMode:      cfg.Database.SSLMode,
+		MaxOpenConns: cfg.Database.MaxOpenConns,
+		MaxIdleConns: cfg.Database.MaxIdleConns,
+		MaxLifetime:  cfg.Database.MaxLifetime,
+	})
+	if err != nil {
+		logrus.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.CloseConnection(db)
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		logrus.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	if err := run(context.Background(), migrator, args); err != nil {
+		logrus.Fatalf("Migration failed: %v", err)
+	}
+}
+
+func run(ctx context.Context, m *database.Migrator, args []string) error {
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: migrate down N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid count %q", args[1])
+		}
+		return m.Down(ctx, n)
+	case "goto":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: migrate goto V")
+		}
+		v, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return m.Goto(ctx, v)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02T15:04:05Z07:00")
+			}
+			fmt.Printf("%04d  %-40s %s\n", st.Version, st.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -9,10 +10,13 @@ import (
 
 	"grpc-exmpl/api/grpc"
 	"grpc-exmpl/internal/config"
+	"grpc-exmpl/internal/publisher"
 	"grpc-exmpl/internal/repository"
 	"grpc-exmpl/internal/service"
+	"grpc-exmpl/internal/worker"
 	"grpc-exmpl/pkg/database"
 	"grpc-exmpl/pkg/logger"
+	"grpc-exmpl/pkg/utils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -61,18 +65,99 @@ func main() {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	productRepo := repository.NewProductRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	reservationRepo := repository.NewReservationRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	productEventRepo := repository.NewProductEventRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(db)
+
+	// Initialize the transaction manager shared by multi-repository services
+	isolation, err := repository.ParseIsolationLevel(cfg.Database.TxIsolation)
+	if err != nil {
+		logrus.Fatalf("Invalid database.tx_isolation: %v", err)
+	}
+	txManager := repository.NewTxManager(db, repository.TxConfig{
+		Isolation:  isolation,
+		MaxRetries: cfg.Database.TxMaxRetries,
+		RetryDelay: cfg.Database.TxRetryDelay,
+	})
+
+	// Load JWT signing keys
+	jwtKeys, err := loadJWTKeys(cfg.JWT)
+	if err != nil {
+		logrus.Fatalf("Failed to load JWT keys: %v", err)
+	}
 
 	// Initialize services
-	userService := service.NewUserService(userRepo, cfg.JWT.Secret)
-	productService := service.NewProductService(productRepo)
+	userService := service.NewUserService(txManager, userRepo, refreshTokenRepo, outboxRepo, service.TokenConfig{
+		Keys:       jwtKeys,
+		AccessTTL:  cfg.JWT.Expiration,
+		RefreshTTL: cfg.JWT.RefreshExpiration,
+	})
+	productService := service.NewProductService(txManager, productRepo, reservationRepo, outboxRepo, service.InventoryConfig{
+		ReservationTTL:    cfg.Inventory.ReservationTTL,
+		MaxReservationTTL: cfg.Inventory.MaxReservationTTL,
+	}, service.BatchConfig{
+		MaxSize:        cfg.Products.MaxBatchSize,
+		ImportAckEvery: cfg.Products.ImportAckEvery,
+	})
+	productFeed := service.NewProductFeed()
+	productWatcher := service.NewProductWatcher(productEventRepo, productRepo, productFeed, service.WatchConfig{
+		PollInterval:     cfg.Watch.PollInterval,
+		HeldBackInterval: cfg.Watch.HeldBackInterval,
+	})
+	orderService := service.NewOrderService(txManager, orderRepo, productRepo, outboxRepo)
+	categoryService := service.NewCategoryService(categoryRepo)
+	webhookService := service.NewWebhookService(webhookRepo, webhookDeliveryRepo, productRepo, service.WebhookConfig{
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+	})
 
 	// Initialize gRPC server
-	server := grpc.NewServer(userService, productService, cfg.Server.Port)
+	server := grpc.NewServer(userService, productService, productWatcher, orderService, categoryService, webhookService, cfg.Server, cfg.Auth)
 
 	// Setup graceful shutdown
-	_, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Start background workers
+	go worker.NewReservationSweeper(productService, cfg.Inventory.SweepInterval).Run(ctx)
+	go worker.NewPurger(productRepo, userRepo, cfg.Purge.Retention, cfg.Purge.Interval).Run(ctx)
+
+	// Relay product change notifications to WatchProducts streams
+	productListener, err := database.NewListener(dbConfig, "product_events")
+	if err != nil {
+		logrus.Fatalf("Failed to listen for product events: %v", err)
+	}
+	go worker.NewProductFeedRelay(productListener, productFeed, productEventRepo, cfg.Watch.EventRetention, cfg.Watch.PruneInterval).Run(ctx)
+
+	// Publish domain events recorded in the outbox, queueing webhook deliveries alongside
+	eventPublisher, err := newPublisher(cfg.Outbox)
+	if err != nil {
+		logrus.Fatalf("Failed to create event publisher: %v", err)
+	}
+	go worker.NewOutboxRelay(outboxRepo, publisher.NewFanout(eventPublisher, webhookService), worker.OutboxRelayConfig{
+		Interval:   cfg.Outbox.PollInterval,
+		BatchSize:  cfg.Outbox.BatchSize,
+		Lease:      cfg.Outbox.Lease,
+		MinBackoff: cfg.Outbox.MinBackoff,
+		MaxBackoff: cfg.Outbox.MaxBackoff,
+	}).Run(ctx)
+
+	// Send queued webhook deliveries to seller endpoints
+	go worker.NewWebhookDeliverer(webhookDeliveryRepo, worker.WebhookDelivererConfig{
+		Interval:             cfg.Webhooks.PollInterval,
+		BatchSize:            cfg.Webhooks.BatchSize,
+		Lease:                cfg.Webhooks.Lease,
+		Timeout:              cfg.Webhooks.Timeout,
+		MinBackoff:           cfg.Webhooks.MinBackoff,
+		MaxBackoff:           cfg.Webhooks.MaxBackoff,
+		MaxAttempts:          cfg.Webhooks.MaxAttempts,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+	}).Run(ctx)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -92,3 +177,40 @@ func main() {
 
 	logrus.Info("Server shutdown complete")
 }
+
+// loadJWTKeys builds the token key set, falling back to the shared secret
+func loadJWTKeys(cfg config.JWTConfig) (*utils.KeySet, error) {
+	if len(cfg.Keys) == 0 {
+		return utils.NewHMACKeySet(cfg.Secret), nil
+	}
+
+	keys := make([]utils.KeyConfig, 0, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		keys = append(keys, utils.KeyConfig{
+			ID:             k.ID,
+			PrivateKeyFile: k.PrivateKeyFile,
+			PublicKeyFile:  k.PublicKeyFile,
+			Active:         k.Active,
+		})
+	}
+	return utils.LoadKeySet(keys)
+}
+
+// newPublisher builds the outbox publisher selected by cfg.Publisher
+func newPublisher(cfg config.OutboxConfig) (publisher.Publisher, error) {
+	switch cfg.Publisher {
+	case "", "stdout":
+		return publisher.NewWriterPublisher(os.Stdout), nil
+	case "file":
+		// The file stays open for the life of the process
+		p, _, err := publisher.NewFilePublisher(cfg.FilePath)
+		return p, err
+	case "webhook":
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("outbox.webhook_url is required for the webhook publisher")
+		}
+		return publisher.NewWebhookPublisher(cfg.WebhookURL, cfg.WebhookTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+	}
+}
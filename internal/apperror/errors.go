@@ -0,0 +1,155 @@
+package apperror
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kind classifies a domain error independently of the transport.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindAlreadyExists
+	KindValidation
+	KindUnauthenticated
+	KindPermissionDenied
+	KindConflict
+)
+
+// FieldViolation describes why a single request field was rejected.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error is a domain error carrying enough structure for the transport
+// layer to pick a status code and build machine-readable details.
+//
+// Message is safe to show to clients; Err is the underlying cause and is
+// only ever logged.
+type Error struct {
+	Kind       Kind
+	Reason     string
+	Message    string
+	Violations []FieldViolation
+	Metadata   map[string]string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound reports that the named resource does not exist
+func NotFound(resource string) *Error {
+	return &Error{
+		Kind:     KindNotFound,
+		Reason:   reasonCode(resource, "NOT_FOUND"),
+		Message:  resource + " not found",
+		Metadata: map[string]string{"resource": resource},
+	}
+}
+
+// AlreadyExists reports a uniqueness conflict on field of resource
+func AlreadyExists(resource, field string) *Error {
+	return &Error{
+		Kind:     KindAlreadyExists,
+		Reason:   reasonCode(resource, field, "TAKEN"),
+		Message:  field + " already exists",
+		Metadata: map[string]string{"resource": resource, "field": field},
+	}
+}
+
+// Invalid reports a single rejected request field
+func Invalid(field, description string) *Error {
+	return Validation(FieldViolation{Field: field, Description: description})
+}
+
+// Validation reports one or more rejected request fields
+func Validation(violations ...FieldViolation) *Error {
+	msgs := make([]string, 0, len(violations))
+	for _, v := range violations {
+		msgs = append(msgs, v.Description)
+	}
+	return &Error{
+		Kind:       KindValidation,
+		Reason:     "INVALID_ARGUMENT",
+		Message:    strings.Join(msgs, "; "),
+		Violations: violations,
+	}
+}
+
+// Unauthenticated reports missing or invalid credentials
+func Unauthenticated(message string) *Error {
+	return &Error{Kind: KindUnauthenticated, Reason: "UNAUTHENTICATED", Message: message}
+}
+
+// PermissionDenied reports an authenticated caller acting outside its rights
+func PermissionDenied(message string) *Error {
+	return &Error{Kind: KindPermissionDenied, Reason: "PERMISSION_DENIED", Message: message}
+}
+
+// Conflict reports that the resource is not in a state allowing the operation
+func Conflict(reason, message string) *Error {
+	return &Error{Kind: KindConflict, Reason: reason, Message: message}
+}
+
+// Internal wraps an unexpected failure; the cause never reaches clients
+func Internal(message string, err error) *Error {
+	return &Error{Kind: KindInternal, Reason: "INTERNAL", Message: message, Err: err}
+}
+
+// WithMetadata returns a copy of e with a key/value pair surfaced to clients
+// in error details. Copying keeps shared sentinel errors untouched.
+func (e *Error) WithMetadata(key, value string) *Error {
+	cp := *e
+	cp.Metadata = make(map[string]string, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		cp.Metadata[k] = v
+	}
+	cp.Metadata[key] = value
+	return &cp
+}
+
+// Is matches domain errors of the same kind and reason, so errors.Is
+// against a sentinel still holds for copies carrying extra metadata.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Reason == e.Reason
+}
+
+// As returns the domain error in err's chain, if any
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// KindOf returns the kind of the domain error in err's chain, or KindInternal
+func KindOf(err error) Kind {
+	if appErr, ok := As(err); ok {
+		return appErr.Kind
+	}
+	return KindInternal
+}
+
+// IsNotFound reports whether err is a NotFound domain error
+func IsNotFound(err error) bool {
+	return err != nil && KindOf(err) == KindNotFound
+}
+
+// reasonCode builds an UPPER_SNAKE_CASE ErrorInfo reason from its parts
+func reasonCode(parts ...string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.Join(parts, "_"), " ", "_"))
+}
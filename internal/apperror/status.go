@@ -0,0 +1,69 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain identifies this service in google.rpc.ErrorInfo details
+const errorDomain = "grpc-exmpl"
+
+var kindCodes = map[Kind]codes.Code{
+	KindInternal:         codes.Internal,
+	KindNotFound:         codes.NotFound,
+	KindAlreadyExists:    codes.AlreadyExists,
+	KindValidation:       codes.InvalidArgument,
+	KindUnauthenticated:  codes.Unauthenticated,
+	KindPermissionDenied: codes.PermissionDenied,
+	KindConflict:         codes.FailedPrecondition,
+}
+
+// ToStatus maps a service error to a gRPC status with BadRequest and
+// ErrorInfo details. Anything that is not a client-facing domain error is
+// logged and reported as a bare Internal so SQL and driver messages never
+// leave the server.
+func ToStatus(err error) *status.Status {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, "deadline exceeded")
+	}
+
+	appErr, ok := As(err)
+	if !ok || appErr.Kind == KindInternal {
+		logrus.WithError(err).Error("Internal error")
+		return status.New(codes.Internal, "internal error")
+	}
+
+	st := status.New(kindCodes[appErr.Kind], appErr.Message)
+
+	info := &errdetails.ErrorInfo{
+		Reason:   appErr.Reason,
+		Domain:   errorDomain,
+		Metadata: appErr.Metadata,
+	}
+	if len(appErr.Violations) == 0 {
+		if withDetails, err := st.WithDetails(info); err == nil {
+			st = withDetails
+		}
+		return st
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, v := range appErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+	if withDetails, err := st.WithDetails(info, badRequest); err == nil {
+		st = withDetails
+	}
+	return st
+}
@@ -8,10 +8,17 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Inventory InventoryConfig `mapstructure:"inventory"`
+	Purge     PurgeConfig     `mapstructure:"purge"`
+	Products  ProductsConfig  `mapstructure:"products"`
+	Watch     WatchConfig     `mapstructure:"watch"`
+	Outbox    OutboxConfig    `mapstructure:"outbox"`
+	Webhooks  WebhooksConfig  `mapstructure:"webhooks"`
+	Log       LogConfig       `mapstructure:"log"`
 }
 
 type ServerConfig struct {
@@ -20,6 +27,48 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// RequestTimeout bounds unary calls that arrive without a client deadline
+	RequestTimeout time.Duration   `mapstructure:"request_timeout"`
+	MethodTimeouts []MethodTimeout `mapstructure:"method_timeouts"`
+	// HTTPPort serves the REST/JSON gateway; empty disables it
+	HTTPPort string `mapstructure:"http_port"`
+	// CORS applies to the gRPC-Web, Connect and REST endpoints
+	CORS CORSConfig `mapstructure:"cors"`
+	// TLS secures the gRPC listener and the REST gateway
+	TLS TLSConfig `mapstructure:"tls"`
+}
+
+// TLSConfig points at the server's PEM files. The files are watched and
+// reloaded when they change, so certificates can be rotated in place.
+type TLSConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ClientCAFile is the CA bundle client certificates are verified
+	// against; empty disables client certificates
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuth is "request", which verifies a certificate when one is
+	// presented, or "require", which rejects clients without one
+	ClientAuth string `mapstructure:"client_auth"`
+}
+
+// CORSConfig lists the browser origins allowed to call the server.
+type CORSConfig struct {
+	// AllowedOrigins are exact origins such as "https://app.example.com";
+	// "*" allows any. Empty disables cross-origin access.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// AllowedHeaders and ExposedHeaders extend the gRPC-Web and Connect
+	// protocol headers, e.g. for custom metadata
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`
+	ExposedHeaders   []string      `mapstructure:"exposed_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+// MethodTimeout overrides the default deadline for one gRPC method.
+type MethodTimeout struct {
+	Method  string        `mapstructure:"method"`
+	Timeout time.Duration `mapstructure:"timeout"`
 }
 
 type DatabaseConfig struct {
@@ -32,11 +81,127 @@ type DatabaseConfig struct {
 	MaxOpenConns int           `mapstructure:"max_open_conns"`
 	MaxIdleConns int           `mapstructure:"max_idle_conns"`
 	MaxLifetime  time.Duration `mapstructure:"max_lifetime"`
+	// TxIsolation is the isolation level of service-level transactions:
+	// "read_committed", "repeatable_read" or "serializable"
+	TxIsolation  string        `mapstructure:"tx_isolation"`
+	TxMaxRetries int           `mapstructure:"tx_max_retries"`
+	TxRetryDelay time.Duration `mapstructure:"tx_retry_delay"`
 }
 
+// JWTConfig controls token signing.
+//
+// When Keys is empty tokens are signed with HS256 using Secret. Otherwise
+// the active key signs (RS256 or EdDSA, inferred from the key type) and
+// every listed key is accepted for verification by its kid.
 type JWTConfig struct {
-	Secret     string        `mapstructure:"secret"`
-	Expiration time.Duration `mapstructure:"expiration"`
+	Secret            string         `mapstructure:"secret"`
+	Expiration        time.Duration  `mapstructure:"expiration"`
+	RefreshExpiration time.Duration  `mapstructure:"refresh_expiration"`
+	Keys              []JWTKeyConfig `mapstructure:"keys"`
+}
+
+// JWTKeyConfig points at the PEM files for one signing key.
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"id"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+	Active         bool   `mapstructure:"active"`
+}
+
+// AuthConfig declares which RPCs require authentication and which roles.
+//
+// Methods without an entry in Policies fall back to DefaultAccess.
+type AuthConfig struct {
+	DefaultAccess string         `mapstructure:"default_access"`
+	Policies      []MethodPolicy `mapstructure:"policies"`
+	// Services lets internal callers authenticate with a verified client
+	// certificate instead of a JWT
+	Services []ServiceIdentity `mapstructure:"services"`
+}
+
+// ServiceIdentity grants roles to the holder of a client certificate.
+//
+// Name is matched against the certificate's URI SANs, DNS SANs and
+// subject common name, e.g. "spiffe://example.org/orders-worker".
+type ServiceIdentity struct {
+	Name  string   `mapstructure:"name"`
+	Roles []string `mapstructure:"roles"`
+}
+
+// MethodPolicy is the access rule for a single fully-qualified gRPC method.
+//
+// Access is "public", "authenticated" or "roles"; with "roles" the caller
+// must hold at least one of Roles.
+type MethodPolicy struct {
+	Method string   `mapstructure:"method"`
+	Access string   `mapstructure:"access"`
+	Roles  []string `mapstructure:"roles"`
+}
+
+// InventoryConfig controls stock reservations and their expiry sweeper.
+type InventoryConfig struct {
+	ReservationTTL    time.Duration `mapstructure:"reservation_ttl"`
+	MaxReservationTTL time.Duration `mapstructure:"max_reservation_ttl"`
+	SweepInterval     time.Duration `mapstructure:"sweep_interval"`
+}
+
+// PurgeConfig controls how long soft-deleted rows stay restorable.
+type PurgeConfig struct {
+	Retention time.Duration `mapstructure:"retention"`
+	Interval  time.Duration `mapstructure:"interval"`
+}
+
+// ProductsConfig tunes product operations.
+type ProductsConfig struct {
+	// MaxBatchSize caps the items in one BatchCreate/Get/DeleteProducts call
+	MaxBatchSize int `mapstructure:"max_batch_size"`
+	// ImportAckEvery is how many streamed items ImportProducts creates
+	// between progress acknowledgements
+	ImportAckEvery int `mapstructure:"import_ack_every"`
+}
+
+// WatchConfig controls the WatchProducts change feed.
+type WatchConfig struct {
+	// EventRetention is how long events, and so resume tokens, are kept
+	EventRetention time.Duration `mapstructure:"event_retention"`
+	// PollInterval rereads the feed when no notification arrives
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// HeldBackInterval rereads the feed while committed events wait on an
+	// older transaction that is still running
+	HeldBackInterval time.Duration `mapstructure:"held_back_interval"`
+	PruneInterval    time.Duration `mapstructure:"prune_interval"`
+}
+
+// OutboxConfig controls how domain events leave the outbox table.
+type OutboxConfig struct {
+	// Publisher is one of "stdout", "file" or "webhook"
+	Publisher      string        `mapstructure:"publisher"`
+	FilePath       string        `mapstructure:"file_path"`
+	WebhookURL     string        `mapstructure:"webhook_url"`
+	WebhookTimeout time.Duration `mapstructure:"webhook_timeout"`
+	PollInterval   time.Duration `mapstructure:"poll_interval"`
+	BatchSize      int           `mapstructure:"batch_size"`
+	// Lease is how long a claimed event is hidden from other relays
+	Lease time.Duration `mapstructure:"lease"`
+	// Failed events are retried after MinBackoff, doubling up to MaxBackoff
+	MinBackoff time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+// WebhooksConfig controls delivery to seller webhooks.
+type WebhooksConfig struct {
+	// Timeout bounds one POST; Lease must be longer
+	Timeout      time.Duration `mapstructure:"timeout"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	Lease        time.Duration `mapstructure:"lease"`
+	MinBackoff   time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	// MaxAttempts failed attempts leave a delivery dead until replayed
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// AllowPrivateNetworks lets webhooks target loopback, private and
+	// link-local addresses; only for local development
+	AllowPrivateNetworks bool `mapstructure:"allow_private_networks"`
 }
 
 type LogConfig struct {
@@ -74,10 +239,14 @@ func LoadConfig(configPath string) (*Config, error) {
 func setDefaults() {
 	// Server defaults
 	viper.SetDefault("server.port", "8080")
+	viper.SetDefault("server.http_port", "8081")
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.read_timeout", "30s")
 	viper.SetDefault("server.write_timeout", "30s")
 	viper.SetDefault("server.shutdown_timeout", "5s")
+	viper.SetDefault("server.request_timeout", "10s")
+	viper.SetDefault("server.cors.max_age", "2h")
+	viper.SetDefault("server.tls.client_auth", "request")
 
 	// Database defaults
 	viper.SetDefault("database.host", "localhost")
@@ -89,10 +258,62 @@ func setDefaults() {
 	viper.SetDefault("database.max_open_conns", 25)
 	viper.SetDefault("database.max_idle_conns", 5)
 	viper.SetDefault("database.max_lifetime", "5m")
+	viper.SetDefault("database.tx_isolation", "read_committed")
+	viper.SetDefault("database.tx_max_retries", 3)
+	viper.SetDefault("database.tx_retry_delay", "50ms")
 
 	// JWT defaults
 	viper.SetDefault("jwt.secret", "your-secret-key")
-	viper.SetDefault("jwt.expiration", "24h")
+	viper.SetDefault("jwt.expiration", "15m")
+	viper.SetDefault("jwt.refresh_expiration", "720h")
+
+	// Auth defaults
+	viper.SetDefault("auth.default_access", "authenticated")
+	viper.SetDefault("auth.policies", []map[string]interface{}{
+		{"method": "/user.UserService/Register", "access": "public"},
+		{"method": "/user.UserService/Login", "access": "public"},
+		{"method": "/user.UserService/RefreshToken", "access": "public"},
+		{"method": "/user.UserService/Logout", "access": "public"},
+		{"method": "/user.UserService/GetPublicKeys", "access": "public"},
+	})
+
+	// Inventory defaults
+	viper.SetDefault("inventory.reservation_ttl", "15m")
+	viper.SetDefault("inventory.max_reservation_ttl", "24h")
+	viper.SetDefault("inventory.sweep_interval", "1m")
+
+	// Purge defaults
+	viper.SetDefault("purge.retention", "720h")
+	viper.SetDefault("purge.interval", "1h")
+
+	// Products defaults
+	viper.SetDefault("products.max_batch_size", 500)
+	viper.SetDefault("products.import_ack_every", 100)
+
+	// Watch defaults
+	viper.SetDefault("watch.event_retention", "24h")
+	viper.SetDefault("watch.poll_interval", "30s")
+	viper.SetDefault("watch.held_back_interval", "250ms")
+	viper.SetDefault("watch.prune_interval", "1h")
+
+	// Outbox defaults
+	viper.SetDefault("outbox.publisher", "stdout")
+	viper.SetDefault("outbox.webhook_timeout", "10s")
+	viper.SetDefault("outbox.poll_interval", "1s")
+	viper.SetDefault("outbox.batch_size", 100)
+	viper.SetDefault("outbox.lease", "1m")
+	viper.SetDefault("outbox.min_backoff", "1s")
+	viper.SetDefault("outbox.max_backoff", "10m")
+
+	// Webhook defaults
+	viper.SetDefault("webhooks.timeout", "10s")
+	viper.SetDefault("webhooks.poll_interval", "1s")
+	viper.SetDefault("webhooks.batch_size", 20)
+	viper.SetDefault("webhooks.lease", "1m")
+	viper.SetDefault("webhooks.min_backoff", "30s")
+	viper.SetDefault("webhooks.max_backoff", "6h")
+	viper.SetDefault("webhooks.max_attempts", 10)
+	viper.SetDefault("webhooks.allow_private_networks", false)
 
 	// Log defaults
 	viper.SetDefault("log.level", "info")
@@ -0,0 +1,137 @@
+package grpc
+
+import (
+	"context"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/middleware"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+	pb "grpc-exmpl/proto/category"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type CategoryHandler struct {
+	pb.UnimplementedCategoryServiceServer
+	service service.CategoryService
+}
+
+func NewCategoryHandler(svc service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{service: svc}
+}
+
+// CreateCategory handles gRPC request to add a category to the tree
+func (h *CategoryHandler) CreateCategory(ctx context.Context, req *pb.CreateCategoryRequest) (*pb.CreateCategoryResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.CreateCategoryResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	category, err := h.service.CreateCategory(ctx, caller, &model.CreateCategoryRequest{
+		ParentID: req.ParentId,
+		Name:     req.Name,
+		Slug:     req.Slug,
+	})
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.CreateCategoryResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.CreateCategoryResponse{
+		Success:  true,
+		Message:  "Category created successfully",
+		Category: convertCategoryToProto(category),
+	}, nil
+}
+
+// GetCategory handles gRPC request to get a category by ID
+func (h *CategoryHandler) GetCategory(ctx context.Context, req *pb.GetCategoryRequest) (*pb.GetCategoryResponse, error) {
+	category, err := h.service.GetCategory(ctx, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.GetCategoryResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.GetCategoryResponse{
+		Success:  true,
+		Message:  "OK",
+		Category: convertCategoryToProto(category),
+	}, nil
+}
+
+// ListCategories handles gRPC request to list the category tree or a subtree
+func (h *CategoryHandler) ListCategories(ctx context.Context, req *pb.ListCategoriesRequest) (*pb.ListCategoriesResponse, error) {
+	categories, err := h.service.ListCategories(ctx, &model.ListCategoriesRequest{RootID: req.RootId})
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ListCategoriesResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	categoryProtos := make([]*pb.CategoryData, 0, len(categories))
+	for _, c := range categories {
+		categoryProtos = append(categoryProtos, convertCategoryToProto(c))
+	}
+
+	return &pb.ListCategoriesResponse{
+		Success:    true,
+		Message:    "OK",
+		Categories: categoryProtos,
+	}, nil
+}
+
+// UpdateCategory handles gRPC request to rename or move a category
+func (h *CategoryHandler) UpdateCategory(ctx context.Context, req *pb.UpdateCategoryRequest) (*pb.UpdateCategoryResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.UpdateCategoryResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	category, err := h.service.UpdateCategory(ctx, caller, &model.UpdateCategoryRequest{
+		ID:       req.Id,
+		ParentID: req.ParentId,
+		Name:     req.Name,
+		Slug:     req.Slug,
+	})
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.UpdateCategoryResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.UpdateCategoryResponse{
+		Success:  true,
+		Message:  "Category updated successfully",
+		Category: convertCategoryToProto(category),
+	}, nil
+}
+
+// DeleteCategory handles gRPC request to delete a category
+func (h *CategoryHandler) DeleteCategory(ctx context.Context, req *pb.DeleteCategoryRequest) (*pb.DeleteCategoryResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.DeleteCategoryResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	if err := h.service.DeleteCategory(ctx, caller, req.Id); err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.DeleteCategoryResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.DeleteCategoryResponse{
+		Success: true,
+		Message: "Category deleted successfully",
+	}, nil
+}
+
+// convertCategoryToProto maps internal Category model to gRPC proto message
+func convertCategoryToProto(c *model.Category) *pb.CategoryData {
+	return &pb.CategoryData{
+		Id:        c.ID,
+		ParentId:  c.ParentID,
+		Name:      c.Name,
+		Slug:      c.Slug,
+		CreatedAt: c.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt: c.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
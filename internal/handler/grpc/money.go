@@ -0,0 +1,58 @@
+package grpc
+
+import (
+	"math"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	moneypb "grpc-exmpl/proto/money"
+)
+
+// nanosPerMinorUnit is the number of proto nanos in one cent
+const nanosPerMinorUnit = 1_000_000_000 / model.MinorUnitsPerMajor
+
+// moneyFromProto converts a proto amount to minor units, rejecting negative
+// amounts and fractions of a cent. A nil amount converts to zero.
+func moneyFromProto(field string, m *moneypb.Money) (model.Money, error) {
+	if m == nil {
+		return model.Money{}, nil
+	}
+	if m.Units < 0 || m.Nanos < 0 {
+		return model.Money{}, apperror.Invalid(field, field+" cannot be negative")
+	}
+	if m.Nanos >= 1_000_000_000 {
+		return model.Money{}, apperror.Invalid(field+".nanos", "nanos must be less than one unit")
+	}
+	if m.Nanos%nanosPerMinorUnit != 0 {
+		return model.Money{}, apperror.Invalid(field, field+" cannot have fractions of a cent")
+	}
+	if m.Units > math.MaxInt64/model.MinorUnitsPerMajor-1 {
+		return model.Money{}, apperror.Invalid(field+".units", field+" is too large")
+	}
+
+	return model.Money{
+		Amount:   m.Units*model.MinorUnitsPerMajor + int64(m.Nanos/nanosPerMinorUnit),
+		Currency: m.CurrencyCode,
+	}, nil
+}
+
+// optionalMoneyFromProto converts an optional proto amount, keeping nil as nil
+func optionalMoneyFromProto(field string, m *moneypb.Money) (*model.Money, error) {
+	if m == nil {
+		return nil, nil
+	}
+	v, err := moneyFromProto(field, m)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// moneyToProto maps a model amount to its proto message
+func moneyToProto(m model.Money) *moneypb.Money {
+	return &moneypb.Money{
+		CurrencyCode: m.Currency,
+		Units:        m.Amount / model.MinorUnitsPerMajor,
+		Nanos:        int32(m.Amount%model.MinorUnitsPerMajor) * nanosPerMinorUnit,
+	}
+}
@@ -0,0 +1,146 @@
+package grpc
+
+import (
+	"context"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/middleware"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+	pb "grpc-exmpl/proto/order"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type OrderHandler struct {
+	pb.UnimplementedOrderServiceServer
+	service service.OrderService
+}
+
+func NewOrderHandler(svc service.OrderService) *OrderHandler {
+	return &OrderHandler{service: svc}
+}
+
+// CreateOrder handles gRPC request to check out products for the caller
+func (h *OrderHandler) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*pb.CreateOrderResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.CreateOrderResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	orderReq := &model.CreateOrderRequest{}
+	for _, line := range req.Items {
+		orderReq.Items = append(orderReq.Items, model.OrderLine{
+			ProductID: line.ProductId,
+			Quantity:  int(line.Quantity),
+		})
+	}
+
+	order, err := h.service.CreateOrder(ctx, caller, orderReq)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.CreateOrderResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.CreateOrderResponse{
+		Success: true,
+		Message: "Order placed successfully",
+		Order:   convertOrderToProto(order),
+	}, nil
+}
+
+// GetOrder handles gRPC request to get an order by ID
+func (h *OrderHandler) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.GetOrderResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	order, err := h.service.GetOrder(ctx, caller, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.GetOrderResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.GetOrderResponse{
+		Success: true,
+		Message: "OK",
+		Order:   convertOrderToProto(order),
+	}, nil
+}
+
+// ListMyOrders handles gRPC request to list the caller's orders
+func (h *OrderHandler) ListMyOrders(ctx context.Context, req *pb.ListMyOrdersRequest) (*pb.ListMyOrdersResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.ListMyOrdersResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	listReq := &model.ListOrdersRequest{
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+	}
+
+	page, err := h.service.ListMyOrders(ctx, caller, listReq)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ListMyOrdersResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	var orderProtos []*pb.OrderData
+	for _, o := range page.Orders {
+		orderProtos = append(orderProtos, convertOrderToProto(o))
+	}
+
+	return &pb.ListMyOrdersResponse{
+		Success:       true,
+		Message:       "OK",
+		Orders:        orderProtos,
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+// CancelOrder handles gRPC request to cancel an order and restore its stock
+func (h *OrderHandler) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.CancelOrderResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	order, err := h.service.CancelOrder(ctx, caller, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.CancelOrderResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.CancelOrderResponse{
+		Success: true,
+		Message: "Order cancelled",
+		Order:   convertOrderToProto(order),
+	}, nil
+}
+
+// convertOrderToProto maps internal Order model to gRPC proto message
+func convertOrderToProto(o *model.Order) *pb.OrderData {
+	items := make([]*pb.OrderItemData, 0, len(o.Items))
+	for _, item := range o.Items {
+		items = append(items, &pb.OrderItemData{
+			Id:          item.ID,
+			ProductId:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    int32(item.Quantity),
+			UnitPrice:   moneyToProto(item.UnitPrice),
+		})
+	}
+
+	return &pb.OrderData{
+		Id:          o.ID,
+		UserId:      o.UserID,
+		Status:      string(o.Status),
+		TotalAmount: moneyToProto(o.TotalAmount),
+		Items:       items,
+		CreatedAt:   o.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:   o.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
@@ -2,11 +2,17 @@ package grpc
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"time"
 
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/middleware"
 	"grpc-exmpl/internal/model"
 	"grpc-exmpl/internal/service"
 	pb "grpc-exmpl/proto/product"
 
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -14,25 +20,50 @@ import (
 type ProductHandler struct {
 	pb.UnimplementedProductServiceServer
 	service service.ProductService
+	watcher service.ProductWatcher
 }
 
-func NewProductHandler(svc service.ProductService) *ProductHandler {
-	return &ProductHandler{service: svc}
+func NewProductHandler(svc service.ProductService, watcher service.ProductWatcher) *ProductHandler {
+	return &ProductHandler{service: svc, watcher: watcher}
 }
 
 // CreateProduct handles gRPC request to create a new product
+//
+// The owner defaults to the authenticated caller; only admins may name another user_id.
 func (h *ProductHandler) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.CreateProductResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.CreateProductResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	ownerID := caller.UserID
+	if req.UserId != 0 && req.UserId != caller.UserID {
+		if !caller.IsAdmin() {
+			msg := "cannot create products for another user"
+			return &pb.CreateProductResponse{Success: false, Message: msg}, status.Error(codes.PermissionDenied, msg)
+		}
+		ownerID = req.UserId
+	}
+
+	price, err := moneyFromProto("price", req.Price)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.CreateProductResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
 	productReq := &model.CreateProductRequest{
 		Name:        req.Name,
 		Description: req.Description,
-		Price:       req.Price,
+		Price:       price,
 		Stock:       int(req.Stock),
-		UserID:      req.UserId,
+		UserID:      ownerID,
+		CategoryID:  req.CategoryId,
+		Tags:        req.Tags,
 	}
 
-	product, err := h.service.CreateProduct(productReq)
+	product, err := h.service.CreateProduct(ctx, productReq)
 	if err != nil {
-		return &pb.CreateProductResponse{Success: false, Message: err.Error()}, status.Error(codes.InvalidArgument, err.Error())
+		st := apperror.ToStatus(err)
+		return &pb.CreateProductResponse{Success: false, Message: st.Message()}, st.Err()
 	}
 
 	return &pb.CreateProductResponse{
@@ -44,9 +75,10 @@ func (h *ProductHandler) CreateProduct(ctx context.Context, req *pb.CreateProduc
 
 // GetProduct handles gRPC request to get a product by ID
 func (h *ProductHandler) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.GetProductResponse, error) {
-	product, err := h.service.GetProductByID(req.Id)
+	product, err := h.service.GetProductByID(ctx, req.Id)
 	if err != nil {
-		return &pb.GetProductResponse{Success: false, Message: err.Error()}, status.Error(codes.NotFound, err.Error())
+		st := apperror.ToStatus(err)
+		return &pb.GetProductResponse{Success: false, Message: st.Message()}, st.Err()
 	}
 
 	return &pb.GetProductResponse{
@@ -58,36 +90,116 @@ func (h *ProductHandler) GetProduct(ctx context.Context, req *pb.GetProductReque
 
 // ListProducts handles gRPC request to list products by user
 func (h *ProductHandler) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
-	products, err := h.service.ListProductsByUser(req.UserId)
+	minPrice, err := optionalMoneyFromProto("min_price", req.MinPrice)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ListProductsResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+	maxPrice, err := optionalMoneyFromProto("max_price", req.MaxPrice)
 	if err != nil {
-		return &pb.ListProductsResponse{Success: false, Message: err.Error()}, status.Error(codes.Internal, err.Error())
+		st := apperror.ToStatus(err)
+		return &pb.ListProductsResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	listReq := &model.ListProductsRequest{
+		UserID:         req.UserId,
+		PageSize:       int(req.PageSize),
+		PageToken:      req.PageToken,
+		MinPrice:       minPrice,
+		MaxPrice:       maxPrice,
+		MinStock:       int32PtrToIntPtr(req.MinStock),
+		MaxStock:       int32PtrToIntPtr(req.MaxStock),
+		NameContains:   req.NameContains,
+		CategoryID:     req.CategoryId,
+		IncludeDeleted: req.IncludeDeleted,
+		OrderBy:        req.OrderBy,
+	}
+
+	// Listing needs no caller unless deleted products are requested
+	caller, _ := middleware.GetCallerFromContext(ctx)
+	page, err := h.service.ListProductsByUser(ctx, caller, listReq)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ListProductsResponse{Success: false, Message: st.Message()}, st.Err()
 	}
 
 	var productProtos []*pb.ProductData
-	for _, p := range products {
+	for _, p := range page.Products {
 		productProtos = append(productProtos, convertProductToProto(p))
 	}
 
 	return &pb.ListProductsResponse{
-		Success:  true,
-		Message:  "OK",
-		Products: productProtos,
+		Success:       true,
+		Message:       "OK",
+		Products:      productProtos,
+		NextPageToken: page.NextPageToken,
+		TotalCount:    page.TotalCount,
+	}, nil
+}
+
+// SearchProducts handles gRPC request for full-text product search
+func (h *ProductHandler) SearchProducts(ctx context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
+	searchReq := &model.SearchProductsRequest{
+		Query:     req.Query,
+		UserID:    req.UserId,
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+	}
+
+	result, err := h.service.SearchProducts(ctx, searchReq)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.SearchProductsResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	var results []*pb.ProductSearchResult
+	for _, hit := range result.Hits {
+		results = append(results, &pb.ProductSearchResult{
+			Product:              convertProductToProto(hit.Product),
+			Rank:                 hit.Rank,
+			NameHighlight:        hit.NameHighlight,
+			DescriptionHighlight: hit.DescriptionHighlight,
+		})
+	}
+
+	return &pb.SearchProductsResponse{
+		Success:       true,
+		Message:       "OK",
+		Results:       results,
+		NextPageToken: result.NextPageToken,
+		TotalCount:    result.TotalCount,
 	}, nil
 }
 
 // UpdateProduct handles gRPC request to update product
 func (h *ProductHandler) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.UpdateProductResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.UpdateProductResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	price, err := moneyFromProto("price", req.Price)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.UpdateProductResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
 	updReq := &model.UpdateProductRequest{
 		ID:          req.Id,
 		Name:        req.Name,
 		Description: req.Description,
-		Price:       req.Price,
+		Price:       price,
 		Stock:       int(req.Stock),
+		CategoryID:  req.CategoryId,
+		Tags:        req.Tags,
+		Version:     req.Version,
+		UpdateMask:  req.UpdateMask.GetPaths(),
 	}
 
-	product, err := h.service.UpdateProduct(updReq)
+	product, err := h.service.UpdateProduct(ctx, caller, updReq)
 	if err != nil {
-		return &pb.UpdateProductResponse{Success: false, Message: err.Error()}, status.Error(codes.InvalidArgument, err.Error())
+		st := apperror.ToStatus(err)
+		return &pb.UpdateProductResponse{Success: false, Message: st.Message()}, st.Err()
 	}
 
 	return &pb.UpdateProductResponse{
@@ -99,8 +211,15 @@ func (h *ProductHandler) UpdateProduct(ctx context.Context, req *pb.UpdateProduc
 
 // DeleteProduct handles gRPC request to delete product
 func (h *ProductHandler) DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error) {
-	if err := h.service.DeleteProduct(req.Id); err != nil {
-		return &pb.DeleteProductResponse{Success: false, Message: err.Error()}, status.Error(codes.NotFound, err.Error())
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.DeleteProductResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	err := h.service.DeleteProduct(ctx, caller, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.DeleteProductResponse{Success: false, Message: st.Message()}, st.Err()
 	}
 
 	return &pb.DeleteProductResponse{
@@ -109,16 +228,392 @@ func (h *ProductHandler) DeleteProduct(ctx context.Context, req *pb.DeleteProduc
 	}, nil
 }
 
+// RestoreProduct handles gRPC request to restore a soft-deleted product
+func (h *ProductHandler) RestoreProduct(ctx context.Context, req *pb.RestoreProductRequest) (*pb.RestoreProductResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.RestoreProductResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	product, err := h.service.RestoreProduct(ctx, caller, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.RestoreProductResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.RestoreProductResponse{
+		Success: true,
+		Message: "Product restored",
+		Product: convertProductToProto(product),
+	}, nil
+}
+
+// BatchCreateProducts handles gRPC request to create many products at once
+//
+// Owners resolve as in CreateProduct; naming another user_id without being
+// an admin fails the whole call rather than the single item.
+func (h *ProductHandler) BatchCreateProducts(ctx context.Context, req *pb.BatchCreateProductsRequest) (*pb.BatchCreateProductsResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.BatchCreateProductsResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	batchReq := &model.BatchCreateProductsRequest{
+		Products:     make([]*model.CreateProductRequest, len(req.Products)),
+		AllOrNothing: req.AllOrNothing,
+	}
+	for i, item := range req.Products {
+		ownerID := caller.UserID
+		if item.UserId != 0 && item.UserId != caller.UserID {
+			if !caller.IsAdmin() {
+				msg := fmt.Sprintf("products[%d]: cannot create products for another user", i)
+				return &pb.BatchCreateProductsResponse{Success: false, Message: msg}, status.Error(codes.PermissionDenied, msg)
+			}
+			ownerID = item.UserId
+		}
+
+		price, err := moneyFromProto(fmt.Sprintf("products[%d].price", i), item.Price)
+		if err != nil {
+			st := apperror.ToStatus(err)
+			return &pb.BatchCreateProductsResponse{Success: false, Message: st.Message()}, st.Err()
+		}
+
+		batchReq.Products[i] = &model.CreateProductRequest{
+			Name:        item.Name,
+			Description: item.Description,
+			Price:       price,
+			Stock:       int(item.Stock),
+			UserID:      ownerID,
+			CategoryID:  item.CategoryId,
+			Tags:        item.Tags,
+		}
+	}
+
+	results, err := h.service.BatchCreateProducts(ctx, batchReq)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.BatchCreateProductsResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.BatchCreateProductsResponse{
+		Success: true,
+		Message: batchMessage(results, "created"),
+		Results: convertResultsToProto(results),
+	}, nil
+}
+
+// BatchGetProducts handles gRPC request to get products by a list of IDs
+func (h *ProductHandler) BatchGetProducts(ctx context.Context, req *pb.BatchGetProductsRequest) (*pb.BatchGetProductsResponse, error) {
+	results, err := h.service.BatchGetProducts(ctx, req.Ids)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.BatchGetProductsResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.BatchGetProductsResponse{
+		Success: true,
+		Message: batchMessage(results, "found"),
+		Results: convertResultsToProto(results),
+	}, nil
+}
+
+// BatchDeleteProducts handles gRPC request to delete products by a list of IDs
+func (h *ProductHandler) BatchDeleteProducts(ctx context.Context, req *pb.BatchDeleteProductsRequest) (*pb.BatchDeleteProductsResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.BatchDeleteProductsResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	results, err := h.service.BatchDeleteProducts(ctx, caller, req.Ids)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.BatchDeleteProductsResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.BatchDeleteProductsResponse{
+		Success: true,
+		Message: batchMessage(results, "deleted"),
+		Results: convertResultsToProto(results),
+	}, nil
+}
+
+// ImportProducts handles a streamed product import, acknowledging progress as it goes
+//
+// Owners resolve as in CreateProduct. An item naming another user_id without
+// admin rights, or carrying a malformed price, ends the stream.
+func (h *ProductHandler) ImportProducts(stream pb.ProductService_ImportProductsServer) error {
+	ctx := stream.Context()
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	var recvErr error
+	var index int64
+	next := func() (*model.CreateProductRequest, error) {
+		item, err := stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				recvErr = err
+			}
+			return nil, err
+		}
+		defer func() { index++ }()
+
+		ownerID := caller.UserID
+		if item.UserId != 0 && item.UserId != caller.UserID {
+			if !caller.IsAdmin() {
+				return nil, apperror.PermissionDenied(fmt.Sprintf("item %d: cannot create products for another user", index))
+			}
+			ownerID = item.UserId
+		}
+
+		price, err := moneyFromProto("price", item.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		return &model.CreateProductRequest{
+			Name:        item.Name,
+			Description: item.Description,
+			Price:       price,
+			Stock:       int(item.Stock),
+			UserID:      ownerID,
+			CategoryID:  item.CategoryId,
+			Tags:        item.Tags,
+		}, nil
+	}
+	report := func(p *model.ImportProgress) error {
+		return stream.Send(convertImportProgressToProto(p))
+	}
+
+	if err := h.service.ImportProducts(ctx, next, report); err != nil {
+		if recvErr != nil {
+			return recvErr
+		}
+		return apperror.ToStatus(err).Err()
+	}
+	return nil
+}
+
+// ExportProducts handles gRPC request to stream a seller's catalog
+func (h *ProductHandler) ExportProducts(req *pb.ExportProductsRequest, stream pb.ProductService_ExportProductsServer) error {
+	var sendErr error
+	send := func(p *model.Product) error {
+		sendErr = stream.Send(convertProductToProto(p))
+		return sendErr
+	}
+
+	if err := h.service.ExportProducts(stream.Context(), req.UserId, send); err != nil {
+		if sendErr != nil {
+			return sendErr
+		}
+		return apperror.ToStatus(err).Err()
+	}
+	return nil
+}
+
+// WatchProducts handles gRPC request to stream product changes
+func (h *ProductHandler) WatchProducts(req *pb.WatchProductsRequest, stream pb.ProductService_WatchProductsServer) error {
+	watchReq := &model.WatchProductsRequest{
+		ProductEventFilter: model.ProductEventFilter{
+			UserID:     req.UserId,
+			ProductIDs: req.ProductIds,
+		},
+		ResumeToken: req.ResumeToken,
+	}
+
+	var sendErr error
+	send := func(e *model.ProductEvent) error {
+		sendErr = stream.Send(convertProductEventToProto(e))
+		return sendErr
+	}
+
+	if err := h.watcher.WatchProducts(stream.Context(), watchReq, send); err != nil {
+		if sendErr != nil {
+			return sendErr
+		}
+		return apperror.ToStatus(err).Err()
+	}
+	return nil
+}
+
+// AdjustStock handles gRPC request to atomically change product stock
+func (h *ProductHandler) AdjustStock(ctx context.Context, req *pb.AdjustStockRequest) (*pb.AdjustStockResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.AdjustStockResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	stock, err := h.service.AdjustStock(ctx, caller, req.ProductId, int(req.Delta))
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.AdjustStockResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.AdjustStockResponse{
+		Success: true,
+		Message: "Stock adjusted",
+		Stock:   int32(stock),
+	}, nil
+}
+
+// ReserveStock handles gRPC request to hold product stock for the caller
+func (h *ProductHandler) ReserveStock(ctx context.Context, req *pb.ReserveStockRequest) (*pb.ReserveStockResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.ReserveStockResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	reserveReq := &model.ReserveStockRequest{
+		ProductID: req.ProductId,
+		Quantity:  int(req.Quantity),
+		TTL:       time.Duration(req.TtlSeconds) * time.Second,
+	}
+
+	res, err := h.service.ReserveStock(ctx, caller, reserveReq)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ReserveStockResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.ReserveStockResponse{
+		Success:     true,
+		Message:     "Stock reserved",
+		Reservation: convertReservationToProto(res),
+	}, nil
+}
+
+// ReleaseStock handles gRPC request to cancel a stock reservation
+func (h *ProductHandler) ReleaseStock(ctx context.Context, req *pb.ReleaseStockRequest) (*pb.ReleaseStockResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.ReleaseStockResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	if err := h.service.ReleaseStock(ctx, caller, req.ReservationId); err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ReleaseStockResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.ReleaseStockResponse{
+		Success: true,
+		Message: "Reservation released",
+	}, nil
+}
+
+// CommitReservation handles gRPC request to finalize a stock reservation
+func (h *ProductHandler) CommitReservation(ctx context.Context, req *pb.CommitReservationRequest) (*pb.CommitReservationResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.CommitReservationResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	if err := h.service.CommitReservation(ctx, caller, req.ReservationId); err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.CommitReservationResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.CommitReservationResponse{
+		Success: true,
+		Message: "Reservation committed",
+	}, nil
+}
+
 // convertProductToProto maps internal Product model to gRPC proto message
 func convertProductToProto(p *model.Product) *pb.ProductData {
-	return &pb.ProductData{
+	data := &pb.ProductData{
 		Id:          p.ID,
 		Name:        p.Name,
 		Description: p.Description,
-		Price:       p.Price,
+		Price:       moneyToProto(p.Price),
 		Stock:       int32(p.Stock),
 		UserId:      p.UserID,
 		CreatedAt:   p.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:   p.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		CategoryId:  p.CategoryID,
+		Tags:        p.Tags,
+		Version:     p.Version,
+	}
+	if p.DeletedAt != nil {
+		data.DeletedAt = p.DeletedAt.Format("2006-01-02T15:04:05Z")
+	}
+	return data
+}
+
+// convertResultsToProto converts batch results, giving each item its own status
+func convertResultsToProto(results []*model.ProductResult) []*pb.ProductResult {
+	out := make([]*pb.ProductResult, len(results))
+	for i, r := range results {
+		out[i] = &pb.ProductResult{Id: r.ID, Status: &spb.Status{Code: int32(codes.OK)}}
+		if r.Err != nil {
+			out[i].Status = apperror.ToStatus(r.Err).Proto()
+			continue
+		}
+		if r.Product != nil {
+			out[i].Product = convertProductToProto(r.Product)
+		}
+	}
+	return out
+}
+
+// convertImportProgressToProto maps an import acknowledgement, giving each failed item its status
+func convertImportProgressToProto(p *model.ImportProgress) *pb.ImportProductsProgress {
+	out := &pb.ImportProductsProgress{
+		Received: p.Received,
+		Created:  p.Created,
+		Failed:   p.Failed,
+		Done:     p.Done,
+	}
+	for _, e := range p.Errors {
+		out.Errors = append(out.Errors, &pb.ImportError{Index: e.Index, Status: apperror.ToStatus(e.Err).Proto()})
+	}
+	return out
+}
+
+// convertProductEventToProto maps a change feed event to gRPC proto message
+func convertProductEventToProto(e *model.ProductEvent) *pb.ProductEvent {
+	out := &pb.ProductEvent{
+		Type:        string(e.Type),
+		ProductId:   e.ProductID,
+		ResumeToken: e.ResumeToken,
+		OccurredAt:  e.OccurredAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if e.Product != nil {
+		out.Product = convertProductToProto(e.Product)
+	}
+	return out
+}
+
+// batchMessage summarizes how many items of a batch succeeded
+func batchMessage(results []*model.ProductResult, verb string) string {
+	ok := 0
+	for _, r := range results {
+		if r.Err == nil {
+			ok++
+		}
+	}
+	return fmt.Sprintf("%d of %d products %s", ok, len(results), verb)
+}
+
+// convertReservationToProto maps internal StockReservation model to gRPC proto message
+func convertReservationToProto(r *model.StockReservation) *pb.StockReservationData {
+	return &pb.StockReservationData{
+		Id:        r.ID,
+		ProductId: r.ProductID,
+		UserId:    r.UserID,
+		Quantity:  int32(r.Quantity),
+		Status:    string(r.Status),
+		ExpiresAt: r.ExpiresAt.Format("2006-01-02T15:04:05Z"),
+		CreatedAt: r.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// int32PtrToIntPtr converts an optional proto int32 into an optional int
+func int32PtrToIntPtr(v *int32) *int {
+	if v == nil {
+		return nil
 	}
+	i := int(*v)
+	return &i
 }
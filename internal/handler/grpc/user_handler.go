@@ -2,6 +2,8 @@ package grpc
 
 import (
 	"context"
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/middleware"
 	"grpc-exmpl/internal/model"
 	"grpc-exmpl/internal/service"
 	pb "grpc-exmpl/proto/user"
@@ -31,13 +33,14 @@ func (h *UserHandler) Register(ctx context.Context, req *pb.RegisterRequest) (*p
 	}
 
 	// Call service
-	user, err := h.userService.Register(registerReq)
+	user, err := h.userService.Register(ctx, registerReq)
 	if err != nil {
+		st := apperror.ToStatus(err)
 		return &pb.RegisterResponse{
 			Success: false,
-			Message: err.Error(),
+			Message: st.Message(),
 			User:    nil,
-		}, status.Error(codes.InvalidArgument, err.Error())
+		}, st.Err()
 	}
 
 	// Convert model to proto response
@@ -65,14 +68,15 @@ func (h *UserHandler) Login(ctx context.Context, req *pb.LoginRequest) (*pb.Logi
 	}
 
 	// Call service
-	loginResp, err := h.userService.Login(loginReq)
+	loginResp, err := h.userService.Login(ctx, loginReq)
 	if err != nil {
+		st := apperror.ToStatus(err)
 		return &pb.LoginResponse{
 			Success: false,
-			Message: err.Error(),
+			Message: st.Message(),
 			Token:   "",
 			User:    nil,
-		}, status.Error(codes.Unauthenticated, err.Error())
+		}, st.Err()
 	}
 
 	// Convert model to proto response
@@ -86,22 +90,61 @@ func (h *UserHandler) Login(ctx context.Context, req *pb.LoginRequest) (*pb.Logi
 	}
 
 	return &pb.LoginResponse{
+		Success:      true,
+		Message:      "Login successful",
+		Token:        loginResp.Token,
+		User:         userProto,
+		RefreshToken: loginResp.RefreshToken,
+		ExpiresAt:    loginResp.ExpiresAt.Format("2006-01-02T15:04:05Z"),
+	}, nil
+}
+
+func (h *UserHandler) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	// Call service
+	tokens, err := h.userService.RefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.RefreshTokenResponse{
+			Success: false,
+			Message: st.Message(),
+		}, st.Err()
+	}
+
+	return &pb.RefreshTokenResponse{
+		Success:      true,
+		Message:      "Token refreshed successfully",
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt.Format("2006-01-02T15:04:05Z"),
+	}, nil
+}
+
+func (h *UserHandler) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	// Call service
+	if err := h.userService.Logout(ctx, req.RefreshToken); err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.LogoutResponse{
+			Success: false,
+			Message: st.Message(),
+		}, st.Err()
+	}
+
+	return &pb.LogoutResponse{
 		Success: true,
-		Message: "Login successful",
-		Token:   loginResp.Token,
-		User:    userProto,
+		Message: "Logged out successfully",
 	}, nil
 }
 
 func (h *UserHandler) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.GetProfileResponse, error) {
 	// Call service
-	user, err := h.userService.GetProfile(req.Token)
+	user, err := h.userService.GetProfile(ctx, req.Token)
 	if err != nil {
+		st := apperror.ToStatus(err)
 		return &pb.GetProfileResponse{
 			Success: false,
-			Message: err.Error(),
+			Message: st.Message(),
 			User:    nil,
-		}, status.Error(codes.Unauthenticated, err.Error())
+		}, st.Err()
 	}
 
 	// Convert model to proto response
@@ -120,3 +163,98 @@ func (h *UserHandler) GetProfile(ctx context.Context, req *pb.GetProfileRequest)
 		User:    userProto,
 	}, nil
 }
+
+// UpdateProfile handles gRPC request to change the caller's own profile
+func (h *UserHandler) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.UpdateProfileResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.UpdateProfileResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	user, err := h.userService.UpdateProfile(ctx, caller, &model.UpdateProfileRequest{
+		Username:   req.Username,
+		Email:      req.Email,
+		FullName:   req.FullName,
+		UpdateMask: req.UpdateMask.GetPaths(),
+	})
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.UpdateProfileResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.UpdateProfileResponse{
+		Success: true,
+		Message: "Profile updated successfully",
+		User: &pb.UserData{
+			Id:        user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			FullName:  user.FullName,
+			CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			UpdatedAt: user.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		},
+	}, nil
+}
+
+func (h *UserHandler) GetPublicKeys(ctx context.Context, req *pb.GetPublicKeysRequest) (*pb.GetPublicKeysResponse, error) {
+	var keys []*pb.JsonWebKey
+	for _, k := range h.userService.GetPublicKeys() {
+		keys = append(keys, &pb.JsonWebKey{
+			Kty: k.Kty,
+			Kid: k.Kid,
+			Alg: k.Alg,
+			Use: k.Use,
+			N:   k.N,
+			E:   k.E,
+			Crv: k.Crv,
+			X:   k.X,
+		})
+	}
+
+	return &pb.GetPublicKeysResponse{Keys: keys}, nil
+}
+
+// DeleteUser handles gRPC request to soft-delete a user
+func (h *UserHandler) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.DeleteUserResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	if err := h.userService.DeleteUser(ctx, caller, req.Id); err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.DeleteUserResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.DeleteUserResponse{
+		Success: true,
+		Message: "User deleted",
+	}, nil
+}
+
+// RestoreUser handles gRPC request to restore a soft-deleted user
+func (h *UserHandler) RestoreUser(ctx context.Context, req *pb.RestoreUserRequest) (*pb.RestoreUserResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.RestoreUserResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	user, err := h.userService.RestoreUser(ctx, caller, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.RestoreUserResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.RestoreUserResponse{
+		Success: true,
+		Message: "User restored",
+		User: &pb.UserData{
+			Id:        user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			FullName:  user.FullName,
+			CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+			UpdatedAt: user.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		},
+	}, nil
+}
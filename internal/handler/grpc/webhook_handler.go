@@ -0,0 +1,205 @@
+package grpc
+
+import (
+	"context"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/middleware"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+	pb "grpc-exmpl/proto/webhook"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type WebhookHandler struct {
+	pb.UnimplementedWebhookServiceServer
+	service service.WebhookService
+}
+
+func NewWebhookHandler(svc service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: svc}
+}
+
+// CreateWebhook handles gRPC request to register a webhook for the caller's products
+func (h *WebhookHandler) CreateWebhook(ctx context.Context, req *pb.CreateWebhookRequest) (*pb.CreateWebhookResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.CreateWebhookResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	webhook, err := h.service.CreateWebhook(ctx, caller, &model.CreateWebhookRequest{
+		URL:        req.Url,
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+		ProductIDs: req.ProductIds,
+	})
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.CreateWebhookResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.CreateWebhookResponse{
+		Success: true,
+		Message: "Webhook created successfully",
+		Webhook: convertWebhookToProto(webhook),
+		Secret:  webhook.Secret,
+	}, nil
+}
+
+// ListWebhooks handles gRPC request to list the caller's webhooks
+func (h *WebhookHandler) ListWebhooks(ctx context.Context, req *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.ListWebhooksResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	webhooks, err := h.service.ListWebhooks(ctx, caller)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ListWebhooksResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	webhookProtos := make([]*pb.WebhookData, 0, len(webhooks))
+	for _, w := range webhooks {
+		webhookProtos = append(webhookProtos, convertWebhookToProto(w))
+	}
+
+	return &pb.ListWebhooksResponse{
+		Success:  true,
+		Message:  "OK",
+		Webhooks: webhookProtos,
+	}, nil
+}
+
+// DeleteWebhook handles gRPC request to delete a webhook and its deliveries
+func (h *WebhookHandler) DeleteWebhook(ctx context.Context, req *pb.DeleteWebhookRequest) (*pb.DeleteWebhookResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.DeleteWebhookResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	if err := h.service.DeleteWebhook(ctx, caller, req.Id); err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.DeleteWebhookResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.DeleteWebhookResponse{
+		Success: true,
+		Message: "Webhook deleted successfully",
+	}, nil
+}
+
+// ListDeliveries handles gRPC request to list a webhook's deliveries
+func (h *WebhookHandler) ListDeliveries(ctx context.Context, req *pb.ListDeliveriesRequest) (*pb.ListDeliveriesResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.ListDeliveriesResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	page, err := h.service.ListDeliveries(ctx, caller, &model.ListDeliveriesRequest{
+		WebhookID: req.WebhookId,
+		Status:    req.Status,
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+	})
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ListDeliveriesResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	deliveryProtos := make([]*pb.DeliveryData, 0, len(page.Deliveries))
+	for _, d := range page.Deliveries {
+		deliveryProtos = append(deliveryProtos, convertDeliveryToProto(d))
+	}
+
+	return &pb.ListDeliveriesResponse{
+		Success:       true,
+		Message:       "OK",
+		Deliveries:    deliveryProtos,
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+// GetDelivery handles gRPC request to get a delivery with its attempt log
+func (h *WebhookHandler) GetDelivery(ctx context.Context, req *pb.GetDeliveryRequest) (*pb.GetDeliveryResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.GetDeliveryResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	delivery, err := h.service.GetDelivery(ctx, caller, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.GetDeliveryResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.GetDeliveryResponse{
+		Success:  true,
+		Message:  "OK",
+		Delivery: convertDeliveryToProto(delivery),
+	}, nil
+}
+
+// ReplayDelivery handles gRPC request to send a finished delivery again
+func (h *WebhookHandler) ReplayDelivery(ctx context.Context, req *pb.ReplayDeliveryRequest) (*pb.ReplayDeliveryResponse, error) {
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok {
+		return &pb.ReplayDeliveryResponse{Success: false, Message: "unauthenticated"}, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	delivery, err := h.service.ReplayDelivery(ctx, caller, req.Id)
+	if err != nil {
+		st := apperror.ToStatus(err)
+		return &pb.ReplayDeliveryResponse{Success: false, Message: st.Message()}, st.Err()
+	}
+
+	return &pb.ReplayDeliveryResponse{
+		Success:  true,
+		Message:  "Delivery queued for replay",
+		Delivery: convertDeliveryToProto(delivery),
+	}, nil
+}
+
+// convertWebhookToProto maps internal Webhook model to gRPC proto message, leaving out the secret
+func convertWebhookToProto(w *model.Webhook) *pb.WebhookData {
+	return &pb.WebhookData{
+		Id:         w.ID,
+		Url:        w.URL,
+		EventTypes: w.EventTypes,
+		ProductIds: w.ProductIDs,
+		CreatedAt:  w.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:  w.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// convertDeliveryToProto maps internal WebhookDelivery model to gRPC proto message
+func convertDeliveryToProto(d *model.WebhookDelivery) *pb.DeliveryData {
+	data := &pb.DeliveryData{
+		Id:             d.ID,
+		WebhookId:      d.WebhookID,
+		EventId:        d.EventID,
+		EventType:      d.EventType,
+		Payload:        string(d.Payload),
+		Status:         d.Status,
+		Attempts:       int32(d.Attempts),
+		NextAttemptAt:  d.NextAttemptAt.Format("2006-01-02T15:04:05Z"),
+		LastStatusCode: int32(d.LastStatusCode),
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if d.DeliveredAt != nil {
+		data.DeliveredAt = d.DeliveredAt.Format("2006-01-02T15:04:05Z")
+	}
+	for _, a := range d.AttemptLog {
+		data.AttemptLog = append(data.AttemptLog, &pb.DeliveryAttempt{
+			Attempt:     int32(a.Attempt),
+			StatusCode:  int32(a.StatusCode),
+			Error:       a.Error,
+			DurationMs:  a.Duration.Milliseconds(),
+			AttemptedAt: a.AttemptedAt.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+	return data
+}
@@ -2,6 +2,9 @@ package middleware
 
 import (
 	"context"
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/config"
+	"grpc-exmpl/internal/model"
 	"grpc-exmpl/internal/service"
 	"strings"
 
@@ -13,11 +16,13 @@ import (
 
 type AuthMiddleware struct {
 	userService service.UserService
+	policies    *PolicyTable
 }
 
-func NewAuthMiddleware(userService service.UserService) *AuthMiddleware {
+func NewAuthMiddleware(userService service.UserService, policies *PolicyTable) *AuthMiddleware {
 	return &AuthMiddleware{
 		userService: userService,
+		policies:    policies,
 	}
 }
 
@@ -28,26 +33,11 @@ func (a *AuthMiddleware) UnaryInterceptor(
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (interface{}, error) {
-	// Skip auth for certain methods
-	if a.isPublicMethod(info.FullMethod) {
-		return handler(ctx, req)
-	}
-
-	// Extract token from metadata
-	token, err := a.extractToken(ctx)
+	ctx, err := a.authorize(ctx, info.FullMethod)
 	if err != nil {
 		return nil, err
 	}
 
-	// Validate token
-	claims, err := a.userService.ValidateToken(token)
-	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "invalid token")
-	}
-
-	// Add user info to context
-	ctx = a.addUserToContext(ctx, claims.UserID, claims.Username, claims.Email)
-
 	return handler(ctx, req)
 }
 
@@ -58,43 +48,62 @@ func (a *AuthMiddleware) StreamInterceptor(
 	info *grpc.StreamServerInfo,
 	handler grpc.StreamHandler,
 ) error {
-	// Skip auth for certain methods
-	if a.isPublicMethod(info.FullMethod) {
-		return handler(srv, ss)
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	wrappedStream := &wrappedServerStream{ss, ctx}
+
+	return handler(srv, wrappedStream)
+}
+
+// authorize enforces the method's policy and returns a context carrying the caller
+func (a *AuthMiddleware) authorize(ctx context.Context, method string) (context.Context, error) {
+	policy := a.policies.lookup(method)
+	if policy.access == AccessPublic {
+		return ctx, nil
 	}
 
 	// Extract token from metadata
-	token, err := a.extractToken(ss.Context())
+	token, err := a.extractToken(ctx)
 	if err != nil {
-		return err
+		// Internal callers may present a client certificate instead
+		if identity, ok := GetClientIdentityFromContext(ctx); ok {
+			if svc, known := a.policies.service(identity); known {
+				return a.authorizeService(ctx, policy, svc)
+			}
+		}
+		return nil, err
 	}
 
 	// Validate token
-	claims, err := a.userService.ValidateToken(token)
+	claims, err := a.userService.ValidateToken(ctx, token)
 	if err != nil {
-		return status.Error(codes.Unauthenticated, "invalid token")
+		// A session store outage must not masquerade as a bad token
+		if apperror.KindOf(err) != apperror.KindUnauthenticated {
+			return nil, apperror.ToStatus(err).Err()
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
 	}
 
-	// Add user info to context
-	ctx := a.addUserToContext(ss.Context(), claims.UserID, claims.Username, claims.Email)
-	wrappedStream := &wrappedServerStream{ss, ctx}
+	if !policy.allows(claims.Roles) {
+		return nil, status.Error(codes.PermissionDenied, "insufficient role")
+	}
 
-	return handler(srv, wrappedStream)
+	// Add user info to context
+	return a.addUserToContext(ctx, claims.UserID, claims.Username, claims.Email, claims.Roles), nil
 }
 
-// isPublicMethod checks if the method doesn't require authentication
-func (a *AuthMiddleware) isPublicMethod(method string) bool {
-	publicMethods := []string{
-		"/user.UserService/Register",
-		"/user.UserService/Login",
+// authorizeService admits an internal service authenticated by its
+// client certificate, with the roles configured for it
+func (a *AuthMiddleware) authorizeService(ctx context.Context, policy methodPolicy, svc config.ServiceIdentity) (context.Context, error) {
+	if !policy.allows(svc.Roles) {
+		return nil, status.Error(codes.PermissionDenied, "insufficient role")
 	}
 
-	for _, publicMethod := range publicMethods {
-		if method == publicMethod {
-			return true
-		}
-	}
-	return false
+	ctx = context.WithValue(ctx, "service", svc.Name)
+	ctx = context.WithValue(ctx, "roles", svc.Roles)
+	return ctx, nil
 }
 
 // extractToken extracts JWT token from gRPC metadata
@@ -119,10 +128,11 @@ func (a *AuthMiddleware) extractToken(ctx context.Context) (string, error) {
 }
 
 // addUserToContext adds user information to context
-func (a *AuthMiddleware) addUserToContext(ctx context.Context, userID int64, username, email string) context.Context {
+func (a *AuthMiddleware) addUserToContext(ctx context.Context, userID int64, username, email string, roles []string) context.Context {
 	ctx = context.WithValue(ctx, "user_id", userID)
 	ctx = context.WithValue(ctx, "username", username)
 	ctx = context.WithValue(ctx, "email", email)
+	ctx = context.WithValue(ctx, "roles", roles)
 	return ctx
 }
 
@@ -151,3 +161,21 @@ func GetEmailFromContext(ctx context.Context) (string, bool) {
 	email, ok := ctx.Value("email").(string)
 	return email, ok
 }
+
+func GetRolesFromContext(ctx context.Context) ([]string, bool) {
+	roles, ok := ctx.Value("roles").([]string)
+	return roles, ok
+}
+
+// GetCallerFromContext returns the authenticated caller, if any
+func GetCallerFromContext(ctx context.Context) (model.Caller, bool) {
+	roles, _ := GetRolesFromContext(ctx)
+	if service, ok := GetServiceFromContext(ctx); ok {
+		return model.Caller{Service: service, Roles: roles}, true
+	}
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		return model.Caller{}, false
+	}
+	return model.Caller{UserID: userID, Roles: roles}, true
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"grpc-exmpl/internal/config"
+)
+
+// corsRequestHeaders are the headers gRPC-Web and Connect clients send
+var corsRequestHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"Grpc-Timeout",
+	"X-Grpc-Web",
+	"X-User-Agent",
+	"Connect-Protocol-Version",
+	"Connect-Timeout-Ms",
+}
+
+// corsResponseHeaders are the headers browsers must let gRPC-Web clients
+// read to learn the call's outcome
+var corsResponseHeaders = []string{
+	"Grpc-Status",
+	"Grpc-Message",
+	"Grpc-Status-Details-Bin",
+}
+
+// CORSMiddleware lets browser pages on allowed origins call HTTP endpoints.
+type CORSMiddleware struct {
+	origins          []string
+	anyOrigin        bool
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// NewCORSMiddleware creates a new CORSMiddleware.
+//
+// With no allowed origins, cross-origin requests get no CORS headers and
+// browsers block them.
+func NewCORSMiddleware(cfg config.CORSConfig) *CORSMiddleware {
+	m := &CORSMiddleware{
+		allowHeaders:     strings.Join(append(slices.Clone(corsRequestHeaders), cfg.AllowedHeaders...), ", "),
+		exposeHeaders:    strings.Join(append(slices.Clone(corsResponseHeaders), cfg.ExposedHeaders...), ", "),
+		allowCredentials: cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			m.anyOrigin = true
+			continue
+		}
+		m.origins = append(m.origins, strings.TrimSuffix(origin, "/"))
+	}
+	if cfg.MaxAge > 0 {
+		m.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	return m
+}
+
+// Handler answers preflight requests and marks responses to allowed
+// origins as readable; requests without an Origin pass straight through.
+func (m *CORSMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !m.allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.anyOrigin && !m.allowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if m.allowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE")
+			h.Set("Access-Control-Allow-Headers", m.allowHeaders)
+			if m.maxAge != "" {
+				h.Set("Access-Control-Max-Age", m.maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.Set("Access-Control-Expose-Headers", m.exposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *CORSMiddleware) allowed(origin string) bool {
+	return m.anyOrigin || slices.Contains(m.origins, origin)
+}
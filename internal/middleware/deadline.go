@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"grpc-exmpl/internal/config"
+
+	"google.golang.org/grpc"
+)
+
+// DeadlineMiddleware applies a server-side deadline to calls that arrive
+// without one, so abandoned requests cannot hold database queries forever.
+type DeadlineMiddleware struct {
+	defaultTimeout time.Duration
+	methods        map[string]time.Duration
+}
+
+// NewDeadlineMiddleware creates a new DeadlineMiddleware.
+//
+// Unary calls fall back to defaultTimeout; streams are only bounded when
+// their method has an explicit entry, since they are expected to be long-lived.
+func NewDeadlineMiddleware(defaultTimeout time.Duration, methodTimeouts []config.MethodTimeout) *DeadlineMiddleware {
+	methods := make(map[string]time.Duration, len(methodTimeouts))
+	for _, mt := range methodTimeouts {
+		methods[mt.Method] = mt.Timeout
+	}
+	return &DeadlineMiddleware{defaultTimeout: defaultTimeout, methods: methods}
+}
+
+// UnaryInterceptor bounds unary RPCs that have no client deadline.
+func (m *DeadlineMiddleware) UnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	timeout, ok := m.methods[info.FullMethod]
+	if !ok {
+		timeout = m.defaultTimeout
+	}
+
+	ctx, cancel := withDefaultDeadline(ctx, timeout)
+	defer cancel()
+
+	return handler(ctx, req)
+}
+
+// StreamInterceptor bounds streaming RPCs that have a configured timeout.
+func (m *DeadlineMiddleware) StreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	timeout, ok := m.methods[info.FullMethod]
+	if !ok {
+		return handler(srv, ss)
+	}
+
+	ctx, cancel := withDefaultDeadline(ss.Context(), timeout)
+	defer cancel()
+
+	return handler(srv, &wrappedServerStream{ss, ctx})
+}
+
+// withDefaultDeadline applies timeout unless ctx already has a deadline or timeout is disabled
+func withDefaultDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
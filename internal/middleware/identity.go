@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientIdentity is the subject of a client certificate that was verified
+// against the configured CA bundle.
+type ClientIdentity struct {
+	CommonName string
+	DNSNames   []string
+	URIs       []string
+}
+
+// names lists every name the identity answers to, most specific first
+func (c ClientIdentity) names() []string {
+	names := append([]string{}, c.URIs...)
+	names = append(names, c.DNSNames...)
+	if c.CommonName != "" {
+		names = append(names, c.CommonName)
+	}
+	return names
+}
+
+// GetClientIdentityFromContext returns the identity of the verified client
+// certificate the call arrived with, if any. It works for native gRPC as
+// well as gRPC-Web, Connect and REST calls.
+func GetClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ClientIdentity{}, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ClientIdentity{}, false
+	}
+
+	leaf := info.State.VerifiedChains[0][0]
+	identity := ClientIdentity{
+		CommonName: leaf.Subject.CommonName,
+		DNSNames:   leaf.DNSNames,
+	}
+	for _, uri := range leaf.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity, true
+}
+
+// GetServiceFromContext returns the name of the internal service the call
+// was authenticated as, if it used a client certificate instead of a JWT
+func GetServiceFromContext(ctx context.Context) (string, bool) {
+	service, ok := ctx.Value("service").(string)
+	return service, ok
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+
+	"grpc-exmpl/internal/config"
+)
+
+// Access levels understood by the policy table.
+const (
+	AccessPublic        = "public"
+	AccessAuthenticated = "authenticated"
+	AccessRoles         = "roles"
+)
+
+// methodPolicy is the resolved access rule for one RPC.
+type methodPolicy struct {
+	access string
+	roles  []string
+}
+
+// PolicyTable maps fully-qualified gRPC methods to their access rule, and
+// client certificate identities to the internal services they belong to.
+type PolicyTable struct {
+	defaultPolicy methodPolicy
+	methods       map[string]methodPolicy
+	services      map[string]config.ServiceIdentity
+}
+
+// NewPolicyTable builds a PolicyTable from configuration, rejecting
+// unknown access levels and role policies without any roles.
+func NewPolicyTable(cfg config.AuthConfig) (*PolicyTable, error) {
+	defaultAccess := cfg.DefaultAccess
+	if defaultAccess == "" {
+		defaultAccess = AccessAuthenticated
+	}
+	if defaultAccess != AccessPublic && defaultAccess != AccessAuthenticated {
+		return nil, fmt.Errorf("invalid default access %q", defaultAccess)
+	}
+
+	table := &PolicyTable{
+		defaultPolicy: methodPolicy{access: defaultAccess},
+		methods:       make(map[string]methodPolicy, len(cfg.Policies)),
+		services:      make(map[string]config.ServiceIdentity, len(cfg.Services)),
+	}
+
+	for _, p := range cfg.Policies {
+		if p.Method == "" {
+			return nil, fmt.Errorf("policy is missing a method")
+		}
+		switch p.Access {
+		case AccessPublic, AccessAuthenticated:
+		case AccessRoles:
+			if len(p.Roles) == 0 {
+				return nil, fmt.Errorf("policy for %s requires at least one role", p.Method)
+			}
+		default:
+			return nil, fmt.Errorf("invalid access %q for %s", p.Access, p.Method)
+		}
+		table.methods[p.Method] = methodPolicy{access: p.Access, roles: p.Roles}
+	}
+
+	for _, svc := range cfg.Services {
+		if svc.Name == "" {
+			return nil, fmt.Errorf("service identity is missing a name")
+		}
+		if _, dup := table.services[svc.Name]; dup {
+			return nil, fmt.Errorf("duplicate service identity %s", svc.Name)
+		}
+		table.services[svc.Name] = svc
+	}
+
+	return table, nil
+}
+
+// service returns the internal service a client certificate identifies
+func (t *PolicyTable) service(identity ClientIdentity) (config.ServiceIdentity, bool) {
+	for _, name := range identity.names() {
+		if svc, ok := t.services[name]; ok {
+			return svc, true
+		}
+	}
+	return config.ServiceIdentity{}, false
+}
+
+// lookup returns the policy for a method, falling back to the default.
+func (t *PolicyTable) lookup(method string) methodPolicy {
+	if p, ok := t.methods[method]; ok {
+		return p
+	}
+	return t.defaultPolicy
+}
+
+// allows reports whether a caller holding roles satisfies the policy.
+func (p methodPolicy) allows(roles []string) bool {
+	if p.access != AccessRoles {
+		return true
+	}
+	for _, want := range p.roles {
+		for _, have := range roles {
+			if want == have {
+				return true
+			}
+		}
+	}
+	return false
+}
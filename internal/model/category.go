@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Category is a node of the product catalog tree.
+//
+// ParentID is zero for top-level categories. Slug is unique across the
+// whole tree and is what clients use in URLs.
+type Category struct {
+	ID        int64     `json:"id" db:"id"`
+	ParentID  int64     `json:"parent_id" db:"parent_id"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateCategoryRequest is used when creating a category.
+//
+// Slug is derived from Name when empty.
+type CreateCategoryRequest struct {
+	ParentID int64  `json:"parent_id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+}
+
+// UpdateCategoryRequest is used when renaming or moving a category.
+type UpdateCategoryRequest struct {
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"parent_id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+}
+
+// ListCategoriesRequest is used when browsing the category tree.
+//
+// A non-zero RootID limits the listing to that category and its descendants.
+type ListCategoriesRequest struct {
+	RootID int64 `json:"root_id"`
+}
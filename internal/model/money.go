@@ -0,0 +1,92 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts stored before currencies were tracked.
+const DefaultCurrency = "USD"
+
+// MinorUnitsPerMajor is the number of minor units (cents) in a major unit.
+// Prices are stored as DECIMAL(…,2), so only two-decimal currencies are supported.
+const MinorUnitsPerMajor = 100
+
+var currencyCodeRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Money is an exact amount of an ISO 4217 currency.
+//
+// Amount is expressed in minor units (cents), so arithmetic on prices and
+// totals never goes through floating point.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// IsValidCurrency reports whether code looks like an ISO 4217 alphabetic code
+func IsValidCurrency(code string) bool {
+	return currencyCodeRegex.MatchString(code)
+}
+
+// Times returns m multiplied by n
+func (m Money) Times(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Add returns the sum of m and o, which must share a currency
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("cannot add %s to %s", o.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Decimal formats the amount in major units with two decimals, e.g. "12.05"
+func (m Money) Decimal() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/MinorUnitsPerMajor, amount%MinorUnitsPerMajor)
+}
+
+// String formats m as "12.05 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// ParseDecimal parses a decimal amount in major units, such as a NUMERIC
+// column value, into minor units. Digits beyond the cent must be zero.
+func ParseDecimal(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid decimal amount %q", s)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("decimal amount %q has sub-cent precision", s)
+	}
+	frac = (frac + "00")[:2]
+
+	units, err := strconv.ParseInt("0"+whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal amount %q", s)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal amount %q", s)
+	}
+
+	amount := units*MinorUnitsPerMajor + cents
+	if neg {
+		amount = -amount
+	}
+	return amount, nil
+}
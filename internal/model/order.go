@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// OrderStatus is the lifecycle state of an order.
+type OrderStatus string
+
+const (
+	OrderPlaced    OrderStatus = "placed"
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+// Order is a checkout of one or more products by a user.
+//
+// TotalAmount is the sum of the item prices captured at checkout and does
+// not change when product prices do. All items share its currency.
+type Order struct {
+	ID          int64        `json:"id" db:"id"`
+	UserID      int64        `json:"user_id" db:"user_id"`
+	Status      OrderStatus  `json:"status" db:"status"`
+	TotalAmount Money        `json:"total_amount" db:"total_amount"`
+	Items       []*OrderItem `json:"items"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// OrderItem is a single product line of an order.
+//
+// ProductName and UnitPrice are snapshots taken at checkout.
+type OrderItem struct {
+	ID          int64  `json:"id" db:"id"`
+	OrderID     int64  `json:"order_id" db:"order_id"`
+	ProductID   int64  `json:"product_id" db:"product_id"`
+	ProductName string `json:"product_name" db:"product_name"`
+	Quantity    int    `json:"quantity" db:"quantity"`
+	UnitPrice   Money  `json:"unit_price" db:"unit_price"`
+}
+
+// OrderLine is a requested product and quantity.
+type OrderLine struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+}
+
+// CreateOrderRequest is used when checking out; the buyer is the caller.
+type CreateOrderRequest struct {
+	Items []OrderLine `json:"items"`
+}
+
+// ListOrdersRequest is used when listing a user's orders, newest first.
+type ListOrdersRequest struct {
+	UserID    int64  `json:"user_id"`
+	PageSize  int    `json:"page_size"`
+	PageToken string `json:"page_token"`
+}
+
+// OrderPage is one page of orders.
+type OrderPage struct {
+	Orders        []*Order `json:"orders"`
+	NextPageToken string   `json:"next_page_token"`
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Domain event types published through the outbox.
+const (
+	EventUserRegistered = "UserRegistered"
+	EventProductCreated = "ProductCreated"
+	EventProductUpdated = "ProductUpdated"
+	EventStockChanged   = "StockChanged"
+)
+
+// Aggregate types an outbox event can be about.
+const (
+	AggregateUser    = "user"
+	AggregateProduct = "product"
+)
+
+// OutboxEvent is a domain event waiting in the outbox to be published.
+//
+// The JSON form, with Payload inlined, is what publishers send. ID is
+// stable across redeliveries, so consumers can use it to drop duplicates.
+type OutboxEvent struct {
+	ID            int64           `json:"id" db:"id"`
+	Type          string          `json:"type" db:"event_type"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id" db:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	Attempts      int             `json:"-" db:"attempts"`
+}
+
+// UserRegisteredEvent is the payload of a UserRegistered event.
+type UserRegisteredEvent struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+}
+
+// ProductChangedEvent is the payload of ProductCreated and ProductUpdated
+// events. Fields lists the fields an update wrote.
+type ProductChangedEvent struct {
+	Product *Product `json:"product"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+// Reasons a StockChanged event can give.
+const (
+	StockReasonAdjustment     = "adjustment"
+	StockReasonUpdate         = "update"
+	StockReasonOrder          = "order"
+	StockReasonOrderCancelled = "order_cancelled"
+	StockReasonReserved       = "reservation"
+	StockReasonReleased       = "reservation_released"
+	StockReasonExpired        = "reservation_expired"
+	// StockReasonCommitted has a zero delta: the units already left stock
+	// when they were reserved, and now will not come back
+	StockReasonCommitted = "reservation_committed"
+)
+
+// StockChangedEvent is the payload of a StockChanged event.
+type StockChangedEvent struct {
+	ProductID int64  `json:"product_id"`
+	Delta     int    `json:"delta"`
+	Stock     int    `json:"stock"`
+	Reason    string `json:"reason"`
+}
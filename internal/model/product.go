@@ -6,31 +6,148 @@ import "time"
 //
 // ID is generated by the database.
 // UserID references the owner of the product.
+// Price is stored in the price and currency columns.
+// CategoryID is zero for uncategorized products.
+// DeletedAt is set once the product is soft-deleted. Version increases
+// with every write to the product and guards updates against lost edits.
 type Product struct {
-	ID          int64     `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Description string    `json:"description" db:"description"`
-	Price       float64   `json:"price" db:"price"`
-	Stock       int       `json:"stock" db:"stock"`
-	UserID      int64     `json:"user_id" db:"user_id"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          int64      `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Description string     `json:"description" db:"description"`
+	Price       Money      `json:"price" db:"price"`
+	Stock       int        `json:"stock" db:"stock"`
+	UserID      int64      `json:"user_id" db:"user_id"`
+	CategoryID  int64      `json:"category_id" db:"category_id"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	Version     int64      `json:"version" db:"version"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 // CreateProductRequest is used when creating a new product.
 type CreateProductRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
-	UserID      int64   `json:"user_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       Money    `json:"price"`
+	Stock       int      `json:"stock"`
+	UserID      int64    `json:"user_id"`
+	CategoryID  int64    `json:"category_id"`
+	Tags        []string `json:"tags"`
 }
 
 // UpdateProductRequest is used when updating an existing product.
+// Version is the product version the change was based on. UpdateMask
+// names the fields to change; when empty, every field is replaced.
 type UpdateProductRequest struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       Money    `json:"price"`
+	Stock       int      `json:"stock"`
+	CategoryID  int64    `json:"category_id"`
+	Tags        []string `json:"tags"`
+	Version     int64    `json:"version"`
+	UpdateMask  []string `json:"update_mask"`
+}
+
+// ProductSortField is a column products can be ordered by when listing.
+type ProductSortField string
+
+const (
+	ProductSortCreatedAt ProductSortField = "created_at"
+	ProductSortPrice     ProductSortField = "price"
+	ProductSortName      ProductSortField = "name"
+	ProductSortStock     ProductSortField = "stock"
+)
+
+// ListProductsRequest is used when listing a user's products.
+//
+// PageToken is opaque to callers and must come from a previous
+// ProductPage.NextPageToken issued for the same owner, filters and sort
+// order; only PageSize may change between pages.
+// Nil range bounds are not applied; price bounds also restrict the listing
+// to their currency. A non-zero CategoryID matches products in that
+// category or any of its descendants. IncludeDeleted, reserved for admins,
+// also lists soft-deleted products.
+type ListProductsRequest struct {
+	UserID         int64            `json:"user_id"`
+	PageSize       int              `json:"page_size"`
+	PageToken      string           `json:"page_token"`
+	MinPrice       *Money           `json:"min_price,omitempty"`
+	MaxPrice       *Money           `json:"max_price,omitempty"`
+	MinStock       *int             `json:"min_stock,omitempty"`
+	MaxStock       *int             `json:"max_stock,omitempty"`
+	NameContains   string           `json:"name_contains"`
+	CategoryID     int64            `json:"category_id"`
+	IncludeDeleted bool             `json:"include_deleted"`
+	OrderBy        string           `json:"order_by"`
+	SortBy         ProductSortField `json:"-"`
+	SortDesc       bool             `json:"-"`
+}
+
+// ProductPage is a single page of a product listing.
+type ProductPage struct {
+	Products      []*Product `json:"products"`
+	NextPageToken string     `json:"next_page_token"`
+	TotalCount    int64      `json:"total_count"`
+}
+
+// SearchProductsRequest is used for full-text product search.
+//
+// UserID narrows the search to a single owner when non-zero.
+type SearchProductsRequest struct {
+	Query     string `json:"query"`
+	UserID    int64  `json:"user_id"`
+	PageSize  int    `json:"page_size"`
+	PageToken string `json:"page_token"`
+}
+
+// ProductSearchHit is a ranked search match with highlighted snippets.
+type ProductSearchHit struct {
+	Product              *Product `json:"product"`
+	Rank                 float64  `json:"rank"`
+	NameHighlight        string   `json:"name_highlight"`
+	DescriptionHighlight string   `json:"description_highlight"`
+}
+
+// ProductSearchResult is a single page of search hits ordered by rank.
+type ProductSearchResult struct {
+	Hits          []*ProductSearchHit `json:"hits"`
+	NextPageToken string              `json:"next_page_token"`
+	TotalCount    int64               `json:"total_count"`
+}
+
+// BatchCreateProductsRequest creates several products in one call. With
+// AllOrNothing, any failing item fails the whole batch and nothing is
+// created; otherwise every item succeeds or fails on its own.
+type BatchCreateProductsRequest struct {
+	Products     []*CreateProductRequest `json:"products"`
+	AllOrNothing bool                    `json:"all_or_nothing"`
+}
+
+// ProductResult is the outcome of one item of a batch operation, in request
+// order. Err is nil when the item succeeded.
+type ProductResult struct {
+	ID      int64    `json:"id"`
+	Product *Product `json:"product,omitempty"`
+	Err     error    `json:"-"`
+}
+
+// ImportProgress reports how far a streamed import has got. The counts are
+// running totals; Errors holds only the items that failed since the
+// previous report.
+type ImportProgress struct {
+	Received int64          `json:"received"`
+	Created  int64          `json:"created"`
+	Failed   int64          `json:"failed"`
+	Errors   []*ImportError `json:"errors,omitempty"`
+	Done     bool           `json:"done"`
+}
+
+// ImportError is an imported item that could not be created, identified by
+// its zero-based position in the stream.
+type ImportError struct {
+	Index int64 `json:"index"`
+	Err   error `json:"-"`
 }
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// ProductEventType says what happened to a product.
+type ProductEventType string
+
+const (
+	ProductCreated ProductEventType = "created"
+	ProductUpdated ProductEventType = "updated"
+	ProductDeleted ProductEventType = "deleted"
+)
+
+// ProductEventCursor is a position in the product change feed. Events are
+// ordered by the transaction that wrote them, then by ID.
+type ProductEventCursor struct {
+	TxID int64
+	ID   int64
+}
+
+// ProductEvent is a change to a product as seen by WatchProducts.
+//
+// Product is the product's state when the event is delivered, not when it
+// happened, and is nil for deletions and for products deleted since.
+type ProductEvent struct {
+	ID         int64            `json:"id" db:"id"`
+	TxID       int64            `json:"-" db:"txid"`
+	Type       ProductEventType `json:"type" db:"event_type"`
+	ProductID  int64            `json:"product_id" db:"product_id"`
+	UserID     int64            `json:"user_id" db:"user_id"`
+	OccurredAt time.Time        `json:"occurred_at" db:"occurred_at"`
+	Product    *Product         `json:"product,omitempty"`
+	// ResumeToken reopens the feed right after this event
+	ResumeToken string `json:"resume_token"`
+}
+
+// Position returns the feed position just after e.
+func (e *ProductEvent) Position() ProductEventCursor {
+	return ProductEventCursor{TxID: e.TxID, ID: e.ID}
+}
+
+// ProductEventFilter narrows the change feed to one owner and/or a set of
+// products; the zero value matches every event.
+type ProductEventFilter struct {
+	UserID     int64   `json:"user_id"`
+	ProductIDs []int64 `json:"product_ids"`
+}
+
+// WatchProductsRequest opens a product change feed. Without a ResumeToken
+// the feed starts with changes committed from now on; with one it resumes
+// right after the event that carried the token.
+type WatchProductsRequest struct {
+	ProductEventFilter
+	ResumeToken string `json:"resume_token"`
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// ReservationStatus is the lifecycle state of a stock reservation.
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationCommitted ReservationStatus = "committed"
+)
+
+// StockReservation holds units of a product for a caller until it expires.
+//
+// Reserved units are taken out of Product.Stock immediately. Releasing or
+// expiring the reservation puts them back; committing keeps them sold.
+type StockReservation struct {
+	ID        int64             `json:"id" db:"id"`
+	ProductID int64             `json:"product_id" db:"product_id"`
+	UserID    int64             `json:"user_id" db:"user_id"`
+	Quantity  int               `json:"quantity" db:"quantity"`
+	Status    ReservationStatus `json:"status" db:"status"`
+	ExpiresAt time.Time         `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// ReleasedStock is what expiring reservations returned to one product.
+type ReleasedStock struct {
+	ProductID    int64
+	Reservations int64
+	Quantity     int
+	// Stock is the product's stock after the units were returned
+	Stock int
+}
+
+// ReserveStockRequest is used when placing a hold on product stock.
+//
+// A zero TTL uses the configured default reservation lifetime.
+type ReserveStockRequest struct {
+	ProductID int64         `json:"product_id"`
+	Quantity  int           `json:"quantity"`
+	TTL       time.Duration `json:"ttl"`
+}
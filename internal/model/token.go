@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// RefreshToken is a stored, hashed refresh token.
+//
+// Tokens issued by rotating one another share a FamilyID, which is also the
+// session ID embedded in access tokens. UsedAt is set once a token has been
+// exchanged; presenting it again revokes the whole family.
+type RefreshToken struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    int64      `json:"user_id" db:"user_id"`
+	FamilyID  string     `json:"family_id" db:"family_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
@@ -5,13 +5,47 @@ import (
 )
 
 type User struct {
-	ID        int64     `json:"id" db:"id"`
-	Username  string    `json:"username" db:"username"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"-" db:"password"` // Hidden in JSON
-	FullName  string    `json:"full_name" db:"full_name"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int64      `json:"id" db:"id"`
+	Username  string     `json:"username" db:"username"`
+	Email     string     `json:"email" db:"email"`
+	Password  string     `json:"-" db:"password"` // Hidden in JSON
+	FullName  string     `json:"full_name" db:"full_name"`
+	Roles     []string   `json:"roles" db:"roles"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// Well-known user roles.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Caller identifies the authenticated user on whose behalf a request runs.
+//
+// Internal services authenticated by client certificate have a Service
+// name and no UserID; they act on other users' resources only through
+// their roles, e.g. admin.
+type Caller struct {
+	UserID  int64
+	Service string
+	Roles   []string
+}
+
+// HasRole reports whether the caller holds the given role.
+func (c Caller) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the caller may act on any user's resources.
+func (c Caller) IsAdmin() bool {
+	return c.HasRole(RoleAdmin)
 }
 
 type RegisterRequest struct {
@@ -21,12 +55,23 @@ type RegisterRequest struct {
 	FullName string `json:"full_name" validate:"required,min=2,max=100"`
 }
 
+// UpdateProfileRequest changes the caller's own profile. UpdateMask names
+// the fields to change; when empty, every field is replaced.
+type UpdateProfileRequest struct {
+	Username   string   `json:"username" validate:"omitempty,min=3,max=50"`
+	Email      string   `json:"email" validate:"omitempty,email"`
+	FullName   string   `json:"full_name" validate:"omitempty,min=2,max=100"`
+	UpdateMask []string `json:"update_mask"`
+}
+
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	User         *User     `json:"user"`
 }
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is an HTTP endpoint a seller registered for their products' events.
+//
+// Empty EventTypes or ProductIDs match everything; otherwise an event is sent
+// only when both lists match it. Secret signs every delivery and is only
+// shown to the owner when the webhook is created.
+type Webhook struct {
+	ID         int64     `json:"id" db:"id"`
+	UserID     int64     `json:"user_id" db:"user_id"`
+	URL        string    `json:"url" db:"url"`
+	Secret     string    `json:"-" db:"secret"`
+	EventTypes []string  `json:"event_types" db:"event_types"`
+	ProductIDs []int64   `json:"product_ids" db:"product_ids"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookEventTypes are the events a webhook can subscribe to.
+var WebhookEventTypes = []string{EventProductCreated, EventProductUpdated, EventStockChanged}
+
+// CreateWebhookRequest is used when registering a webhook.
+//
+// A secret is generated when Secret is empty.
+type CreateWebhookRequest struct {
+	UserID     int64    `json:"user_id"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+	ProductIDs []int64  `json:"product_ids"`
+}
+
+// Webhook delivery states. A pending delivery is retried until it succeeds
+// or runs out of attempts and becomes dead.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery is one event queued for one webhook.
+//
+// Payload is the JSON body sent, the same for every attempt. Webhook is only
+// loaded for deliveries claimed for sending.
+type WebhookDelivery struct {
+	ID             int64             `json:"id" db:"id"`
+	WebhookID      int64             `json:"webhook_id" db:"webhook_id"`
+	EventID        int64             `json:"event_id" db:"event_id"`
+	EventType      string            `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage   `json:"payload" db:"payload"`
+	Status         string            `json:"status" db:"status"`
+	Attempts       int               `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode int               `json:"last_status_code" db:"last_status_code"`
+	LastError      string            `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty" db:"delivered_at"`
+	Webhook        *Webhook          `json:"-"`
+	AttemptLog     []*WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt records one HTTP request made for a delivery.
+//
+// StatusCode is zero when no response arrived; Error then says why.
+type WebhookAttempt struct {
+	ID          int64         `json:"id" db:"id"`
+	DeliveryID  int64         `json:"delivery_id" db:"delivery_id"`
+	Attempt     int           `json:"attempt" db:"attempt"`
+	StatusCode  int           `json:"status_code" db:"status_code"`
+	Error       string        `json:"error" db:"error"`
+	Duration    time.Duration `json:"duration" db:"duration_ms"`
+	AttemptedAt time.Time     `json:"attempted_at" db:"attempted_at"`
+}
+
+// ListDeliveriesRequest is used when browsing a webhook's deliveries, newest
+// first. A non-empty Status narrows the list to deliveries in that state.
+type ListDeliveriesRequest struct {
+	WebhookID int64  `json:"webhook_id"`
+	Status    string `json:"status"`
+	PageSize  int    `json:"page_size"`
+	PageToken string `json:"page_token"`
+}
+
+// DeliveryPage is one page of webhook deliveries.
+type DeliveryPage struct {
+	Deliveries    []*WebhookDelivery `json:"deliveries"`
+	NextPageToken string             `json:"next_page_token"`
+}
@@ -0,0 +1,27 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+
+	"grpc-exmpl/internal/model"
+)
+
+type fanout []Publisher
+
+// NewFanout creates a Publisher handing every event to each of publishers.
+// It fails when any of them does, so the whole set sees the event again.
+func NewFanout(publishers ...Publisher) Publisher {
+	return fanout(publishers)
+}
+
+// Publish publishes event to every publisher, even after one fails
+func (f fanout) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	var errs []error
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,16 @@
+// Package publisher delivers outbox events to systems outside the service.
+package publisher
+
+import (
+	"context"
+
+	"grpc-exmpl/internal/model"
+)
+
+// Publisher sends one domain event to its destination.
+//
+// Delivery is at least once: the outbox relay retries an event until
+// Publish returns nil, so implementations may see the same event ID twice.
+type Publisher interface {
+	Publish(ctx context.Context, event *model.OutboxEvent) error
+}
@@ -0,0 +1,47 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"grpc-exmpl/internal/model"
+)
+
+// WriterPublisher writes each event as one line of JSON.
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterPublisher creates a Publisher writing JSON lines to w
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// NewFilePublisher creates a Publisher appending JSON lines to the file at path
+func NewFilePublisher(path string) (*WriterPublisher, io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+	return NewWriterPublisher(f), f, nil
+}
+
+// Publish writes the event
+func (p *WriterPublisher) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// CategoryRepository defines contract for category tree persistence
+type CategoryRepository interface {
+	Create(ctx context.Context, category *model.Category) error
+	GetByID(ctx context.Context, id int64) (*model.Category, error)
+	List(ctx context.Context, rootID int64) ([]*model.Category, error)
+	Update(ctx context.Context, category *model.Category) error
+	Delete(ctx context.Context, id int64) error
+}
+
+var (
+	// ErrCategoryCycle is returned when moving a category under itself or one of its descendants
+	ErrCategoryCycle = apperror.Invalid("parent_id", "a category cannot be moved under itself or its descendants")
+	// ErrCategoryNotEmpty is returned when deleting a category that still has subcategories
+	ErrCategoryNotEmpty = apperror.Conflict("CATEGORY_NOT_EMPTY", "category still has subcategories")
+)
+
+// categorySubtree is a recursive CTE selecting the ids of category $1 and
+// all of its descendants. Callers append their own SELECT.
+const categorySubtree = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE id = $1
+		UNION ALL
+		SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+	)
+`
+
+type categoryRepository struct {
+	db *sql.DB
+}
+
+// NewCategoryRepository creates a new instance of CategoryRepository
+func NewCategoryRepository(db *sql.DB) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+func (r *categoryRepository) Create(ctx context.Context, category *model.Category) error {
+	query := `
+		INSERT INTO categories (parent_id, name, slug, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	now := time.Now()
+	category.CreatedAt = now
+	category.UpdatedAt = now
+
+	err := dbFrom(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		nullableID(category.ParentID),
+		category.Name,
+		category.Slug,
+		category.CreatedAt,
+		category.UpdatedAt,
+	).Scan(&category.ID)
+	if err != nil {
+		if mapped := mapCategoryError(err); mapped != nil {
+			return mapped
+		}
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+
+	return nil
+}
+
+func (r *categoryRepository) GetByID(ctx context.Context, id int64) (*model.Category, error) {
+	query := `
+		SELECT id, COALESCE(parent_id, 0), name, slug, created_at, updated_at
+		FROM categories WHERE id = $1
+	`
+
+	c := &model.Category{}
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&c.ID,
+		&c.ParentID,
+		&c.Name,
+		&c.Slug,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("category")
+		}
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+
+	return c, nil
+}
+
+// List returns the whole tree, or the subtree under rootID when it is
+// non-zero, ordered so that every parent precedes its children.
+func (r *categoryRepository) List(ctx context.Context, rootID int64) ([]*model.Category, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, parent_id, name, slug, created_at, updated_at, 0 AS depth
+			FROM categories
+			WHERE ($1::bigint = 0 AND parent_id IS NULL) OR id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.slug, c.created_at, c.updated_at, t.depth + 1
+			FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT id, COALESCE(parent_id, 0), name, slug, created_at, updated_at
+		FROM tree
+		ORDER BY depth, name, id
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*model.Category
+	for rows.Next() {
+		c := &model.Category{}
+		if err := rows.Scan(
+			&c.ID,
+			&c.ParentID,
+			&c.Name,
+			&c.Slug,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	if rootID != 0 && len(categories) == 0 {
+		return nil, apperror.NotFound("category")
+	}
+
+	return categories, nil
+}
+
+// Update renames or moves a category. Moving it under its own subtree is
+// refused in the same statement, so concurrent moves cannot form a cycle
+// the check did not see.
+func (r *categoryRepository) Update(ctx context.Context, category *model.Category) error {
+	query := categorySubtree + `
+		UPDATE categories
+		SET parent_id = $2, name = $3, slug = $4, updated_at = $5
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM subtree WHERE id = $2)
+	`
+
+	category.UpdatedAt = time.Now()
+
+	db := dbFrom(ctx, r.db)
+	result, err := db.ExecContext(
+		ctx,
+		query,
+		category.ID,
+		nullableID(category.ParentID),
+		category.Name,
+		category.Slug,
+		category.UpdatedAt,
+	)
+	if err != nil {
+		if mapped := mapCategoryError(err); mapped != nil {
+			return mapped
+		}
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		var exists bool
+		if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, category.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check category: %w", err)
+		}
+		if !exists {
+			return apperror.NotFound("category")
+		}
+		return ErrCategoryCycle
+	}
+
+	return nil
+}
+
+// Delete removes a leaf category; its products become uncategorized
+func (r *categoryRepository) Delete(ctx context.Context, id int64) error {
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+			return ErrCategoryNotEmpty
+		}
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return apperror.NotFound("category")
+	}
+
+	return nil
+}
+
+// mapCategoryError translates constraint violations on categories into domain errors
+func mapCategoryError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return nil
+	}
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return apperror.AlreadyExists("category", "slug")
+	case "23503": // foreign_key_violation
+		return apperror.Invalid("parent_id", "parent category does not exist")
+	}
+	return nil
+}
+
+// nullableID maps a zero id to SQL NULL
+func nullableID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+
+	"grpc-exmpl/internal/model"
+)
+
+// decimalAmount scans a NUMERIC money column into minor units without
+// passing through float64. Amounts are written back with Money.Decimal.
+type decimalAmount struct {
+	dst *int64
+}
+
+func (d decimalAmount) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*d.dst = v * model.MinorUnitsPerMajor
+		return nil
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("cannot scan %T into a money amount", src)
+	}
+
+	amount, err := model.ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d.dst = amount
+	return nil
+}
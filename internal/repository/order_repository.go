@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// OrderRepository defines contract for order persistence
+type OrderRepository interface {
+	Create(ctx context.Context, order *model.Order) error
+	GetByID(ctx context.Context, id int64) (*model.Order, error)
+	ListByUserID(ctx context.Context, req *model.ListOrdersRequest) (*model.OrderPage, error)
+	Cancel(ctx context.Context, id int64) error
+}
+
+// ErrOrderNotCancellable is returned when cancelling an order that is no longer placed
+var ErrOrderNotCancellable = apperror.Conflict("ORDER_NOT_CANCELLABLE", "order can no longer be cancelled")
+
+// orderCursorSort tags page tokens issued by order listings
+const orderCursorSort = "order_id"
+
+type orderRepository struct {
+	db *sql.DB
+}
+
+// NewOrderRepository creates a new instance of OrderRepository
+func NewOrderRepository(db *sql.DB) OrderRepository {
+	return &orderRepository{db: db}
+}
+
+// Create inserts an order and its items. Items must already carry their
+// product name and price snapshot; callers take the stock and create the
+// order inside one TxManager unit of work.
+func (r *orderRepository) Create(ctx context.Context, order *model.Order) error {
+	db := dbFrom(ctx, r.db)
+
+	now := time.Now()
+	order.Status = model.OrderPlaced
+	order.CreatedAt = now
+	order.UpdatedAt = now
+
+	err := db.QueryRowContext(
+		ctx,
+		`INSERT INTO orders (user_id, status, total_amount, currency, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		order.UserID,
+		order.Status,
+		order.TotalAmount.Decimal(),
+		order.TotalAmount.Currency,
+		order.CreatedAt,
+		order.UpdatedAt,
+	).Scan(&order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+
+	for _, item := range order.Items {
+		item.OrderID = order.ID
+		err := db.QueryRowContext(
+			ctx,
+			`INSERT INTO order_items (order_id, product_id, product_name, quantity, unit_price)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id`,
+			item.OrderID,
+			item.ProductID,
+			item.ProductName,
+			item.Quantity,
+			item.UnitPrice.Decimal(),
+		).Scan(&item.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create order item: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *orderRepository) GetByID(ctx context.Context, id int64) (*model.Order, error) {
+	query := `
+		SELECT id, user_id, status, total_amount, currency, created_at, updated_at
+		FROM orders WHERE id = $1
+	`
+
+	o := &model.Order{}
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&o.ID,
+		&o.UserID,
+		&o.Status,
+		decimalAmount{&o.TotalAmount.Amount},
+		&o.TotalAmount.Currency,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order")
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if err := r.loadItems(ctx, []*model.Order{o}); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// ListByUserID returns a page of a user's orders, newest first
+func (r *orderRepository) ListByUserID(ctx context.Context, req *model.ListOrdersRequest) (*model.OrderPage, error) {
+	var afterID int64
+	if req.PageToken != "" {
+		cursor, err := decodePageCursor(req.PageToken)
+		if err != nil || cursor.Sort != orderCursorSort {
+			return nil, apperror.Invalid("page_token", "invalid page token")
+		}
+		if cursor.Query != queryHash(req.UserID) {
+			return nil, apperror.Invalid("page_token", "page token was issued for a different query")
+		}
+		afterID = cursor.ID
+	}
+
+	query := `
+		SELECT id, user_id, status, total_amount, currency, created_at, updated_at
+		FROM orders
+		WHERE user_id = $1 AND ($2::bigint = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, req.UserID, afterID, req.PageSize+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*model.Order
+	for rows.Next() {
+		o := &model.Order{}
+		if err := rows.Scan(
+			&o.ID,
+			&o.UserID,
+			&o.Status,
+			decimalAmount{&o.TotalAmount.Amount},
+			&o.TotalAmount.Currency,
+			&o.CreatedAt,
+			&o.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	page := &model.OrderPage{}
+	if len(orders) > req.PageSize {
+		orders = orders[:req.PageSize]
+		token, err := encodePageCursor(pageCursor{Sort: orderCursorSort, Desc: true, ID: orders[len(orders)-1].ID, Query: queryHash(req.UserID)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode page token: %w", err)
+		}
+		page.NextPageToken = token
+	}
+
+	if err := r.loadItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	page.Orders = orders
+
+	return page, nil
+}
+
+// Cancel moves a placed order to cancelled. Returning its stock is up to
+// the caller, in the same unit of work.
+func (r *orderRepository) Cancel(ctx context.Context, id int64) error {
+	result, err := dbFrom(ctx, r.db).ExecContext(
+		ctx,
+		`UPDATE orders SET status = $2, updated_at = $4 WHERE id = $1 AND status = $3`,
+		id,
+		model.OrderCancelled,
+		model.OrderPlaced,
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel order: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrOrderNotCancellable
+	}
+
+	return nil
+}
+
+// loadItems fetches the items of all given orders with a single query
+func (r *orderRepository) loadItems(ctx context.Context, orders []*model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*model.Order, len(orders))
+	ids := make([]int64, 0, len(orders))
+	for _, o := range orders {
+		byID[o.ID] = o
+		ids = append(ids, o.ID)
+	}
+
+	query := `
+		SELECT i.id, i.order_id, COALESCE(i.product_id, 0), i.product_name, i.quantity, i.unit_price, o.currency
+		FROM order_items i
+		JOIN orders o ON o.id = i.order_id
+		WHERE i.order_id = ANY($1)
+		ORDER BY i.order_id, i.id
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to load order items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &model.OrderItem{}
+		if err := rows.Scan(
+			&item.ID,
+			&item.OrderID,
+			&item.ProductID,
+			&item.ProductName,
+			&item.Quantity,
+			decimalAmount{&item.UnitPrice.Amount},
+			&item.UnitPrice.Currency,
+		); err != nil {
+			return fmt.Errorf("failed to scan order item: %w", err)
+		}
+		if o, ok := byID[item.OrderID]; ok {
+			o.Items = append(o.Items, item)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+)
+
+// OutboxRepository stores domain events until the relay has published them
+type OutboxRepository interface {
+	// Add records events; call it inside the transaction making the change
+	Add(ctx context.Context, events ...*model.OutboxEvent) error
+	// Claim leases up to limit due events, oldest first and at most one per
+	// aggregate, so concurrent relays never publish the same event at the
+	// same time nor an aggregate's events out of order
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxEvent, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	// Retry records a failed attempt and schedules the next one
+	Retry(ctx context.Context, id int64, lastErr string, at time.Time) error
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository creates a new instance of OutboxRepository
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Add(ctx context.Context, events ...*model.OutboxEvent) error {
+	query := `
+		INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	db := dbFrom(ctx, r.db)
+	for _, e := range events {
+		e.CreatedAt = time.Now()
+		err := db.QueryRowContext(ctx, query, e.Type, e.AggregateType, e.AggregateID, []byte(e.Payload), e.CreatedAt).Scan(&e.ID)
+		if err != nil {
+			return fmt.Errorf("failed to add %s event: %w", e.Type, err)
+		}
+	}
+	return nil
+}
+
+func (r *outboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxEvent, error) {
+	// Pushing next_attempt_at past the lease hides claimed rows from other
+	// relays; if this one dies mid-batch they become due again afterwards.
+	// Only the earliest undelivered event of each aggregate is a candidate:
+	// the next one waits until it is marked delivered, not merely leased,
+	// because a relay racing this one skips a locked row and still sees its
+	// old next_attempt_at.
+	query := `
+		UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT o.id FROM outbox o
+			WHERE o.delivered_at IS NULL AND o.next_attempt_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox prev
+				WHERE prev.aggregate_type = o.aggregate_type AND prev.aggregate_id = o.aggregate_id
+				AND prev.id < o.id AND prev.delivered_at IS NULL
+			)
+			ORDER BY o.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_type, aggregate_id, payload, created_at, attempts
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*model.OutboxEvent
+	for rows.Next() {
+		e := &model.OutboxEvent{}
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateType, &e.AggregateID, &payload, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	// RETURNING does not keep the subquery's order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (r *outboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	query := `UPDATE outbox SET delivered_at = $2, last_error = NULL WHERE id = $1`
+
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event delivered: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return apperror.NotFound("outbox event")
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) Retry(ctx context.Context, id int64, lastErr string, at time.Time) error {
+	query := `UPDATE outbox SET next_attempt_at = $2, last_error = $3 WHERE id = $1`
+
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, query, id, at, lastErr)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox event: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return apperror.NotFound("outbox event")
+	}
+
+	return nil
+}
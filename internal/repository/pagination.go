@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-exmpl/internal/model"
+)
+
+// sortColumn describes how a sort field maps onto a keyset-paginated column.
+type sortColumn struct {
+	name  string
+	cast  string
+	value func(p *model.Product) string
+}
+
+var productSortColumns = map[model.ProductSortField]sortColumn{
+	model.ProductSortCreatedAt: {
+		name:  "created_at",
+		cast:  "timestamptz",
+		value: func(p *model.Product) string { return p.CreatedAt.Format(time.RFC3339Nano) },
+	},
+	model.ProductSortPrice: {
+		name:  "price",
+		cast:  "numeric",
+		value: func(p *model.Product) string { return p.Price.Decimal() },
+	},
+	model.ProductSortName: {
+		name:  "name",
+		cast:  "text",
+		value: func(p *model.Product) string { return p.Name },
+	},
+	model.ProductSortStock: {
+		name:  "stock",
+		cast:  "integer",
+		value: func(p *model.Product) string { return strconv.Itoa(p.Stock) },
+	},
+}
+
+// searchCursorSort marks page tokens issued by full-text search, whose
+// relevance ordering is paginated by offset rather than by keyset.
+const searchCursorSort = "relevance"
+
+// pageCursor is the keyset position encoded into an opaque page token.
+// Query fingerprints the request the token was issued for, so it cannot be
+// replayed against a different owner, filter or sort.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int64  `json:"i"`
+	Query string `json:"q"`
+}
+
+// queryHash fingerprints the parameters that select and order a listing
+func queryHash(params ...interface{}) string {
+	b, _ := json.Marshal(params)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// productListHash fingerprints everything in req except the page position
+func productListHash(req *model.ListProductsRequest) string {
+	return queryHash(req.UserID, req.MinPrice, req.MaxPrice, req.MinStock, req.MaxStock,
+		req.NameContains, req.CategoryID, req.IncludeDeleted, req.SortBy, req.SortDesc)
+}
+
+func encodePageCursor(c pageCursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodePageCursor(token string) (pageCursor, error) {
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-exmpl/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// ProductEventRepository reads the product change feed written by the
+// products_record_event trigger.
+//
+// Event IDs come from a sequence, so a transaction can commit an event with
+// a lower ID after a reader has already seen a higher one. Reading in
+// (txid, id) order and only up to the oldest transaction still running
+// avoids that: every event before the returned position is final. Only
+// transactions that have written something have a txid, so read-only ones,
+// such as a product export, never hold the feed back.
+type ProductEventRepository interface {
+	// Head returns the position after every event that is already final
+	Head(ctx context.Context) (model.ProductEventCursor, error)
+	// ListAfter returns up to limit final events past after that match
+	// filter, in feed order. heldBack reports that further committed events
+	// are waiting for an older transaction to finish; no notification
+	// announces when it does.
+	ListAfter(ctx context.Context, after model.ProductEventCursor, filter *model.ProductEventFilter, limit int) (events []*model.ProductEvent, heldBack bool, err error)
+	Exists(ctx context.Context, id int64) (bool, error)
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+type productEventRepository struct {
+	db *sql.DB
+}
+
+// NewProductEventRepository creates a new instance of ProductEventRepository
+func NewProductEventRepository(db *sql.DB) ProductEventRepository {
+	return &productEventRepository{db: db}
+}
+
+func (r *productEventRepository) Head(ctx context.Context) (model.ProductEventCursor, error) {
+	// Transactions below xmin have all finished; everything from xmin on
+	// may still write events, so the feed starts at (xmin, 0)
+	var xmin int64
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, `SELECT txid_snapshot_xmin(txid_current_snapshot())`).Scan(&xmin)
+	if err != nil {
+		return model.ProductEventCursor{}, fmt.Errorf("failed to read feed head: %w", err)
+	}
+	return model.ProductEventCursor{TxID: xmin}, nil
+}
+
+func (r *productEventRepository) ListAfter(ctx context.Context, after model.ProductEventCursor, filter *model.ProductEventFilter, limit int) ([]*model.ProductEvent, bool, error) {
+	conditions := []string{"(txid, id) > ($1, $2)"}
+	args := []interface{}{after.TxID, after.ID}
+	if filter.UserID > 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, "user_id = $"+strconv.Itoa(len(args)))
+	}
+	if len(filter.ProductIDs) > 0 {
+		args = append(args, pq.Array(filter.ProductIDs))
+		conditions = append(conditions, "product_id = ANY($"+strconv.Itoa(len(args))+")")
+	}
+	args = append(args, limit)
+
+	// Rows from xmin on are read too, only to learn that events are being
+	// held back; feed order puts them after every final row
+	query := `
+		SELECT id, txid, event_type, product_id, user_id, occurred_at,
+			txid < txid_snapshot_xmin(txid_current_snapshot()) AS final
+		FROM product_events
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY txid, id
+		LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list product events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*model.ProductEvent
+	heldBack := false
+	for rows.Next() {
+		e := &model.ProductEvent{}
+		var final bool
+		if err := rows.Scan(&e.ID, &e.TxID, &e.Type, &e.ProductID, &e.UserID, &e.OccurredAt, &final); err != nil {
+			return nil, false, fmt.Errorf("failed to scan product event: %w", err)
+		}
+		if !final {
+			heldBack = true
+			break
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("rows error: %w", err)
+	}
+
+	return events, heldBack, nil
+}
+
+// Exists reports whether an event is still retained
+func (r *productEventRepository) Exists(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM product_events WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check product event: %w", err)
+	}
+	return exists, nil
+}
+
+// Prune deletes events that happened before the cutoff; their resume tokens stop working
+func (r *productEventRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, `DELETE FROM product_events WHERE occurred_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune product events: %w", err)
+	}
+	return result.RowsAffected()
+}
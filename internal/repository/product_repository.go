@@ -1,22 +1,47 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"grpc-exmpl/internal/apperror"
 	"grpc-exmpl/internal/model"
+
+	"github.com/lib/pq"
 )
 
 // ProductRepository defines contract for product operations
 type ProductRepository interface {
-	Create(product *model.Product) error
-	GetByID(id int64) (*model.Product, error)
-	ListByUserID(userID int64) ([]*model.Product, error)
-	Update(product *model.Product) error
-	Delete(id int64) error
+	Create(ctx context.Context, product *model.Product) error
+	CreateBatch(ctx context.Context, products []*model.Product) error
+	GetByID(ctx context.Context, id int64) (*model.Product, error)
+	GetByIDs(ctx context.Context, ids []int64) ([]*model.Product, error)
+	ListByUserID(ctx context.Context, req *model.ListProductsRequest) (*model.ProductPage, error)
+	Search(ctx context.Context, req *model.SearchProductsRequest) (*model.ProductSearchResult, error)
+	Export(ctx context.Context, userID int64, fetchSize int, fn func(*model.Product) error) error
+	Update(ctx context.Context, product *model.Product, fields []string) error
+	AdjustStock(ctx context.Context, id int64, delta int) (int, error)
+	SetTags(ctx context.Context, id int64, tags []string) error
+	Delete(ctx context.Context, id int64) error
+	DeleteBatch(ctx context.Context, ids []int64) ([]int64, error)
+	GetDeleted(ctx context.Context, id int64) (*model.Product, error)
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
+var (
+	// ErrInsufficientStock is returned when a stock change would take a product below zero
+	ErrInsufficientStock = apperror.Conflict("INSUFFICIENT_STOCK", "insufficient stock")
+	// ErrProductOwnerDeleted is returned when restoring a product whose owner is deleted
+	ErrProductOwnerDeleted = apperror.Conflict("OWNER_DELETED", "the product owner is deleted; restore the user instead")
+	// ErrVersionMismatch is returned when a product changed since the version the caller read
+	ErrVersionMismatch = apperror.Conflict("VERSION_MISMATCH", "product was modified by someone else; reload it and retry")
+)
+
 type productRepository struct {
 	db *sql.DB
 }
@@ -26,72 +51,163 @@ func NewProductRepository(db *sql.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) Create(product *model.Product) error {
+func (r *productRepository) Create(ctx context.Context, product *model.Product) error {
 	query := `
-		INSERT INTO products (name, description, price, stock, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id
+		INSERT INTO products (name, description, price, currency, stock, user_id, category_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, version
 	`
 
 	now := time.Now()
 	product.CreatedAt = now
 	product.UpdatedAt = now
 
-	err := r.db.QueryRow(
+	err := dbFrom(ctx, r.db).QueryRowContext(
+		ctx,
 		query,
 		product.Name,
 		product.Description,
-		product.Price,
+		product.Price.Decimal(),
+		product.Price.Currency,
 		product.Stock,
 		product.UserID,
+		nullableID(product.CategoryID),
 		product.CreatedAt,
 		product.UpdatedAt,
-	).Scan(&product.ID)
+	).Scan(&product.ID, &product.Version)
 	if err != nil {
+		if mapped := mapProductError(err); mapped != nil {
+			return mapped
+		}
 		return fmt.Errorf("failed to create product: %w", err)
 	}
 
 	return nil
 }
 
-func (r *productRepository) GetByID(id int64) (*model.Product, error) {
+// CreateBatch inserts products with a single statement, taking each column
+// as an array so the batch size is not bound by the parameter limit. Rows
+// are inserted and returned in input order, which is how ids are matched
+// back to products.
+func (r *productRepository) CreateBatch(ctx context.Context, products []*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
 	query := `
-		SELECT id, name, description, price, stock, user_id, created_at, updated_at
-		FROM products WHERE id = $1
+		INSERT INTO products (name, description, price, currency, stock, user_id, category_id, created_at, updated_at)
+		SELECT name, description, price, currency, stock, user_id, NULLIF(category_id, 0), $8, $8
+		FROM unnest($1::text[], $2::text[], $3::numeric[], $4::text[], $5::int[], $6::bigint[], $7::bigint[])
+			WITH ORDINALITY AS t(name, description, price, currency, stock, user_id, category_id, ord)
+		ORDER BY ord
+		RETURNING id, version
+	`
+
+	now := time.Now()
+	n := len(products)
+	names, descriptions, prices, currencies := make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	stocks, userIDs, categoryIDs := make([]int64, n), make([]int64, n), make([]int64, n)
+	for i, p := range products {
+		p.CreatedAt = now
+		p.UpdatedAt = now
+		names[i] = p.Name
+		descriptions[i] = p.Description
+		prices[i] = p.Price.Decimal()
+		currencies[i] = p.Price.Currency
+		stocks[i] = int64(p.Stock)
+		userIDs[i] = p.UserID
+		categoryIDs[i] = p.CategoryID
+	}
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(
+		ctx,
+		query,
+		pq.Array(names),
+		pq.Array(descriptions),
+		pq.Array(prices),
+		pq.Array(currencies),
+		pq.Array(stocks),
+		pq.Array(userIDs),
+		pq.Array(categoryIDs),
+		now,
+	)
+	if err != nil {
+		if mapped := mapProductError(err); mapped != nil {
+			return mapped
+		}
+		return fmt.Errorf("failed to create products: %w", err)
+	}
+	defer rows.Close()
+
+	i := 0
+	for rows.Next() {
+		if i == n {
+			return fmt.Errorf("failed to create products: more rows returned than inserted")
+		}
+		if err := rows.Scan(&products[i].ID, &products[i].Version); err != nil {
+			return fmt.Errorf("failed to scan product id: %w", err)
+		}
+		i++
+	}
+
+	if err := rows.Err(); err != nil {
+		if mapped := mapProductError(err); mapped != nil {
+			return mapped
+		}
+		return fmt.Errorf("rows error: %w", err)
+	}
+	if i != n {
+		return fmt.Errorf("failed to create products: %d of %d rows returned", i, n)
+	}
+
+	return nil
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id int64) (*model.Product, error) {
+	query := `
+		SELECT id, name, description, price, currency, stock, user_id, COALESCE(category_id, 0), created_at, updated_at, version
+		FROM products WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	p := &model.Product{}
-	err := r.db.QueryRow(query, id).Scan(
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&p.ID,
 		&p.Name,
 		&p.Description,
-		&p.Price,
+		decimalAmount{&p.Price.Amount},
+		&p.Price.Currency,
 		&p.Stock,
 		&p.UserID,
+		&p.CategoryID,
 		&p.CreatedAt,
 		&p.UpdatedAt,
+		&p.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product not found")
+			return nil, apperror.NotFound("product")
 		}
 		return nil, fmt.Errorf("failed to get product: %w", err)
 	}
 
+	if err := r.loadTags(ctx, []*model.Product{p}); err != nil {
+		return nil, err
+	}
+
 	return p, nil
 }
 
-func (r *productRepository) ListByUserID(userID int64) ([]*model.Product, error) {
+// GetByIDs returns the live products among ids, in no particular order;
+// missing ids are simply absent from the result
+func (r *productRepository) GetByIDs(ctx context.Context, ids []int64) ([]*model.Product, error) {
 	query := `
-		SELECT id, name, description, price, stock, user_id, created_at, updated_at
-		FROM products
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+		SELECT id, name, description, price, currency, stock, user_id, COALESCE(category_id, 0), created_at, updated_at, version
+		FROM products WHERE id = ANY($1) AND deleted_at IS NULL
 	`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
-		return nil, fmt.Errorf("failed to list products: %w", err)
+		return nil, fmt.Errorf("failed to get products: %w", err)
 	}
 	defer rows.Close()
 
@@ -102,11 +218,14 @@ func (r *productRepository) ListByUserID(userID int64) ([]*model.Product, error)
 			&p.ID,
 			&p.Name,
 			&p.Description,
-			&p.Price,
+			decimalAmount{&p.Price.Amount},
+			&p.Price.Currency,
 			&p.Stock,
 			&p.UserID,
+			&p.CategoryID,
 			&p.CreatedAt,
 			&p.UpdatedAt,
+			&p.Version,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan product: %w", err)
 		}
@@ -117,48 +236,521 @@ func (r *productRepository) ListByUserID(userID int64) ([]*model.Product, error)
 		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
+	if err := r.loadTags(ctx, products); err != nil {
+		return nil, err
+	}
+
 	return products, nil
 }
 
-func (r *productRepository) Update(product *model.Product) error {
+func (r *productRepository) ListByUserID(ctx context.Context, req *model.ListProductsRequest) (*model.ProductPage, error) {
+	sortCol, ok := productSortColumns[req.SortBy]
+	if !ok {
+		return nil, apperror.Invalid("order_by", fmt.Sprintf("unsupported sort field: %s", req.SortBy))
+	}
+
+	where := []string{"user_id = $1"}
+	if !req.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+	args := []interface{}{req.UserID}
+	addFilter := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if req.MinPrice != nil {
+		addFilter("currency = $%d", req.MinPrice.Currency)
+		addFilter("price >= $%d", req.MinPrice.Decimal())
+	}
+	if req.MaxPrice != nil {
+		addFilter("currency = $%d", req.MaxPrice.Currency)
+		addFilter("price <= $%d", req.MaxPrice.Decimal())
+	}
+	if req.MinStock != nil {
+		addFilter("stock >= $%d", *req.MinStock)
+	}
+	if req.MaxStock != nil {
+		addFilter("stock <= $%d", *req.MaxStock)
+	}
+	if req.NameContains != "" {
+		addFilter(`name ILIKE '%%' || $%d || '%%'`, escapeLike(req.NameContains))
+	}
+	if req.CategoryID != 0 {
+		addFilter(`category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = $%[1]d
+				UNION ALL
+				SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT id FROM subtree
+		)`, req.CategoryID)
+	}
+
+	page := &model.ProductPage{}
+	countQuery := "SELECT COUNT(*) FROM products WHERE " + strings.Join(where, " AND ")
+	if err := dbFrom(ctx, r.db).QueryRowContext(ctx, countQuery, args...).Scan(&page.TotalCount); err != nil {
+		return nil, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	if req.PageToken != "" {
+		cursor, err := decodePageCursor(req.PageToken)
+		if err != nil || cursor.Sort != string(req.SortBy) || cursor.Desc != req.SortDesc {
+			return nil, apperror.Invalid("page_token", "invalid page token")
+		}
+		if cursor.Query != productListHash(req) {
+			return nil, apperror.Invalid("page_token", "page token was issued for a different query")
+		}
+		op := ">"
+		if req.SortDesc {
+			op = "<"
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)",
+			sortCol.name, op, len(args)-1, sortCol.cast, len(args)))
+	}
+
+	dir := "ASC"
+	if req.SortDesc {
+		dir = "DESC"
+	}
+	args = append(args, req.PageSize+1)
+	query := fmt.Sprintf(`
+		SELECT id, name, description, price, currency, stock, user_id, COALESCE(category_id, 0), created_at, updated_at, version, deleted_at
+		FROM products
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT $%d
+	`, strings.Join(where, " AND "), sortCol.name, dir, dir, len(args))
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := &model.Product{}
+		if err := rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Description,
+			decimalAmount{&p.Price.Amount},
+			&p.Price.Currency,
+			&p.Stock,
+			&p.UserID,
+			&p.CategoryID,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.Version,
+			&p.DeletedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		page.Products = append(page.Products, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(page.Products) > req.PageSize {
+		page.Products = page.Products[:req.PageSize]
+		last := page.Products[len(page.Products)-1]
+		token, err := encodePageCursor(pageCursor{
+			Sort:  string(req.SortBy),
+			Desc:  req.SortDesc,
+			Value: sortCol.value(last),
+			ID:    last.ID,
+			Query: productListHash(req),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode page token: %w", err)
+		}
+		page.NextPageToken = token
+	}
+
+	if err := r.loadTags(ctx, page.Products); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (r *productRepository) Search(ctx context.Context, req *model.SearchProductsRequest) (*model.ProductSearchResult, error) {
+	offset := 0
+	if req.PageToken != "" {
+		cursor, err := decodePageCursor(req.PageToken)
+		if err != nil || cursor.Sort != searchCursorSort {
+			return nil, apperror.Invalid("page_token", "invalid page token")
+		}
+		if cursor.Query != queryHash(req.Query, req.UserID) {
+			return nil, apperror.Invalid("page_token", "page token was issued for a different query")
+		}
+		if offset, err = strconv.Atoi(cursor.Value); err != nil || offset < 0 {
+			return nil, apperror.Invalid("page_token", "invalid page token")
+		}
+	}
+
 	query := `
-		UPDATE products
-		SET name = $2, description = $3, price = $4, stock = $5, updated_at = $6
-		WHERE id = $1
+		SELECT p.id, p.name, p.description, p.price, p.currency, p.stock, p.user_id, COALESCE(p.category_id, 0), p.created_at, p.updated_at, p.version,
+			ts_rank(p.search_vector, q) AS rank,
+			ts_headline('english', p.name, q, 'HighlightAll=true'),
+			ts_headline('english', coalesce(p.description, ''), q, 'MaxFragments=2, MaxWords=20, MinWords=5'),
+			COUNT(*) OVER () AS total
+		FROM products p, websearch_to_tsquery('english', $1) q
+		WHERE p.search_vector @@ q AND p.deleted_at IS NULL AND ($2::bigint = 0 OR p.user_id = $2)
+		ORDER BY rank DESC, p.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, req.Query, req.UserID, req.PageSize+1, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+	defer rows.Close()
+
+	result := &model.ProductSearchResult{}
+	for rows.Next() {
+		p := &model.Product{}
+		hit := &model.ProductSearchHit{Product: p}
+		if err := rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Description,
+			decimalAmount{&p.Price.Amount},
+			&p.Price.Currency,
+			&p.Stock,
+			&p.UserID,
+			&p.CategoryID,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.Version,
+			&hit.Rank,
+			&hit.NameHighlight,
+			&hit.DescriptionHighlight,
+			&result.TotalCount,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan search hit: %w", err)
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(result.Hits) > req.PageSize {
+		result.Hits = result.Hits[:req.PageSize]
+		token, err := encodePageCursor(pageCursor{
+			Sort:  searchCursorSort,
+			Value: strconv.Itoa(offset + req.PageSize),
+			Query: queryHash(req.Query, req.UserID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode page token: %w", err)
+		}
+		result.NextPageToken = token
+	}
+
+	products := make([]*model.Product, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		products = append(products, hit.Product)
+	}
+	if err := r.loadTags(ctx, products); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Export passes a user's live products to fn in id order. Rows are read
+// through a server-side cursor fetchSize at a time, so a catalog of any size
+// is never held in memory at once. The cursor lives in its own read-only
+// transaction that stays open until Export returns, however slowly fn
+// consumes the rows; an error from fn stops the export and is returned.
+// Being read-only it never takes a txid, so it does not hold back the
+// product change feed; it deliberately does not join a caller's
+// transaction, which might have written.
+func (r *productRepository) Export(ctx context.Context, userID int64, fetchSize int, fn func(*model.Product) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin export: %w", err)
+	}
+	defer tx.Rollback()
+	ctx = context.WithValue(ctx, txKey{}, tx)
+
+	declare := `
+		DECLARE product_export NO SCROLL CURSOR FOR
+		SELECT id, name, description, price, currency, stock, user_id, COALESCE(category_id, 0), created_at, updated_at, version
+		FROM products WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY id
 	`
+	if _, err := tx.ExecContext(ctx, declare, userID); err != nil {
+		return fmt.Errorf("failed to open export cursor: %w", err)
+	}
+	defer tx.ExecContext(context.WithoutCancel(ctx), `CLOSE product_export`)
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM product_export`, fetchSize)
+	for {
+		products, err := r.fetchExport(ctx, tx, fetch)
+		if err != nil {
+			return err
+		}
+		if err := r.loadTags(ctx, products); err != nil {
+			return err
+		}
+		for _, p := range products {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		if len(products) < fetchSize {
+			return nil
+		}
+	}
+}
+
+// fetchExport reads the next chunk of the export cursor
+func (r *productRepository) fetchExport(ctx context.Context, tx *sql.Tx, fetch string) ([]*model.Product, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []*model.Product
+	for rows.Next() {
+		p := &model.Product{}
+		if err := rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Description,
+			decimalAmount{&p.Price.Amount},
+			&p.Price.Currency,
+			&p.Stock,
+			&p.UserID,
+			&p.CategoryID,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.Version,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return products, nil
+}
 
+// Update writes the given fields of a product only if its version still
+// matches the one the caller read, and stores the new version back on
+// product. Tags are not a column; SetTags writes them.
+func (r *productRepository) Update(ctx context.Context, product *model.Product, fields []string) error {
 	product.UpdatedAt = time.Now()
 
-	result, err := r.db.Exec(
-		query,
-		product.ID,
-		product.Name,
-		product.Description,
-		product.Price,
-		product.Stock,
-		product.UpdatedAt,
-	)
+	args := []interface{}{product.ID, product.Version}
+	var set []string
+	assign := func(column string, value interface{}) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	for _, field := range fields {
+		switch field {
+		case "name":
+			assign("name", product.Name)
+		case "description":
+			assign("description", product.Description)
+		case "price":
+			assign("price", product.Price.Decimal())
+			assign("currency", product.Price.Currency)
+		case "stock":
+			assign("stock", product.Stock)
+		case "category_id":
+			assign("category_id", nullableID(product.CategoryID))
+		case "tags":
+		default:
+			return apperror.Invalid("update_mask", fmt.Sprintf("field %q cannot be updated", field))
+		}
+	}
+	// Always written, so a tags-only change still bumps the version
+	assign("updated_at", product.UpdatedAt)
+
+	query := fmt.Sprintf(`
+		UPDATE products
+		SET %s
+		WHERE id = $1 AND deleted_at IS NULL AND version = $2
+		RETURNING version
+	`, strings.Join(set, ", "))
+
+	db := dbFrom(ctx, r.db)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&product.Version)
+	if err == sql.ErrNoRows {
+		return versionFailure(ctx, db, product.ID)
+	}
 	if err != nil {
+		if mapped := mapProductError(err); mapped != nil {
+			return mapped
+		}
 		return fmt.Errorf("failed to update product: %w", err)
 	}
 
+	return nil
+}
+
+// versionFailure explains why a version-guarded update matched no row
+func versionFailure(ctx context.Context, db DBTX, id int64) error {
+	var current int64
+	err := db.QueryRowContext(ctx, `SELECT version FROM products WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return apperror.NotFound("product")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check product version: %w", err)
+	}
+	return ErrVersionMismatch.WithMetadata("current_version", strconv.FormatInt(current, 10))
+}
+
+// AdjustStock atomically adds delta to a product's stock and returns the new
+// level. The non-negative guard lives in the UPDATE itself so concurrent
+// adjustments can never oversell.
+func (r *productRepository) AdjustStock(ctx context.Context, id int64, delta int) (int, error) {
+	query := `
+		UPDATE products SET stock = stock + $2, updated_at = $3
+		WHERE id = $1 AND deleted_at IS NULL AND stock + $2 >= 0
+		RETURNING stock
+	`
+
+	var stock int
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id, delta, time.Now()).Scan(&stock)
+	if err == sql.ErrNoRows {
+		return 0, stockFailure(ctx, dbFrom(ctx, r.db), id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to adjust stock: %w", err)
+	}
+
+	return stock, nil
+}
+
+// stockFailure explains why a guarded stock update matched no row
+func stockFailure(ctx context.Context, db DBTX, id int64) error {
+	productID := strconv.FormatInt(id, 10)
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check product: %w", err)
+	}
+	if !exists {
+		return apperror.NotFound("product").WithMetadata("product_id", productID)
+	}
+	return ErrInsufficientStock.WithMetadata("product_id", productID)
+}
+
+// Delete soft-deletes a product; it stays restorable until purged
+func (r *productRepository) Delete(ctx context.Context, id int64) error {
+	query := `UPDATE products SET deleted_at = $2, updated_at = $2 WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to delete product: %w", err)
+	}
+
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("product not found")
+		return apperror.NotFound("product")
 	}
 
 	return nil
 }
 
-func (r *productRepository) Delete(id int64) error {
-	query := `DELETE FROM products WHERE id = $1`
+// DeleteBatch soft-deletes the live products among ids and returns the ids
+// it deleted
+func (r *productRepository) DeleteBatch(ctx context.Context, ids []int64) ([]int64, error) {
+	query := `
+		UPDATE products SET deleted_at = $2, updated_at = $2
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		RETURNING id
+	`
 
-	result, err := r.db.Exec(query, id)
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, pq.Array(ids), time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
+		return nil, fmt.Errorf("failed to delete products: %w", err)
+	}
+	defer rows.Close()
+
+	var deleted []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan product id: %w", err)
+		}
+		deleted = append(deleted, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// GetDeleted retrieves a soft-deleted product, which GetByID no longer sees
+func (r *productRepository) GetDeleted(ctx context.Context, id int64) (*model.Product, error) {
+	query := `
+		SELECT id, name, description, price, currency, stock, user_id, COALESCE(category_id, 0), created_at, updated_at, version, deleted_at
+		FROM products WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	p := &model.Product{}
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&p.ID,
+		&p.Name,
+		&p.Description,
+		decimalAmount{&p.Price.Amount},
+		&p.Price.Currency,
+		&p.Stock,
+		&p.UserID,
+		&p.CategoryID,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.Version,
+		&p.DeletedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("product")
+		}
+		return nil, fmt.Errorf("failed to get deleted product: %w", err)
+	}
+
+	if err := r.loadTags(ctx, []*model.Product{p}); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Restore undoes a soft delete. Products of a deleted user come back with
+// the user, not on their own.
+func (r *productRepository) Restore(ctx context.Context, id int64) error {
+	query := `
+		UPDATE products p SET deleted_at = NULL, updated_at = $2
+		FROM users u
+		WHERE p.id = $1 AND p.deleted_at IS NOT NULL AND u.id = p.user_id AND u.deleted_at IS NULL
+	`
+
+	db := dbFrom(ctx, r.db)
+	result, err := db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to restore product: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -166,8 +758,129 @@ func (r *productRepository) Delete(id int64) error {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("product not found")
+		var exists bool
+		if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NOT NULL)`, id).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check product: %w", err)
+		}
+		if !exists {
+			return apperror.NotFound("product")
+		}
+		return ErrProductOwnerDeleted
+	}
+
+	return nil
+}
+
+// Purge permanently removes products soft-deleted before the cutoff and
+// returns how many were removed.
+func (r *productRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, `DELETE FROM products WHERE deleted_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge products: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+// SetTags replaces the tags of a product, creating tags that do not exist yet
+func (r *productRepository) SetTags(ctx context.Context, id int64, tags []string) error {
+	db := dbFrom(ctx, r.db)
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM product_tags WHERE product_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to clear product tags: %w", err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, err := db.ExecContext(
+		ctx,
+		`INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`,
+		pq.Array(tags),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create tags: %w", err)
+	}
+
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO product_tags (product_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2)`,
+		id,
+		pq.Array(tags),
+	)
+	if err != nil {
+		if mapped := mapProductError(err); mapped != nil {
+			return mapped
+		}
+		return fmt.Errorf("failed to tag product: %w", err)
+	}
+
+	return nil
+}
+
+// loadTags fetches the tags of all given products with a single query
+func (r *productRepository) loadTags(ctx context.Context, products []*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*model.Product, len(products))
+	ids := make([]int64, 0, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+
+	query := `
+		SELECT pt.product_id, t.name
+		FROM product_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.product_id = ANY($1)
+		ORDER BY pt.product_id, t.name
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to load product tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID int64
+		var tag string
+		if err := rows.Scan(&productID, &tag); err != nil {
+			return fmt.Errorf("failed to scan product tag: %w", err)
+		}
+		if p, ok := byID[productID]; ok {
+			p.Tags = append(p.Tags, tag)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
 	}
 
 	return nil
 }
+
+// mapProductError translates foreign key violations on products into domain errors
+func mapProductError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok || pqErr.Code != "23503" { // foreign_key_violation
+		return nil
+	}
+	switch pqErr.Constraint {
+	case "products_category_id_fkey":
+		return apperror.Invalid("category_id", "category does not exist")
+	case "product_tags_product_id_fkey":
+		return apperror.NotFound("product")
+	default:
+		return apperror.Invalid("user_id", "user does not exist")
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+)
+
+// RefreshTokenRepository defines contract for refresh token persistence
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error)
+	MarkUsed(ctx context.Context, id int64) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	IsFamilyActive(ctx context.Context, familyID string) (bool, error)
+}
+
+type refreshTokenRepository struct {
+	db *sql.DB
+}
+
+// NewRefreshTokenRepository creates a new instance of RefreshTokenRepository
+func NewRefreshTokenRepository(db *sql.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	token.CreatedAt = time.Now()
+
+	err := dbFrom(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`
+
+	t := &model.RefreshToken{}
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, hash).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.FamilyID,
+		&t.TokenHash,
+		&t.ExpiresAt,
+		&t.UsedAt,
+		&t.RevokedAt,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("refresh token")
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return t, nil
+}
+
+// MarkUsed flags a token as exchanged. It reports false when the token had
+// already been used, which callers must treat as token reuse.
+func (r *refreshTokenRepository) MarkUsed(ctx context.Context, id int64) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL`
+
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := dbFrom(ctx, r.db).ExecContext(ctx, query, familyID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	return nil
+}
+
+// IsFamilyActive reports whether the session has not been revoked
+func (r *refreshTokenRepository) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM refresh_tokens WHERE family_id = $1 AND revoked_at IS NULL
+		)
+	`
+
+	var active bool
+	if err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, familyID).Scan(&active); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	return active, nil
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+)
+
+// ReservationRepository defines contract for stock reservation persistence
+type ReservationRepository interface {
+	// Reserve, Release and Commit return the product's stock afterwards
+	Reserve(ctx context.Context, reservation *model.StockReservation) (int, error)
+	GetByID(ctx context.Context, id int64) (*model.StockReservation, error)
+	Release(ctx context.Context, id int64) (int, error)
+	Commit(ctx context.Context, id int64) (int, error)
+	ReleaseExpired(ctx context.Context, now time.Time) ([]model.ReleasedStock, error)
+}
+
+// ErrReservationNotActive is returned when a reservation was already released, committed or expired
+var ErrReservationNotActive = apperror.Conflict("RESERVATION_NOT_ACTIVE", "reservation is no longer active")
+
+type reservationRepository struct {
+	db *sql.DB
+}
+
+// NewReservationRepository creates a new instance of ReservationRepository
+func NewReservationRepository(db *sql.DB) ReservationRepository {
+	return &reservationRepository{db: db}
+}
+
+// Reserve takes the reserved quantity out of stock and records the hold in
+// one statement, so the hold exists if and only if the stock was available.
+func (r *reservationRepository) Reserve(ctx context.Context, res *model.StockReservation) (int, error) {
+	query := `
+		WITH held AS (
+			UPDATE products SET stock = stock - $2, updated_at = $5
+			WHERE id = $1 AND deleted_at IS NULL AND stock >= $2
+			RETURNING id, stock
+		), inserted AS (
+			INSERT INTO stock_reservations (product_id, user_id, quantity, status, expires_at, created_at, updated_at)
+			SELECT id, $3, $2, $4, $6, $5, $5 FROM held
+			RETURNING id
+		)
+		SELECT inserted.id, held.stock FROM inserted, held
+	`
+
+	now := time.Now()
+	res.Status = model.ReservationActive
+	res.CreatedAt = now
+	res.UpdatedAt = now
+
+	var stock int
+	err := dbFrom(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		res.ProductID,
+		res.Quantity,
+		res.UserID,
+		res.Status,
+		now,
+		res.ExpiresAt,
+	).Scan(&res.ID, &stock)
+	if err == sql.ErrNoRows {
+		return 0, stockFailure(ctx, dbFrom(ctx, r.db), res.ProductID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve stock: %w", err)
+	}
+
+	return stock, nil
+}
+
+func (r *reservationRepository) GetByID(ctx context.Context, id int64) (*model.StockReservation, error) {
+	query := `
+		SELECT id, product_id, user_id, quantity, status, expires_at, created_at, updated_at
+		FROM stock_reservations WHERE id = $1
+	`
+
+	res := &model.StockReservation{}
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&res.ID,
+		&res.ProductID,
+		&res.UserID,
+		&res.Quantity,
+		&res.Status,
+		&res.ExpiresAt,
+		&res.CreatedAt,
+		&res.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("reservation")
+		}
+		return nil, fmt.Errorf("failed to get reservation: %w", err)
+	}
+
+	return res, nil
+}
+
+// Release returns an active reservation's units to stock
+func (r *reservationRepository) Release(ctx context.Context, id int64) (int, error) {
+	query := `
+		WITH released AS (
+			UPDATE stock_reservations SET status = $2, updated_at = $4
+			WHERE id = $1 AND status = $3
+			RETURNING product_id, quantity
+		)
+		UPDATE products p SET stock = p.stock + released.quantity, updated_at = $4
+		FROM released WHERE p.id = released.product_id
+		RETURNING p.stock
+	`
+
+	var stock int
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id, model.ReservationReleased, model.ReservationActive, time.Now()).Scan(&stock)
+	if err == sql.ErrNoRows {
+		return 0, ErrReservationNotActive
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to release reservation: %w", err)
+	}
+
+	return stock, nil
+}
+
+// Commit turns an unexpired active reservation into a permanent stock decrement
+func (r *reservationRepository) Commit(ctx context.Context, id int64) (int, error) {
+	query := `
+		WITH committed AS (
+			UPDATE stock_reservations SET status = $2, updated_at = $4
+			WHERE id = $1 AND status = $3 AND expires_at > $4
+			RETURNING product_id
+		)
+		SELECT p.stock FROM products p JOIN committed ON p.id = committed.product_id
+	`
+
+	var stock int
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id, model.ReservationCommitted, model.ReservationActive, time.Now()).Scan(&stock)
+	if err == sql.ErrNoRows {
+		return 0, ErrReservationNotActive
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit reservation: %w", err)
+	}
+
+	return stock, nil
+}
+
+// ReleaseExpired releases every active reservation that expired before now
+// and returns, per product, what was put back into stock.
+func (r *reservationRepository) ReleaseExpired(ctx context.Context, now time.Time) ([]model.ReleasedStock, error) {
+	query := `
+		WITH expired AS (
+			UPDATE stock_reservations SET status = $1, updated_at = $3
+			WHERE status = $2 AND expires_at <= $3
+			RETURNING product_id, quantity
+		), totals AS (
+			SELECT product_id, COUNT(*) AS reservations, SUM(quantity) AS quantity
+			FROM expired GROUP BY product_id
+		)
+		UPDATE products p SET stock = p.stock + t.quantity, updated_at = $3
+		FROM totals t
+		WHERE p.id = t.product_id
+		RETURNING p.id, t.reservations, t.quantity, p.stock
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, model.ReservationReleased, model.ReservationActive, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release expired reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var released []model.ReleasedStock
+	for rows.Next() {
+		var rs model.ReleasedStock
+		if err := rows.Scan(&rs.ProductID, &rs.Reservations, &rs.Quantity, &rs.Stock); err != nil {
+			return nil, fmt.Errorf("failed to scan released stock: %w", err)
+		}
+		released = append(released, rs)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return released, nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// DBTX is the query surface shared by *sql.DB and *sql.Tx. Repositories run
+// every statement through it so the same code works inside and outside a
+// transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// dbFrom returns the transaction bound to ctx by TxManager, or db when
+// the call is not part of a unit of work.
+func dbFrom(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// TxManager runs a unit of work spanning several repositories in one
+// database transaction.
+type TxManager interface {
+	// WithinTx runs fn in a transaction carried by the context passed to
+	// fn. Calls nested inside an outer WithinTx join the outer transaction.
+	// On a serialization failure the whole fn is retried, so it must not
+	// have side effects outside the database.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxConfig controls the isolation level and retry policy of a TxManager
+type TxConfig struct {
+	Isolation  sql.IsolationLevel
+	MaxRetries int
+	RetryDelay time.Duration
+}
+
+type txManager struct {
+	db  *sql.DB
+	cfg TxConfig
+}
+
+// NewTxManager creates a new instance of TxManager
+func NewTxManager(db *sql.DB, cfg TxConfig) TxManager {
+	return &txManager{db: db, cfg: cfg}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := m.run(ctx, fn)
+		if err == nil || !isSerializationFailure(err) || attempt >= m.cfg.MaxRetries {
+			return err
+		}
+
+		logrus.Debugf("Retrying transaction after serialization failure (attempt %d): %v", attempt+1, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.cfg.RetryDelay * time.Duration(attempt+1)):
+		}
+	}
+}
+
+// run executes fn in a single transaction, rolling back on error
+func (m *txManager) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.db.BeginTx(ctx, &sql.TxOptions{Isolation: m.cfg.Isolation})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isSerializationFailure reports whether err is SQLSTATE 40001, which
+// PostgreSQL raises when a REPEATABLE READ or SERIALIZABLE transaction
+// must be retried.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "40001"
+}
+
+// ParseIsolationLevel maps a config value such as "read_committed" or
+// "serializable" to its sql.IsolationLevel.
+func ParseIsolationLevel(level string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(level))) {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unsupported isolation level %q", level)
+	}
+}
@@ -1,21 +1,26 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"grpc-exmpl/internal/apperror"
 	"grpc-exmpl/internal/model"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
 )
 
 type UserRepository interface {
-	Create(user *model.User) error
-	GetByEmail(email string) (*model.User, error)
-	GetByID(id int64) (*model.User, error)
-	GetByUsername(username string) (*model.User, error)
-	Update(user *model.User) error
-	Delete(id int64) error
+	Create(ctx context.Context, user *model.User) error
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByID(ctx context.Context, id int64) (*model.User, error)
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	Update(ctx context.Context, user *model.User, fields []string) error
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 type userRepository struct {
@@ -28,37 +33,31 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	}
 }
 
-func (r *userRepository) Create(user *model.User) error {
+func (r *userRepository) Create(ctx context.Context, user *model.User) error {
 	query := `
-		INSERT INTO users (username, email, password, full_name, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO users (username, email, password, full_name, roles, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id`
 
 	now := time.Now()
 	user.CreatedAt = now
 	user.UpdatedAt = now
 
-	err := r.db.QueryRow(
+	err := dbFrom(ctx, r.db).QueryRowContext(
+		ctx,
 		query,
 		user.Username,
 		user.Email,
 		user.Password,
 		user.FullName,
+		pq.Array(user.Roles),
 		user.CreatedAt,
 		user.UpdatedAt,
 	).Scan(&user.ID)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code {
-			case "23505": // unique_violation
-				if pqErr.Constraint == "users_email_key" {
-					return fmt.Errorf("email already exists")
-				}
-				if pqErr.Constraint == "users_username_key" {
-					return fmt.Errorf("username already exists")
-				}
-			}
+		if mapped := mapUserError(err); mapped != nil {
+			return mapped
 		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -66,26 +65,27 @@ func (r *userRepository) Create(user *model.User) error {
 	return nil
 }
 
-func (r *userRepository) GetByEmail(email string) (*model.User, error) {
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	user := &model.User{}
 	query := `
-		SELECT id, username, email, password, full_name, created_at, updated_at
+		SELECT id, username, email, password, full_name, roles, created_at, updated_at
 		FROM users
-		WHERE email = $1`
+		WHERE email = $1 AND deleted_at IS NULL`
 
-	err := r.db.QueryRow(query, email).Scan(
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
 		&user.FullName,
+		pq.Array(&user.Roles),
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, apperror.NotFound("user")
 		}
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
@@ -93,26 +93,27 @@ func (r *userRepository) GetByEmail(email string) (*model.User, error) {
 	return user, nil
 }
 
-func (r *userRepository) GetByID(id int64) (*model.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*model.User, error) {
 	user := &model.User{}
 	query := `
-		SELECT id, username, email, password, full_name, created_at, updated_at
+		SELECT id, username, email, password, full_name, roles, created_at, updated_at
 		FROM users
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
 		&user.FullName,
+		pq.Array(&user.Roles),
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, apperror.NotFound("user")
 		}
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
@@ -120,26 +121,27 @@ func (r *userRepository) GetByID(id int64) (*model.User, error) {
 	return user, nil
 }
 
-func (r *userRepository) GetByUsername(username string) (*model.User, error) {
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
 	user := &model.User{}
 	query := `
-		SELECT id, username, email, password, full_name, created_at, updated_at
+		SELECT id, username, email, password, full_name, roles, created_at, updated_at
 		FROM users
-		WHERE username = $1`
+		WHERE username = $1 AND deleted_at IS NULL`
 
-	err := r.db.QueryRow(query, username).Scan(
+	err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password,
 		&user.FullName,
+		pq.Array(&user.Roles),
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, apperror.NotFound("user")
 		}
 		return nil, fmt.Errorf("failed to get user by username: %w", err)
 	}
@@ -147,24 +149,43 @@ func (r *userRepository) GetByUsername(username string) (*model.User, error) {
 	return user, nil
 }
 
-func (r *userRepository) Update(user *model.User) error {
-	query := `
-		UPDATE users
-		SET username = $2, email = $3, full_name = $4, updated_at = $5
-		WHERE id = $1`
-
+// Update writes the given fields of a live user
+func (r *userRepository) Update(ctx context.Context, user *model.User, fields []string) error {
 	user.UpdatedAt = time.Now()
 
-	result, err := r.db.Exec(
-		query,
-		user.ID,
-		user.Username,
-		user.Email,
-		user.FullName,
-		user.UpdatedAt,
-	)
+	args := []interface{}{user.ID}
+	var set []string
+	assign := func(column string, value interface{}) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	for _, field := range fields {
+		switch field {
+		case "username":
+			assign("username", user.Username)
+		case "email":
+			assign("email", user.Email)
+		case "full_name":
+			assign("full_name", user.FullName)
+		case "roles":
+			assign("roles", pq.Array(user.Roles))
+		default:
+			return apperror.Invalid("update_mask", fmt.Sprintf("field %q cannot be updated", field))
+		}
+	}
+	assign("updated_at", user.UpdatedAt)
+
+	query := fmt.Sprintf(`
+		UPDATE users
+		SET %s
+		WHERE id = $1 AND deleted_at IS NULL`, strings.Join(set, ", "))
+
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, query, args...)
 
 	if err != nil {
+		if mapped := mapUserError(err); mapped != nil {
+			return mapped
+		}
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
@@ -174,28 +195,97 @@ func (r *userRepository) Update(user *model.User) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return apperror.NotFound("user")
 	}
 
 	return nil
 }
 
-func (r *userRepository) Delete(id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
+// Delete soft-deletes a user together with their live products and ends
+// their sessions. The products share the user's deleted_at, which is how
+// Restore tells them apart from products deleted earlier on their own.
+func (r *userRepository) Delete(ctx context.Context, id int64) error {
+	query := `
+		WITH deleted AS (
+			UPDATE users SET deleted_at = $2, updated_at = $2
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING id
+		), products_deleted AS (
+			UPDATE products SET deleted_at = $2, updated_at = $2
+			WHERE user_id IN (SELECT id FROM deleted) AND deleted_at IS NULL
+		), sessions_revoked AS (
+			UPDATE refresh_tokens SET revoked_at = $2
+			WHERE user_id IN (SELECT id FROM deleted) AND revoked_at IS NULL
+		)
+		SELECT COUNT(*) FROM deleted`
+
+	var deleted int64
+	if err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id, time.Now()).Scan(&deleted); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
 
-	result, err := r.db.Exec(query, id)
+	if deleted == 0 {
+		return apperror.NotFound("user")
+	}
+
+	return nil
+}
+
+// Restore undoes a soft delete, bringing back the products that were
+// deleted along with the user.
+func (r *userRepository) Restore(ctx context.Context, id int64) error {
+	query := `
+		WITH restored AS (
+			UPDATE users u SET deleted_at = NULL, updated_at = $2
+			FROM (SELECT id, deleted_at FROM users WHERE id = $1 AND deleted_at IS NOT NULL) d
+			WHERE u.id = d.id
+			RETURNING u.id, d.deleted_at
+		), products_restored AS (
+			UPDATE products p SET deleted_at = NULL, updated_at = $2
+			FROM restored
+			WHERE p.user_id = restored.id AND p.deleted_at = restored.deleted_at
+		)
+		SELECT COUNT(*) FROM restored`
+
+	var restored int64
+	if err := dbFrom(ctx, r.db).QueryRowContext(ctx, query, id, time.Now()).Scan(&restored); err != nil {
+		return fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	if restored == 0 {
+		return apperror.NotFound("user")
+	}
+
+	return nil
+}
+
+// Purge permanently removes users soft-deleted before the cutoff, along
+// with everything they own, and returns how many users were removed.
+func (r *userRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, `DELETE FROM users WHERE deleted_at <= $1`, before)
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return 0, fmt.Errorf("failed to purge users: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
-	}
+	return rowsAffected, nil
+}
 
+// mapUserError translates unique violations on users into domain errors
+func mapUserError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok || pqErr.Code != "23505" { // unique_violation
+		return nil
+	}
+	switch pqErr.Constraint {
+	case "users_email_key":
+		return apperror.AlreadyExists("user", "email")
+	case "users_username_key":
+		return apperror.AlreadyExists("user", "username")
+	}
 	return nil
 }
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// WebhookDeliveryRepository defines contract for queued webhook deliveries
+// and their attempt log
+type WebhookDeliveryRepository interface {
+	// Enqueue queues deliveries, skipping any already queued for the same
+	// webhook and event
+	Enqueue(ctx context.Context, deliveries ...*model.WebhookDelivery) error
+	GetByID(ctx context.Context, id int64) (*model.WebhookDelivery, error)
+	ListByWebhookID(ctx context.Context, req *model.ListDeliveriesRequest) (*model.DeliveryPage, error)
+	ListAttempts(ctx context.Context, deliveryID int64) ([]*model.WebhookAttempt, error)
+	// Claim leases up to limit due deliveries, loading their webhooks, so
+	// concurrent workers never send the same delivery at the same time
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error)
+	// RecordAttempt logs an attempt and stores the delivery's resulting
+	// status, next_attempt_at and last result in one statement
+	RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, attempt *model.WebhookAttempt) error
+	// Replay makes a finished delivery pending again with fresh attempts
+	Replay(ctx context.Context, id int64) error
+}
+
+// deliveryCursorSort tags page tokens issued by delivery listings
+const deliveryCursorSort = "delivery_id"
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+type webhookDeliveryRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookDeliveryRepository creates a new instance of WebhookDeliveryRepository
+func NewWebhookDeliveryRepository(db *sql.DB) WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{db: db}
+}
+
+func (r *webhookDeliveryRepository) Enqueue(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`
+
+	db := dbFrom(ctx, r.db)
+	for _, d := range deliveries {
+		d.Status = model.DeliveryPending
+		d.CreatedAt = time.Now()
+		d.NextAttemptAt = d.CreatedAt
+		_, err := db.ExecContext(ctx, query, d.WebhookID, d.EventID, d.EventType, []byte(d.Payload), d.Status, d.CreatedAt)
+		if err != nil {
+			// The webhook was deleted after it matched; nothing to deliver
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+				continue
+			}
+			return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *webhookDeliveryRepository) GetByID(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	d, err := scanDelivery(dbFrom(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("webhook delivery")
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	return d, nil
+}
+
+// ListByWebhookID returns a page of a webhook's deliveries, newest first
+func (r *webhookDeliveryRepository) ListByWebhookID(ctx context.Context, req *model.ListDeliveriesRequest) (*model.DeliveryPage, error) {
+	var afterID int64
+	if req.PageToken != "" {
+		cursor, err := decodePageCursor(req.PageToken)
+		if err != nil || cursor.Sort != deliveryCursorSort {
+			return nil, apperror.Invalid("page_token", "invalid page token")
+		}
+		if cursor.Query != queryHash(req.WebhookID, req.Status) {
+			return nil, apperror.Invalid("page_token", "page token was issued for a different query")
+		}
+		afterID = cursor.ID
+	}
+
+	query := `
+		SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2::bigint = 0 OR id < $2) AND ($3 = '' OR status = $3)
+		ORDER BY id DESC
+		LIMIT $4
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, req.WebhookID, afterID, req.Status, req.PageSize+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	page := &model.DeliveryPage{}
+	if len(deliveries) > req.PageSize {
+		deliveries = deliveries[:req.PageSize]
+		token, err := encodePageCursor(pageCursor{Sort: deliveryCursorSort, Desc: true, ID: deliveries[len(deliveries)-1].ID, Query: queryHash(req.WebhookID, req.Status)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode page token: %w", err)
+		}
+		page.NextPageToken = token
+	}
+	page.Deliveries = deliveries
+
+	return page, nil
+}
+
+func (r *webhookDeliveryRepository) ListAttempts(ctx context.Context, deliveryID int64) ([]*model.WebhookAttempt, error) {
+	query := `
+		SELECT id, delivery_id, attempt, status_code, error, duration_ms, attempted_at
+		FROM webhook_attempts
+		WHERE delivery_id = $1
+		ORDER BY id
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []*model.WebhookAttempt
+	for rows.Next() {
+		a := &model.WebhookAttempt{}
+		var ms int64
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.Attempt, &a.StatusCode, &a.Error, &ms, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %w", err)
+		}
+		a.Duration = time.Duration(ms) * time.Millisecond
+		attempts = append(attempts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return attempts, nil
+}
+
+func (r *webhookDeliveryRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	// Same leasing scheme as the outbox: a claimed row is not due again
+	// until the lease runs out or the attempt is recorded
+	query := `
+		UPDATE webhook_deliveries d SET attempts = d.attempts + 1, next_attempt_at = $2
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, d.created_at, w.user_id, w.url, w.secret
+	`
+
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*model.WebhookDelivery
+	for rows.Next() {
+		d := &model.WebhookDelivery{Status: model.DeliveryPending, Webhook: &model.Webhook{}}
+		var payload []byte
+		if err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.EventType,
+			&payload,
+			&d.Attempts,
+			&d.CreatedAt,
+			&d.Webhook.UserID,
+			&d.Webhook.URL,
+			&d.Webhook.Secret,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		d.Webhook.ID = d.WebhookID
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (r *webhookDeliveryRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, attempt *model.WebhookAttempt) error {
+	query := `
+		WITH attempt AS (
+			INSERT INTO webhook_attempts (delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		)
+		UPDATE webhook_deliveries
+		SET status = $7, next_attempt_at = $8, delivered_at = $9, last_status_code = $3, last_error = $4
+		WHERE id = $1
+		RETURNING (SELECT id FROM attempt)
+	`
+
+	attempt.DeliveryID = delivery.ID
+	err := dbFrom(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		delivery.ID,
+		attempt.Attempt,
+		attempt.StatusCode,
+		attempt.Error,
+		attempt.Duration.Milliseconds(),
+		attempt.AttemptedAt,
+		delivery.Status,
+		delivery.NextAttemptAt,
+		delivery.DeliveredAt,
+	).Scan(&attempt.ID)
+	if err != nil {
+		// The webhook, and with it the delivery, was deleted mid-attempt
+		if pqErr, ok := err.(*pq.Error); (ok && pqErr.Code == "23503") || err == sql.ErrNoRows {
+			return apperror.NotFound("webhook delivery")
+		}
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+	return nil
+}
+
+func (r *webhookDeliveryRepository) Replay(ctx context.Context, id int64) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE id = $1
+	`
+
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return apperror.NotFound("webhook delivery")
+	}
+
+	return nil
+}
+
+func scanDelivery(row rowScanner) (*model.WebhookDelivery, error) {
+	d := &model.WebhookDelivery{}
+	var payload []byte
+	err := row.Scan(
+		&d.ID,
+		&d.WebhookID,
+		&d.EventID,
+		&d.EventType,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.CreatedAt,
+		&d.DeliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return d, nil
+}
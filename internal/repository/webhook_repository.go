@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// WebhookRepository defines contract for webhook registrations
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *model.Webhook) error
+	GetByID(ctx context.Context, id int64) (*model.Webhook, error)
+	ListByUserID(ctx context.Context, userID int64) ([]*model.Webhook, error)
+	Delete(ctx context.Context, id int64) error
+	// ListMatching returns the user's webhooks whose filters accept an
+	// event of eventType about productID
+	ListMatching(ctx context.Context, userID int64, eventType string, productID int64) ([]*model.Webhook, error)
+}
+
+const webhookColumns = `id, user_id, url, secret, event_types, product_ids, created_at, updated_at`
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new instance of WebhookRepository
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) Create(ctx context.Context, webhook *model.Webhook) error {
+	query := `
+		INSERT INTO webhooks (user_id, url, secret, event_types, product_ids, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	now := time.Now()
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+
+	err := dbFrom(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		webhook.UserID,
+		webhook.URL,
+		webhook.Secret,
+		pq.Array(webhook.EventTypes),
+		pq.Array(webhook.ProductIDs),
+		webhook.CreatedAt,
+		webhook.UpdatedAt,
+	).Scan(&webhook.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) GetByID(ctx context.Context, id int64) (*model.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+	webhook, err := scanWebhook(dbFrom(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("webhook")
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (r *webhookRepository) ListByUserID(ctx context.Context, userID int64) ([]*model.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 ORDER BY id`
+	return r.list(ctx, query, userID)
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+
+	result, err := dbFrom(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return apperror.NotFound("webhook")
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) ListMatching(ctx context.Context, userID int64, eventType string, productID int64) ([]*model.Webhook, error) {
+	query := `
+		SELECT ` + webhookColumns + ` FROM webhooks
+		WHERE user_id = $1
+		AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+		AND (cardinality(product_ids) = 0 OR $3 = ANY(product_ids))
+		ORDER BY id
+	`
+	return r.list(ctx, query, userID, eventType, productID)
+}
+
+func (r *webhookRepository) list(ctx context.Context, query string, args ...interface{}) ([]*model.Webhook, error) {
+	rows, err := dbFrom(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*model.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row rowScanner) (*model.Webhook, error) {
+	webhook := &model.Webhook{}
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.EventTypes),
+		pq.Array(&webhook.ProductIDs),
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+)
+
+// CategoryService defines business logic for the product category tree
+type CategoryService interface {
+	CreateCategory(ctx context.Context, caller model.Caller, req *model.CreateCategoryRequest) (*model.Category, error)
+	GetCategory(ctx context.Context, id int64) (*model.Category, error)
+	ListCategories(ctx context.Context, req *model.ListCategoriesRequest) ([]*model.Category, error)
+	UpdateCategory(ctx context.Context, caller model.Caller, req *model.UpdateCategoryRequest) (*model.Category, error)
+	DeleteCategory(ctx context.Context, caller model.Caller, id int64) error
+}
+
+var (
+	slugRegex      = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type categoryService struct {
+	repo repository.CategoryRepository
+}
+
+// NewCategoryService creates a new instance of CategoryService
+func NewCategoryService(repo repository.CategoryRepository) CategoryService {
+	return &categoryService{repo: repo}
+}
+
+// CreateCategory adds a category to the tree; only admins may change the catalog
+func (s *categoryService) CreateCategory(ctx context.Context, caller model.Caller, req *model.CreateCategoryRequest) (*model.Category, error) {
+	if !caller.IsAdmin() {
+		return nil, ErrPermissionDenied
+	}
+
+	c := &model.Category{
+		ParentID: req.ParentID,
+		Name:     strings.TrimSpace(req.Name),
+		Slug:     categorySlug(req.Slug, req.Name),
+	}
+	if err := validateCategory(c); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetCategory retrieves a category by ID
+func (s *categoryService) GetCategory(ctx context.Context, id int64) (*model.Category, error) {
+	if id <= 0 {
+		return nil, apperror.Invalid("id", "id is required")
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListCategories lists the whole tree or one subtree, parents before children
+func (s *categoryService) ListCategories(ctx context.Context, req *model.ListCategoriesRequest) ([]*model.Category, error) {
+	if req.RootID < 0 {
+		return nil, apperror.Invalid("root_id", "root_id cannot be negative")
+	}
+	return s.repo.List(ctx, req.RootID)
+}
+
+// UpdateCategory renames or moves a category; only admins may change the catalog
+func (s *categoryService) UpdateCategory(ctx context.Context, caller model.Caller, req *model.UpdateCategoryRequest) (*model.Category, error) {
+	if !caller.IsAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	if req.ID <= 0 {
+		return nil, apperror.Invalid("id", "id is required")
+	}
+
+	existing, err := s.repo.GetByID(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.ParentID = req.ParentID
+	existing.Name = strings.TrimSpace(req.Name)
+	existing.Slug = categorySlug(req.Slug, req.Name)
+	if err := validateCategory(existing); err != nil {
+		return nil, err
+	}
+	if existing.ParentID == existing.ID {
+		return nil, repository.ErrCategoryCycle
+	}
+
+	if err := s.repo.Update(ctx, existing); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// DeleteCategory removes a category without subcategories; only admins may change the catalog
+func (s *categoryService) DeleteCategory(ctx context.Context, caller model.Caller, id int64) error {
+	if !caller.IsAdmin() {
+		return ErrPermissionDenied
+	}
+	if id <= 0 {
+		return apperror.Invalid("id", "id is required")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// categorySlug returns the requested slug, or one derived from the name
+func categorySlug(slug, name string) string {
+	slug = strings.TrimSpace(slug)
+	if slug != "" {
+		return slug
+	}
+	return strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// validateCategory validates a category before it is written, reporting every bad field
+func validateCategory(c *model.Category) error {
+	var v violations
+	v.check(c.Name != "", "name", "name is required")
+	v.check(slugRegex.MatchString(c.Slug), "slug", "slug must be lowercase letters and digits separated by single hyphens")
+	v.check(c.ParentID >= 0, "parent_id", "parent_id cannot be negative")
+	return v.err()
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"grpc-exmpl/internal/model"
+)
+
+// newEvent builds an outbox event carrying payload as JSON
+func newEvent(eventType, aggregateType string, aggregateID int64, payload interface{}) (*model.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	return &model.OutboxEvent{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       data,
+	}, nil
+}
+
+// productCreatedEvents builds a ProductCreated event for each product
+func productCreatedEvents(products ...*model.Product) ([]*model.OutboxEvent, error) {
+	events := make([]*model.OutboxEvent, 0, len(products))
+	for _, p := range products {
+		e, err := newEvent(model.EventProductCreated, model.AggregateProduct, p.ID, model.ProductChangedEvent{Product: p})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// stockChangedEvent builds a StockChanged event for a product now holding stock units
+func stockChangedEvent(productID int64, delta, stock int, reason string) (*model.OutboxEvent, error) {
+	return newEvent(model.EventStockChanged, model.AggregateProduct, productID, model.StockChangedEvent{
+		ProductID: productID,
+		Delta:     delta,
+		Stock:     stock,
+		Reason:    reason,
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+)
+
+// OrderService defines business logic for placing and managing orders
+type OrderService interface {
+	CreateOrder(ctx context.Context, caller model.Caller, req *model.CreateOrderRequest) (*model.Order, error)
+	GetOrder(ctx context.Context, caller model.Caller, id int64) (*model.Order, error)
+	ListMyOrders(ctx context.Context, caller model.Caller, req *model.ListOrdersRequest) (*model.OrderPage, error)
+	CancelOrder(ctx context.Context, caller model.Caller, id int64) (*model.Order, error)
+}
+
+// maxOrderItems bounds how many lines a single checkout may lock
+const maxOrderItems = 100
+
+// ErrCurrencyMismatch is returned when an order mixes products priced in different currencies
+var ErrCurrencyMismatch = apperror.Conflict("CURRENCY_MISMATCH", "all items must be priced in the same currency")
+
+type orderService struct {
+	tx       repository.TxManager
+	repo     repository.OrderRepository
+	products repository.ProductRepository
+	outbox   repository.OutboxRepository
+}
+
+// NewOrderService creates a new instance of OrderService
+func NewOrderService(tx repository.TxManager, repo repository.OrderRepository, products repository.ProductRepository, outbox repository.OutboxRepository) OrderService {
+	return &orderService{tx: tx, repo: repo, products: products, outbox: outbox}
+}
+
+// CreateOrder checks out the requested products for the caller
+func (s *orderService) CreateOrder(ctx context.Context, caller model.Caller, req *model.CreateOrderRequest) (*model.Order, error) {
+	if caller.UserID == 0 {
+		return nil, ErrUserRequired
+	}
+	if err := s.validateCreate(req); err != nil {
+		return nil, err
+	}
+
+	// Lock products in id order so concurrent checkouts cannot deadlock
+	lines := append([]model.OrderLine(nil), req.Items...)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ProductID < lines[j].ProductID })
+
+	var order *model.Order
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		order = &model.Order{UserID: caller.UserID}
+		var events []*model.OutboxEvent
+		for i, line := range lines {
+			stock, err := s.products.AdjustStock(ctx, line.ProductID, -line.Quantity)
+			if err != nil {
+				return err
+			}
+			event, err := stockChangedEvent(line.ProductID, -line.Quantity, stock, model.StockReasonOrder)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+			// Read back inside the transaction, where the row is locked by our update
+			p, err := s.products.GetByID(ctx, line.ProductID)
+			if err != nil {
+				return err
+			}
+
+			order.Items = append(order.Items, &model.OrderItem{
+				ProductID:   p.ID,
+				ProductName: p.Name,
+				Quantity:    line.Quantity,
+				UnitPrice:   p.Price,
+			})
+			if i == 0 {
+				order.TotalAmount.Currency = p.Price.Currency
+			}
+			total, err := order.TotalAmount.Add(p.Price.Times(line.Quantity))
+			if err != nil {
+				return ErrCurrencyMismatch.WithMetadata("product_id", strconv.FormatInt(p.ID, 10))
+			}
+			order.TotalAmount = total
+		}
+		if err := s.repo.Create(ctx, order); err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, events...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// GetOrder retrieves an order placed by the caller, or any order for admins
+func (s *orderService) GetOrder(ctx context.Context, caller model.Caller, id int64) (*model.Order, error) {
+	if id <= 0 {
+		return nil, apperror.Invalid("id", "id is required")
+	}
+
+	order, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsAdmin() && order.UserID != caller.UserID {
+		return nil, ErrPermissionDenied
+	}
+
+	return order, nil
+}
+
+// ListMyOrders retrieves a page of the caller's orders, newest first
+func (s *orderService) ListMyOrders(ctx context.Context, caller model.Caller, req *model.ListOrdersRequest) (*model.OrderPage, error) {
+	if req.PageSize < 0 || req.PageSize > maxPageSize {
+		return nil, apperror.Invalid("page_size", fmt.Sprintf("page_size must be between 0 and %d", maxPageSize))
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+	req.UserID = caller.UserID
+
+	return s.repo.ListByUserID(ctx, req)
+}
+
+// CancelOrder cancels a placed order and returns its stock
+func (s *orderService) CancelOrder(ctx context.Context, caller model.Caller, id int64) (*model.Order, error) {
+	order, err := s.GetOrder(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Cancel(ctx, id); err != nil {
+			return err
+		}
+		var events []*model.OutboxEvent
+		for _, item := range order.Items {
+			// Products deleted since checkout have nothing to restock
+			if item.ProductID == 0 {
+				continue
+			}
+			stock, err := s.products.AdjustStock(ctx, item.ProductID, item.Quantity)
+			if apperror.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			event, err := stockChangedEvent(item.ProductID, item.Quantity, stock, model.StockReasonOrderCancelled)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return s.outbox.Add(ctx, events...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, id)
+}
+
+// validateCreate validates a checkout request, reporting every bad line
+func (s *orderService) validateCreate(req *model.CreateOrderRequest) error {
+	var v violations
+	v.check(len(req.Items) > 0, "items", "at least one item is required")
+	v.check(len(req.Items) <= maxOrderItems, "items", fmt.Sprintf("an order cannot have more than %d items", maxOrderItems))
+
+	seen := make(map[int64]bool, len(req.Items))
+	for i, line := range req.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		v.check(line.ProductID > 0, field+".product_id", "product_id is required")
+		v.check(line.Quantity > 0, field+".quantity", "quantity must be greater than zero")
+		v.check(!seen[line.ProductID], field+".product_id", fmt.Sprintf("product %d is listed more than once", line.ProductID))
+		seen[line.ProductID] = true
+	}
+
+	return v.err()
+}
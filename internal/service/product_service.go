@@ -1,120 +1,846 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"grpc-exmpl/internal/apperror"
 	"grpc-exmpl/internal/model"
 	"grpc-exmpl/internal/repository"
 )
 
 // ProductService defines business logic for product operations
 type ProductService interface {
-	CreateProduct(req *model.CreateProductRequest) (*model.Product, error)
-	GetProductByID(id int64) (*model.Product, error)
-	ListProductsByUser(userID int64) ([]*model.Product, error)
-	UpdateProduct(req *model.UpdateProductRequest) (*model.Product, error)
-	DeleteProduct(id int64) error
+	CreateProduct(ctx context.Context, req *model.CreateProductRequest) (*model.Product, error)
+	GetProductByID(ctx context.Context, id int64) (*model.Product, error)
+	ListProductsByUser(ctx context.Context, caller model.Caller, req *model.ListProductsRequest) (*model.ProductPage, error)
+	SearchProducts(ctx context.Context, req *model.SearchProductsRequest) (*model.ProductSearchResult, error)
+	UpdateProduct(ctx context.Context, caller model.Caller, req *model.UpdateProductRequest) (*model.Product, error)
+	DeleteProduct(ctx context.Context, caller model.Caller, id int64) error
+	BatchCreateProducts(ctx context.Context, req *model.BatchCreateProductsRequest) ([]*model.ProductResult, error)
+	BatchGetProducts(ctx context.Context, ids []int64) ([]*model.ProductResult, error)
+	BatchDeleteProducts(ctx context.Context, caller model.Caller, ids []int64) ([]*model.ProductResult, error)
+	ImportProducts(ctx context.Context, next func() (*model.CreateProductRequest, error), report func(*model.ImportProgress) error) error
+	ExportProducts(ctx context.Context, userID int64, send func(*model.Product) error) error
+	RestoreProduct(ctx context.Context, caller model.Caller, id int64) (*model.Product, error)
+	AdjustStock(ctx context.Context, caller model.Caller, productID int64, delta int) (int, error)
+	ReserveStock(ctx context.Context, caller model.Caller, req *model.ReserveStockRequest) (*model.StockReservation, error)
+	ReleaseStock(ctx context.Context, caller model.Caller, reservationID int64) error
+	CommitReservation(ctx context.Context, caller model.Caller, reservationID int64) error
+	ReleaseExpiredReservations(ctx context.Context, now time.Time) (int64, error)
 }
 
+// InventoryConfig controls how long stock reservations may be held
+type InventoryConfig struct {
+	ReservationTTL    time.Duration
+	MaxReservationTTL time.Duration
+}
+
+// BatchConfig bounds the number of items in one batch call and sets how
+// often a streamed import reports progress
+type BatchConfig struct {
+	MaxSize        int
+	ImportAckEvery int
+}
+
+// ErrPermissionDenied is returned when a non-admin caller acts on a product it does not own
+var ErrPermissionDenied = apperror.PermissionDenied("permission denied")
+
+// ErrUserRequired is returned when a service authenticated only by its
+// certificate calls a method whose result the caller would own
+var ErrUserRequired = apperror.PermissionDenied("this call must be made on behalf of a user")
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	maxProductTags = 20
+	maxTagLength   = 64
+
+	defaultMaxBatchSize   = 500
+	defaultImportAckEvery = 100
+	exportFetchSize       = 100
+)
+
+// productUpdateFields are the update_mask paths UpdateProduct accepts
+var productUpdateFields = []string{"name", "description", "price", "stock", "category_id", "tags"}
+
 type productService struct {
-	repo repository.ProductRepository
+	tx           repository.TxManager
+	repo         repository.ProductRepository
+	reservations repository.ReservationRepository
+	outbox       repository.OutboxRepository
+	inventory    InventoryConfig
+	batch        BatchConfig
 }
 
 // NewProductService creates a new instance of ProductService
-func NewProductService(repo repository.ProductRepository) ProductService {
-	return &productService{repo: repo}
+func NewProductService(tx repository.TxManager, repo repository.ProductRepository, reservations repository.ReservationRepository, outbox repository.OutboxRepository, inventory InventoryConfig, batch BatchConfig) ProductService {
+	if batch.MaxSize <= 0 {
+		batch.MaxSize = defaultMaxBatchSize
+	}
+	if batch.ImportAckEvery <= 0 {
+		batch.ImportAckEvery = defaultImportAckEvery
+	}
+	// Each acknowledged chunk is created as one batch
+	batch.ImportAckEvery = min(batch.ImportAckEvery, batch.MaxSize)
+	return &productService{tx: tx, repo: repo, reservations: reservations, outbox: outbox, inventory: inventory, batch: batch}
 }
 
 // CreateProduct handles product creation logic
-func (s *productService) CreateProduct(req *model.CreateProductRequest) (*model.Product, error) {
-	if err := s.validateCreate(req); err != nil {
+func (s *productService) CreateProduct(ctx context.Context, req *model.CreateProductRequest) (*model.Product, error) {
+	p, err := s.newProduct(req)
+	if err != nil {
 		return nil, err
 	}
 
-	p := &model.Product{
-		Name:        strings.TrimSpace(req.Name),
-		Description: strings.TrimSpace(req.Description),
-		Price:       req.Price,
-		Stock:       req.Stock,
-		UserID:      req.UserID,
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, p); err != nil {
+			return err
+		}
+		if err := s.repo.SetTags(ctx, p.ID, p.Tags); err != nil {
+			return err
+		}
+		events, err := productCreatedEvents(p)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, events...)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.repo.Create(p); err != nil {
+	return p, nil
+}
+
+// BatchCreateProducts creates many products with a single insert.
+//
+// With AllOrNothing every invalid item is reported in one Validation error
+// and nothing is written. Otherwise invalid items fail on their own and the
+// rest are still created; if the insert itself is rejected (a missing
+// category, say) the valid items are retried one by one to find the culprit.
+func (s *productService) BatchCreateProducts(ctx context.Context, req *model.BatchCreateProductsRequest) ([]*model.ProductResult, error) {
+	if err := s.validateBatchSize("products", len(req.Products)); err != nil {
 		return nil, err
 	}
 
-	return p, nil
+	results := make([]*model.ProductResult, len(req.Products))
+	var pending []*model.Product
+	var pendingIdx []int
+	var v violations
+	for i, item := range req.Products {
+		p, err := s.newProduct(item)
+		if err != nil {
+			results[i] = &model.ProductResult{Err: err}
+			v.addPrefixed(fmt.Sprintf("products[%d].", i), err)
+			continue
+		}
+		results[i] = &model.ProductResult{Product: p}
+		pending = append(pending, p)
+		pendingIdx = append(pendingIdx, i)
+	}
+	if req.AllOrNothing {
+		if err := v.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.createProducts(ctx, pending)
+	if err != nil {
+		if req.AllOrNothing || apperror.KindOf(err) == apperror.KindInternal {
+			return nil, err
+		}
+		for _, i := range pendingIdx {
+			if err := s.createProducts(ctx, []*model.Product{results[i].Product}); err != nil {
+				results[i] = &model.ProductResult{Err: err}
+			}
+		}
+	}
+
+	for _, r := range results {
+		if r.Product != nil {
+			r.ID = r.Product.ID
+		}
+	}
+	return results, nil
+}
+
+// BatchGetProducts retrieves products by ID, reporting missing ones per item
+func (s *productService) BatchGetProducts(ctx context.Context, ids []int64) ([]*model.ProductResult, error) {
+	if err := s.validateBatchIDs(ids); err != nil {
+		return nil, err
+	}
+
+	products, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*model.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	results := make([]*model.ProductResult, len(ids))
+	for i, id := range ids {
+		results[i] = &model.ProductResult{ID: id, Product: byID[id]}
+		if byID[id] == nil {
+			results[i].Err = apperror.NotFound("product")
+		}
+	}
+	return results, nil
+}
+
+// BatchDeleteProducts soft-deletes the products the caller may manage,
+// reporting missing and foreign products per item
+func (s *productService) BatchDeleteProducts(ctx context.Context, caller model.Caller, ids []int64) ([]*model.ProductResult, error) {
+	if err := s.validateBatchIDs(ids); err != nil {
+		return nil, err
+	}
+
+	products, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*model.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	results := make([]*model.ProductResult, len(ids))
+	var allowed []int64
+	for i, id := range ids {
+		results[i] = &model.ProductResult{ID: id}
+		switch p := byID[id]; {
+		case p == nil:
+			results[i].Err = apperror.NotFound("product")
+		case !canManage(caller, p):
+			results[i].Err = ErrPermissionDenied
+		default:
+			allowed = append(allowed, id)
+		}
+	}
+	if len(allowed) == 0 {
+		return results, nil
+	}
+
+	deleted, err := s.repo.DeleteBatch(ctx, allowed)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		// Deleted concurrently between the lookup and the delete
+		if r.Err == nil && !slices.Contains(deleted, r.ID) {
+			r.Err = apperror.NotFound("product")
+		}
+	}
+	return results, nil
 }
 
 // GetProductByID retrieves a product by ID
-func (s *productService) GetProductByID(id int64) (*model.Product, error) {
-	return s.repo.GetByID(id)
+func (s *productService) GetProductByID(ctx context.Context, id int64) (*model.Product, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListProductsByUser retrieves a filtered, sorted page of a user's products;
+// only admins may include soft-deleted ones
+func (s *productService) ListProductsByUser(ctx context.Context, caller model.Caller, req *model.ListProductsRequest) (*model.ProductPage, error) {
+	if err := s.validateList(req); err != nil {
+		return nil, err
+	}
+	if req.IncludeDeleted && !caller.IsAdmin() {
+		return nil, ErrPermissionDenied
+	}
+
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+	req.NameContains = strings.TrimSpace(req.NameContains)
+
+	return s.repo.ListByUserID(ctx, req)
 }
 
-// ListProductsByUser retrieves all products by a user ID
-func (s *productService) ListProductsByUser(userID int64) ([]*model.Product, error) {
-	return s.repo.ListByUserID(userID)
+// SearchProducts runs a ranked full-text search over product names and descriptions
+func (s *productService) SearchProducts(ctx context.Context, req *model.SearchProductsRequest) (*model.ProductSearchResult, error) {
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return nil, apperror.Invalid("query", "query is required")
+	}
+	if req.UserID < 0 {
+		return nil, apperror.Invalid("user_id", "user_id cannot be negative")
+	}
+	if req.PageSize < 0 || req.PageSize > maxPageSize {
+		return nil, apperror.Invalid("page_size", fmt.Sprintf("page_size must be between 0 and %d", maxPageSize))
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+
+	return s.repo.Search(ctx, req)
 }
 
-// UpdateProduct updates existing product data
-func (s *productService) UpdateProduct(req *model.UpdateProductRequest) (*model.Product, error) {
-	if err := s.validateUpdate(req); err != nil {
+// UpdateProduct updates the fields named in the request's mask on behalf of the
+// product's owner or an admin, provided nobody changed the product since the
+// caller read req.Version
+func (s *productService) UpdateProduct(ctx context.Context, caller model.Caller, req *model.UpdateProductRequest) (*model.Product, error) {
+	fields, err := resolveUpdateMask(req.UpdateMask, productUpdateFields)
+	if err != nil {
+		return nil, err
+	}
+	normalizeCurrency(&req.Price)
+	req.Tags = normalizeTags(req.Tags)
+	if err := s.validateUpdate(req, fields); err != nil {
 		return nil, err
 	}
 
-	existing, err := s.repo.GetByID(req.ID)
+	existing, err := s.repo.GetByID(ctx, req.ID)
 	if err != nil {
 		return nil, err
 	}
+	if !canManage(caller, existing) {
+		return nil, ErrPermissionDenied
+	}
+	if existing.Version != req.Version {
+		return nil, repository.ErrVersionMismatch.WithMetadata("current_version", strconv.FormatInt(existing.Version, 10))
+	}
+
+	oldStock := existing.Stock
+	for _, field := range fields {
+		switch field {
+		case "name":
+			existing.Name = strings.TrimSpace(req.Name)
+		case "description":
+			existing.Description = strings.TrimSpace(req.Description)
+		case "price":
+			existing.Price = req.Price
+		case "stock":
+			existing.Stock = req.Stock
+		case "category_id":
+			existing.CategoryID = req.CategoryID
+		case "tags":
+			existing.Tags = req.Tags
+		}
+	}
 
-	existing.Name = strings.TrimSpace(req.Name)
-	existing.Description = strings.TrimSpace(req.Description)
-	existing.Price = req.Price
-	existing.Stock = req.Stock
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, existing, fields); err != nil {
+			return err
+		}
+		if slices.Contains(fields, "tags") {
+			if err := s.repo.SetTags(ctx, existing.ID, existing.Tags); err != nil {
+				return err
+			}
+		}
 
-	if err := s.repo.Update(existing); err != nil {
+		updated, err := newEvent(model.EventProductUpdated, model.AggregateProduct, existing.ID, model.ProductChangedEvent{Product: existing, Fields: fields})
+		if err != nil {
+			return err
+		}
+		events := []*model.OutboxEvent{updated}
+		if delta := existing.Stock - oldStock; delta != 0 {
+			stock, err := stockChangedEvent(existing.ID, delta, existing.Stock, model.StockReasonUpdate)
+			if err != nil {
+				return err
+			}
+			events = append(events, stock)
+		}
+		return s.outbox.Add(ctx, events...)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return existing, nil
 }
 
-// DeleteProduct deletes a product by ID
-func (s *productService) DeleteProduct(id int64) error {
-	return s.repo.Delete(id)
+// DeleteProduct deletes a product by ID on behalf of its owner or an admin
+func (s *productService) DeleteProduct(ctx context.Context, caller model.Caller, id int64) error {
+	existing, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !canManage(caller, existing) {
+		return ErrPermissionDenied
+	}
+
+	return s.repo.Delete(ctx, id)
+}
+
+// RestoreProduct undoes a soft delete on behalf of the product's owner or an admin
+func (s *productService) RestoreProduct(ctx context.Context, caller model.Caller, id int64) (*model.Product, error) {
+	if id <= 0 {
+		return nil, apperror.Invalid("id", "id is required")
+	}
+
+	deleted, err := s.repo.GetDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(caller, deleted) {
+		return nil, ErrPermissionDenied
+	}
+
+	if err := s.repo.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, id)
+}
+
+// ImportProducts creates the products read from next until it returns io.EOF.
+//
+// Items are created in chunks of ImportAckEvery, each like a per-item
+// BatchCreateProducts, and report is called after every chunk and once more
+// with Done set at the end. A failing item only shows up in the report; an
+// error from next, report or the database stops the import, leaving the
+// chunks already reported in place.
+func (s *productService) ImportProducts(ctx context.Context, next func() (*model.CreateProductRequest, error), report func(*model.ImportProgress) error) error {
+	progress := model.ImportProgress{}
+	chunk := make([]*model.CreateProductRequest, 0, s.batch.ImportAckEvery)
+
+	flush := func(done bool) error {
+		if len(chunk) > 0 {
+			results, err := s.BatchCreateProducts(ctx, &model.BatchCreateProductsRequest{Products: chunk})
+			if err != nil {
+				return err
+			}
+			first := progress.Received - int64(len(chunk))
+			for i, r := range results {
+				if r.Err != nil {
+					progress.Failed++
+					progress.Errors = append(progress.Errors, &model.ImportError{Index: first + int64(i), Err: r.Err})
+					continue
+				}
+				progress.Created++
+			}
+			chunk = chunk[:0]
+		}
+
+		progress.Done = done
+		ack := progress
+		progress.Errors = nil
+		return report(&ack)
+	}
+
+	for {
+		item, err := next()
+		if errors.Is(err, io.EOF) {
+			return flush(true)
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk = append(chunk, item)
+		progress.Received++
+		if len(chunk) == s.batch.ImportAckEvery {
+			if err := flush(false); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ExportProducts passes every live product of a user to send, in id order,
+// without loading the whole catalog at once
+func (s *productService) ExportProducts(ctx context.Context, userID int64, send func(*model.Product) error) error {
+	if userID <= 0 {
+		return apperror.Invalid("user_id", "user_id is required")
+	}
+	return s.repo.Export(ctx, userID, exportFetchSize, send)
+}
+
+// AdjustStock atomically changes a product's stock by delta on behalf of its owner or an admin
+func (s *productService) AdjustStock(ctx context.Context, caller model.Caller, productID int64, delta int) (int, error) {
+	var v violations
+	v.check(productID > 0, "product_id", "product_id is required")
+	v.check(delta != 0, "delta", "delta must not be zero")
+	if err := v.err(); err != nil {
+		return 0, err
+	}
+
+	existing, err := s.repo.GetByID(ctx, productID)
+	if err != nil {
+		return 0, err
+	}
+	if !canManage(caller, existing) {
+		return 0, ErrPermissionDenied
+	}
+
+	var stock int
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if stock, err = s.repo.AdjustStock(ctx, productID, delta); err != nil {
+			return err
+		}
+		event, err := stockChangedEvent(productID, delta, stock, model.StockReasonAdjustment)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, event)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return stock, nil
+}
+
+// ReserveStock places an expiring hold on product stock for the caller
+func (s *productService) ReserveStock(ctx context.Context, caller model.Caller, req *model.ReserveStockRequest) (*model.StockReservation, error) {
+	if caller.UserID == 0 {
+		return nil, ErrUserRequired
+	}
+
+	var v violations
+	v.check(req.ProductID > 0, "product_id", "product_id is required")
+	v.check(req.Quantity > 0, "quantity", "quantity must be greater than zero")
+	v.check(req.TTL >= 0, "ttl_seconds", "ttl_seconds cannot be negative")
+	v.check(s.inventory.MaxReservationTTL <= 0 || req.TTL <= s.inventory.MaxReservationTTL,
+		"ttl_seconds", fmt.Sprintf("ttl_seconds cannot exceed %d", int(s.inventory.MaxReservationTTL.Seconds())))
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = s.inventory.ReservationTTL
+	}
+
+	res := &model.StockReservation{
+		ProductID: req.ProductID,
+		UserID:    caller.UserID,
+		Quantity:  req.Quantity,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		stock, err := s.reservations.Reserve(ctx, res)
+		if err != nil {
+			return err
+		}
+		event, err := stockChangedEvent(res.ProductID, -res.Quantity, stock, model.StockReasonReserved)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ReleaseStock cancels a reservation held by the caller, returning its units to stock
+func (s *productService) ReleaseStock(ctx context.Context, caller model.Caller, reservationID int64) error {
+	res, err := s.ownReservation(ctx, caller, reservationID)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		stock, err := s.reservations.Release(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		event, err := stockChangedEvent(res.ProductID, res.Quantity, stock, model.StockReasonReleased)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, event)
+	})
+}
+
+// CommitReservation makes a reservation held by the caller permanent
+func (s *productService) CommitReservation(ctx context.Context, caller model.Caller, reservationID int64) error {
+	res, err := s.ownReservation(ctx, caller, reservationID)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		stock, err := s.reservations.Commit(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		event, err := stockChangedEvent(res.ProductID, 0, stock, model.StockReasonCommitted)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, event)
+	})
+}
+
+// ReleaseExpiredReservations returns the units of every reservation that
+// expired by now to stock, writing one StockChanged event per product, and
+// reports how many reservations were released
+func (s *productService) ReleaseExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	var released int64
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		released = 0
+		products, err := s.reservations.ReleaseExpired(ctx, now)
+		if err != nil {
+			return err
+		}
+		events := make([]*model.OutboxEvent, 0, len(products))
+		for _, p := range products {
+			event, err := stockChangedEvent(p.ProductID, p.Quantity, p.Stock, model.StockReasonExpired)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+			released += p.Reservations
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		return s.outbox.Add(ctx, events...)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return released, nil
 }
 
-// validateCreate validates product creation request
+// ownReservation loads a reservation the caller holds, or any reservation for admins
+func (s *productService) ownReservation(ctx context.Context, caller model.Caller, id int64) (*model.StockReservation, error) {
+	if id <= 0 {
+		return nil, apperror.Invalid("reservation_id", "reservation_id is required")
+	}
+
+	res, err := s.reservations.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsAdmin() && res.UserID != caller.UserID {
+		return nil, ErrPermissionDenied
+	}
+
+	return res, nil
+}
+
+// newProduct normalizes and validates a creation request into a product ready to insert
+func (s *productService) newProduct(req *model.CreateProductRequest) (*model.Product, error) {
+	normalizeCurrency(&req.Price)
+	req.Tags = normalizeTags(req.Tags)
+	if err := s.validateCreate(req); err != nil {
+		return nil, err
+	}
+
+	return &model.Product{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Price:       req.Price,
+		Stock:       req.Stock,
+		UserID:      req.UserID,
+		CategoryID:  req.CategoryID,
+		Tags:        req.Tags,
+	}, nil
+}
+
+// createProducts inserts products, their tags and their ProductCreated events in one transaction
+func (s *productService) createProducts(ctx context.Context, products []*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateBatch(ctx, products); err != nil {
+			return err
+		}
+		for _, p := range products {
+			if err := s.repo.SetTags(ctx, p.ID, p.Tags); err != nil {
+				return err
+			}
+		}
+		events, err := productCreatedEvents(products...)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, events...)
+	})
+}
+
+// canManage reports whether the caller may modify the product
+func canManage(caller model.Caller, p *model.Product) bool {
+	return caller.IsAdmin() || p.UserID == caller.UserID
+}
+
+// validateCreate validates product creation request, reporting every bad field
 func (s *productService) validateCreate(req *model.CreateProductRequest) error {
-	if strings.TrimSpace(req.Name) == "" {
-		return fmt.Errorf("name is required")
+	var v violations
+	v.check(strings.TrimSpace(req.Name) != "", "name", "name is required")
+	v.check(req.Price.Amount > 0, "price", "price must be greater than zero")
+	v.checkCurrency("price", req.Price)
+	v.check(req.Stock >= 0, "stock", "stock cannot be negative")
+	v.check(req.UserID > 0, "user_id", "user_id is required")
+	v.check(req.CategoryID >= 0, "category_id", "category_id cannot be negative")
+	v.checkTags(req.Tags)
+	return v.err()
+}
+
+// validateUpdate validates the masked fields of a product update request, reporting every bad field
+func (s *productService) validateUpdate(req *model.UpdateProductRequest, fields []string) error {
+	var v violations
+	v.check(req.ID > 0, "id", "id is required")
+	v.check(req.Version > 0, "version", "version is required")
+	for _, field := range fields {
+		switch field {
+		case "name":
+			v.check(strings.TrimSpace(req.Name) != "", "name", "name is required")
+		case "price":
+			v.check(req.Price.Amount > 0, "price", "price must be greater than zero")
+			v.checkCurrency("price", req.Price)
+		case "stock":
+			v.check(req.Stock >= 0, "stock", "stock cannot be negative")
+		case "category_id":
+			v.check(req.CategoryID >= 0, "category_id", "category_id cannot be negative")
+		case "tags":
+			v.checkTags(req.Tags)
+		}
 	}
-	if req.Price <= 0 {
-		return fmt.Errorf("price must be greater than zero")
+	return v.err()
+}
+
+// validateBatchSize checks that a batch has between one and the configured maximum items
+func (s *productService) validateBatchSize(field string, n int) error {
+	if n == 0 {
+		return apperror.Invalid(field, field+" must not be empty")
+	}
+	if n > s.batch.MaxSize {
+		return apperror.Invalid(field, fmt.Sprintf("a batch cannot have more than %d items", s.batch.MaxSize))
 	}
-	if req.Stock < 0 {
-		return fmt.Errorf("stock cannot be negative")
+	return nil
+}
+
+// validateBatchIDs validates the product IDs of a batch lookup or delete, reporting every bad ID
+func (s *productService) validateBatchIDs(ids []int64) error {
+	if err := s.validateBatchSize("ids", len(ids)); err != nil {
+		return err
 	}
+
+	var v violations
+	seen := make(map[int64]bool, len(ids))
+	for i, id := range ids {
+		field := fmt.Sprintf("ids[%d]", i)
+		v.check(id > 0, field, "id must be greater than zero")
+		v.check(!seen[id], field, fmt.Sprintf("id %d is repeated", id))
+		seen[id] = true
+	}
+	return v.err()
+}
+
+// validateList validates a product listing request and resolves its sort order
+func (s *productService) validateList(req *model.ListProductsRequest) error {
 	if req.UserID <= 0 {
-		return fmt.Errorf("user_id is required")
+		return apperror.Invalid("user_id", "user_id is required")
+	}
+	if req.PageSize < 0 || req.PageSize > maxPageSize {
+		return apperror.Invalid("page_size", fmt.Sprintf("page_size must be between 0 and %d", maxPageSize))
+	}
+	if err := validatePriceRange(req.MinPrice, req.MaxPrice); err != nil {
+		return err
+	}
+	if req.MinStock != nil && req.MaxStock != nil && *req.MinStock > *req.MaxStock {
+		return apperror.Invalid("min_stock", "min_stock cannot be greater than max_stock")
+	}
+	if req.CategoryID < 0 {
+		return apperror.Invalid("category_id", "category_id cannot be negative")
 	}
+
+	sortBy, desc, err := parseOrderBy(req.OrderBy)
+	if err != nil {
+		return err
+	}
+	req.SortBy = sortBy
+	req.SortDesc = desc
 	return nil
 }
 
-// validateUpdate validates product update request
-func (s *productService) validateUpdate(req *model.UpdateProductRequest) error {
-	if req.ID <= 0 {
-		return fmt.Errorf("id is required")
+// normalizeTags lower-cases and trims tags, dropping blanks and duplicates
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
 	}
-	if strings.TrimSpace(req.Name) == "" {
-		return fmt.Errorf("name is required")
+	return out
+}
+
+// checkTags records violations for oversized tag lists or tags
+func (v *violations) checkTags(tags []string) {
+	v.check(len(tags) <= maxProductTags, "tags", fmt.Sprintf("a product cannot have more than %d tags", maxProductTags))
+	for _, tag := range tags {
+		v.check(len(tag) <= maxTagLength, "tags", fmt.Sprintf("tag %q is longer than %d characters", tag, maxTagLength))
 	}
-	if req.Price <= 0 {
-		return fmt.Errorf("price must be greater than zero")
+}
+
+// validatePriceRange validates optional price bounds, which must share a currency
+func validatePriceRange(minPrice, maxPrice *model.Money) error {
+	var v violations
+	bounds := []struct {
+		field string
+		price *model.Money
+	}{{"min_price", minPrice}, {"max_price", maxPrice}}
+	for _, b := range bounds {
+		if b.price == nil {
+			continue
+		}
+		normalizeCurrency(b.price)
+		v.check(b.price.Amount >= 0, b.field, b.field+" cannot be negative")
+		v.checkCurrency(b.field, *b.price)
+	}
+	if err := v.err(); err != nil {
+		return err
 	}
-	if req.Stock < 0 {
-		return fmt.Errorf("stock cannot be negative")
+
+	if minPrice != nil && maxPrice != nil {
+		if minPrice.Currency != maxPrice.Currency {
+			return apperror.Invalid("max_price.currency_code", "min_price and max_price must use the same currency")
+		}
+		if minPrice.Amount > maxPrice.Amount {
+			return apperror.Invalid("min_price", "min_price cannot be greater than max_price")
+		}
 	}
 	return nil
 }
+
+// parseOrderBy parses an "field [asc|desc]" order clause.
+// An empty clause keeps the historical newest-first ordering.
+func parseOrderBy(orderBy string) (model.ProductSortField, bool, error) {
+	parts := strings.Fields(strings.ToLower(orderBy))
+	if len(parts) == 0 {
+		return model.ProductSortCreatedAt, true, nil
+	}
+	if len(parts) > 2 {
+		return "", false, apperror.Invalid("order_by", fmt.Sprintf("invalid order_by: %s", orderBy))
+	}
+
+	field := model.ProductSortField(parts[0])
+	switch field {
+	case model.ProductSortCreatedAt, model.ProductSortPrice, model.ProductSortName, model.ProductSortStock:
+	default:
+		return "", false, apperror.Invalid("order_by", fmt.Sprintf("unsupported order_by field: %s", parts[0]))
+	}
+
+	if len(parts) == 1 {
+		return field, false, nil
+	}
+	switch parts[1] {
+	case "asc":
+		return field, false, nil
+	case "desc":
+		return field, true, nil
+	default:
+		return "", false, apperror.Invalid("order_by", fmt.Sprintf("invalid order_by direction: %s", parts[1]))
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+)
+
+// ProductWatcher streams product changes to storefronts
+type ProductWatcher interface {
+	WatchProducts(ctx context.Context, req *model.WatchProductsRequest, send func(*model.ProductEvent) error) error
+}
+
+// WatchConfig controls the product change feed
+type WatchConfig struct {
+	// PollInterval rereads the feed even without a notification, in case
+	// one was lost while the listener reconnected
+	PollInterval time.Duration
+	// HeldBackInterval rereads the feed while committed events wait behind
+	// a transaction still running elsewhere, whose end sends no notification
+	// unless it writes products itself
+	HeldBackInterval time.Duration
+}
+
+// ErrResumeTokenExpired is returned when the event a resume token points at has been pruned
+var ErrResumeTokenExpired = apperror.Conflict("RESUME_TOKEN_EXPIRED", "resume token has expired; reload the products and watch again")
+
+const (
+	defaultWatchPollInterval = 30 * time.Second
+	defaultWatchHeldBack     = 250 * time.Millisecond
+	watchPageSize            = 100
+	maxWatchedProducts       = 500
+)
+
+// ProductFeed wakes watchers when the change feed has new events. Wake-ups
+// carry no data and coalesce, so a slow watcher never holds up the others;
+// it reads everything new on its next turn.
+type ProductFeed struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]struct{}
+}
+
+// NewProductFeed creates a new ProductFeed
+func NewProductFeed() *ProductFeed {
+	return &ProductFeed{subs: make(map[chan struct{}]struct{})}
+}
+
+// Notify wakes every watcher
+func (f *ProductFeed) Notify() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel woken by Notify and a func that closes the subscription
+func (f *ProductFeed) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	f.mu.Lock()
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+	}
+}
+
+type productWatcher struct {
+	events   repository.ProductEventRepository
+	products repository.ProductRepository
+	feed     *ProductFeed
+	cfg      WatchConfig
+}
+
+// NewProductWatcher creates a new instance of ProductWatcher
+func NewProductWatcher(events repository.ProductEventRepository, products repository.ProductRepository, feed *ProductFeed, cfg WatchConfig) ProductWatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultWatchPollInterval
+	}
+	if cfg.HeldBackInterval <= 0 {
+		cfg.HeldBackInterval = defaultWatchHeldBack
+	}
+	return &productWatcher{events: events, products: products, feed: feed, cfg: cfg}
+}
+
+// WatchProducts sends matching product events until ctx ends or send fails
+func (w *productWatcher) WatchProducts(ctx context.Context, req *model.WatchProductsRequest, send func(*model.ProductEvent) error) error {
+	if err := validateWatch(req); err != nil {
+		return err
+	}
+
+	// Subscribe before reading the start position so no wake-up is missed
+	wake, unsubscribe := w.feed.subscribe()
+	defer unsubscribe()
+
+	pos, err := w.start(ctx, req.ResumeToken)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, heldBack, err := w.events.ListAfter(ctx, pos, &req.ProductEventFilter, watchPageSize)
+		if err != nil {
+			return err
+		}
+		if err := w.attachProducts(ctx, events); err != nil {
+			return err
+		}
+		for _, e := range events {
+			pos = e.Position()
+			e.ResumeToken = encodeResumeToken(pos)
+			if err := send(e); err != nil {
+				return err
+			}
+		}
+		if len(events) == watchPageSize {
+			continue
+		}
+
+		var retry <-chan time.Time
+		if heldBack {
+			retry = time.After(w.cfg.HeldBackInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-ticker.C:
+		case <-retry:
+		}
+	}
+}
+
+// start resolves where a watch begins: after the resumed event, or at the head of the feed
+func (w *productWatcher) start(ctx context.Context, token string) (model.ProductEventCursor, error) {
+	if token == "" {
+		return w.events.Head(ctx)
+	}
+
+	pos, err := decodeResumeToken(token)
+	if err != nil {
+		return pos, err
+	}
+	exists, err := w.events.Exists(ctx, pos.ID)
+	if err != nil {
+		return pos, err
+	}
+	if !exists {
+		return pos, ErrResumeTokenExpired
+	}
+	return pos, nil
+}
+
+// attachProducts loads the current state of the products that were created or updated
+func (w *productWatcher) attachProducts(ctx context.Context, events []*model.ProductEvent) error {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, e := range events {
+		if e.Type != model.ProductDeleted && !seen[e.ProductID] {
+			seen[e.ProductID] = true
+			ids = append(ids, e.ProductID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	products, err := w.products.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]*model.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	for _, e := range events {
+		if e.Type != model.ProductDeleted {
+			e.Product = byID[e.ProductID]
+		}
+	}
+	return nil
+}
+
+// validateWatch validates the feed filter, reporting every bad field
+func validateWatch(req *model.WatchProductsRequest) error {
+	var v violations
+	v.check(req.UserID >= 0, "user_id", "user_id cannot be negative")
+	v.check(len(req.ProductIDs) <= maxWatchedProducts, "product_ids", fmt.Sprintf("cannot watch more than %d products", maxWatchedProducts))
+	for i, id := range req.ProductIDs {
+		v.check(id > 0, fmt.Sprintf("product_ids[%d]", i), "id must be greater than zero")
+	}
+	return v.err()
+}
+
+// encodeResumeToken renders a feed position as an opaque token
+func encodeResumeToken(pos model.ProductEventCursor) string {
+	return strconv.FormatInt(pos.TxID, 36) + "." + strconv.FormatInt(pos.ID, 36)
+}
+
+// decodeResumeToken parses a token produced by encodeResumeToken
+func decodeResumeToken(token string) (model.ProductEventCursor, error) {
+	invalid := apperror.Invalid("resume_token", "malformed resume token")
+
+	txid, id, ok := strings.Cut(token, ".")
+	if !ok {
+		return model.ProductEventCursor{}, invalid
+	}
+	var pos model.ProductEventCursor
+	var err error
+	if pos.TxID, err = strconv.ParseInt(txid, 36, 64); err != nil || pos.TxID <= 0 {
+		return pos, invalid
+	}
+	if pos.ID, err = strconv.ParseInt(id, 36, 64); err != nil || pos.ID <= 0 {
+		return pos, invalid
+	}
+	return pos, nil
+}
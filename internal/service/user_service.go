@@ -1,46 +1,78 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"grpc-exmpl/internal/apperror"
 	"grpc-exmpl/internal/model"
 	"grpc-exmpl/internal/repository"
 	"grpc-exmpl/pkg/utils"
 	"strings"
+	"time"
 )
 
+// profileUpdateFields are the update_mask paths UpdateProfile accepts
+var profileUpdateFields = []string{"username", "email", "full_name"}
+
+// errInvalidCredentials deliberately does not say which of email or password was wrong
+var errInvalidCredentials = apperror.Unauthenticated("invalid email or password")
+
 type UserService interface {
-	Register(req *model.RegisterRequest) (*model.User, error)
-	Login(req *model.LoginRequest) (*model.LoginResponse, error)
-	GetProfile(token string) (*model.User, error)
-	GetUserByID(id int64) (*model.User, error)
-	ValidateToken(token string) (*utils.JWTClaims, error)
+	Register(ctx context.Context, req *model.RegisterRequest) (*model.User, error)
+	Login(ctx context.Context, req *model.LoginRequest) (*model.LoginResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*model.LoginResponse, error)
+	Logout(ctx context.Context, refreshToken string) error
+	GetProfile(ctx context.Context, token string) (*model.User, error)
+	UpdateProfile(ctx context.Context, caller model.Caller, req *model.UpdateProfileRequest) (*model.User, error)
+	GetUserByID(ctx context.Context, id int64) (*model.User, error)
+	ValidateToken(ctx context.Context, token string) (*utils.JWTClaims, error)
+	GetPublicKeys() []utils.JWK
+	DeleteUser(ctx context.Context, caller model.Caller, id int64) error
+	RestoreUser(ctx context.Context, caller model.Caller, id int64) (*model.User, error)
+}
+
+// TokenConfig controls how access and refresh tokens are issued
+type TokenConfig struct {
+	Keys       *utils.KeySet
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
 }
 
 type userService struct {
+	tx        repository.TxManager
 	userRepo  repository.UserRepository
-	jwtSecret string
+	tokenRepo repository.RefreshTokenRepository
+	outbox    repository.OutboxRepository
+	tokens    TokenConfig
 }
 
-func NewUserService(userRepo repository.UserRepository, jwtSecret string) UserService {
+func NewUserService(tx repository.TxManager, userRepo repository.UserRepository, tokenRepo repository.RefreshTokenRepository, outbox repository.OutboxRepository, tokens TokenConfig) UserService {
 	return &userService{
+		tx:        tx,
 		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		tokenRepo: tokenRepo,
+		outbox:    outbox,
+		tokens:    tokens,
 	}
 }
 
-func (s *userService) Register(req *model.RegisterRequest) (*model.User, error) {
+func (s *userService) Register(ctx context.Context, req *model.RegisterRequest) (*model.User, error) {
 	// Validate input
 	if err := s.validateRegisterRequest(req); err != nil {
 		return nil, err
 	}
 
 	// Check if user already exists
-	if _, err := s.userRepo.GetByEmail(req.Email); err == nil {
-		return nil, fmt.Errorf("user with email %s already exists", req.Email)
+	if _, err := s.userRepo.GetByEmail(ctx, req.Email); err == nil {
+		return nil, apperror.AlreadyExists("user", "email")
+	} else if !apperror.IsNotFound(err) {
+		return nil, err
 	}
 
-	if _, err := s.userRepo.GetByUsername(req.Username); err == nil {
-		return nil, fmt.Errorf("user with username %s already exists", req.Username)
+	if _, err := s.userRepo.GetByUsername(ctx, req.Username); err == nil {
+		return nil, apperror.AlreadyExists("user", "username")
+	} else if !apperror.IsNotFound(err) {
+		return nil, err
 	}
 
 	// Hash password
@@ -55,126 +87,352 @@ func (s *userService) Register(req *model.RegisterRequest) (*model.User, error)
 		Email:    strings.ToLower(strings.TrimSpace(req.Email)),
 		Password: hashedPassword,
 		FullName: req.FullName,
+		Roles:    []string{model.RoleUser},
 	}
 
-	if err := s.userRepo.Create(user); err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		event, err := newEvent(model.EventUserRegistered, model.AggregateUser, user.ID, model.UserRegisteredEvent{
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			FullName: user.FullName,
+		})
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, event)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return user, nil
 }
 
-func (s *userService) Login(req *model.LoginRequest) (*model.LoginResponse, error) {
+func (s *userService) Login(ctx context.Context, req *model.LoginRequest) (*model.LoginResponse, error) {
 	// Validate input
 	if err := s.validateLoginRequest(req); err != nil {
 		return nil, err
 	}
 
 	// Get user by email
-	user, err := s.userRepo.GetByEmail(strings.ToLower(strings.TrimSpace(req.Email)))
+	user, err := s.userRepo.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
+	if apperror.IsNotFound(err) {
+		return nil, errInvalidCredentials
+	}
 	if err != nil {
-		return nil, fmt.Errorf("invalid email or password")
+		return nil, err
 	}
 
 	// Check password
 	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		return nil, fmt.Errorf("invalid email or password")
+		return nil, errInvalidCredentials
+	}
+
+	// Start a new session
+	familyID, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueTokens(ctx, user, familyID)
+}
+
+func (s *userService) RefreshToken(ctx context.Context, refreshToken string) (*model.LoginResponse, error) {
+	if refreshToken == "" {
+		return nil, apperror.Invalid("refresh_token", "refresh token is required")
+	}
+
+	stored, err := s.getRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if stored.RevokedAt != nil {
+		return nil, apperror.Unauthenticated("session has been revoked")
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, apperror.Unauthenticated("refresh token expired")
+	}
+
+	// Burning the old token and issuing its successor commit together, so
+	// a failure part way leaves the presented token usable for a retry
+	var resp *model.LoginResponse
+	reused := false
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		reused = false
+		used, err := s.tokenRepo.MarkUsed(ctx, stored.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			reused = true
+			return nil
+		}
+
+		user, err := s.userRepo.GetByID(ctx, stored.UserID)
+		if err != nil {
+			if apperror.IsNotFound(err) {
+				return apperror.Unauthenticated("user no longer exists")
+			}
+			return err
+		}
+
+		resp, err = s.issueTokens(ctx, user, stored.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID, user.Email, user.Username, s.jwtSecret)
+	// A second exchange of the same token means it leaked; kill the session
+	if reused {
+		if err := s.tokenRepo.RevokeFamily(ctx, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, apperror.Unauthenticated("refresh token reuse detected, session revoked")
+	}
+
+	return resp, nil
+}
+
+func (s *userService) Logout(ctx context.Context, refreshToken string) error {
+	if refreshToken == "" {
+		return apperror.Invalid("refresh_token", "refresh token is required")
+	}
+
+	stored, err := s.getRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return s.tokenRepo.RevokeFamily(ctx, stored.FamilyID)
+}
+
+// getRefreshToken looks up a presented refresh token, treating unknown tokens as bad credentials
+func (s *userService) getRefreshToken(ctx context.Context, refreshToken string) (*model.RefreshToken, error) {
+	stored, err := s.tokenRepo.GetByHash(ctx, utils.HashToken(refreshToken))
+	if apperror.IsNotFound(err) {
+		return nil, apperror.Unauthenticated("invalid refresh token")
+	}
+	return stored, err
+}
+
+// issueTokens creates an access token and a new refresh token in the session
+func (s *userService) issueTokens(ctx context.Context, user *model.User, familyID string) (*model.LoginResponse, error) {
+	now := time.Now()
+
+	token, err := utils.GenerateJWT(utils.JWTClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Username:  user.Username,
+		Roles:     user.Roles,
+		SessionID: familyID,
+	}, s.tokens.AccessTTL, s.tokens.Keys)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	refreshToken, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if err := s.tokenRepo.Create(ctx, &model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: now.Add(s.tokens.RefreshTTL),
+	}); err != nil {
+		return nil, err
+	}
+
 	return &model.LoginResponse{
-		Token: token,
-		User:  user,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    now.Add(s.tokens.AccessTTL),
+		User:         user,
 	}, nil
 }
 
-func (s *userService) GetProfile(token string) (*model.User, error) {
+func (s *userService) GetProfile(ctx context.Context, token string) (*model.User, error) {
 	// Validate token
-	claims, err := s.ValidateToken(token)
+	claims, err := s.ValidateToken(ctx, token)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get user by ID
-	user, err := s.userRepo.GetByID(claims.UserID)
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateProfile changes the fields of the caller's own profile named in the request's mask
+func (s *userService) UpdateProfile(ctx context.Context, caller model.Caller, req *model.UpdateProfileRequest) (*model.User, error) {
+	fields, err := resolveUpdateMask(req.UpdateMask, profileUpdateFields)
 	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+		return nil, err
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := s.validateUpdateProfileRequest(req, fields); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, caller.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		switch field {
+		case "username":
+			user.Username = req.Username
+		case "email":
+			user.Email = req.Email
+		case "full_name":
+			user.FullName = req.FullName
+		}
+	}
+
+	if err := s.userRepo.Update(ctx, user, fields); err != nil {
+		return nil, err
 	}
 
 	return user, nil
 }
 
-func (s *userService) GetUserByID(id int64) (*model.User, error) {
-	user, err := s.userRepo.GetByID(id)
+func (s *userService) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+		return nil, err
 	}
 
 	return user, nil
 }
 
-func (s *userService) ValidateToken(token string) (*utils.JWTClaims, error) {
+func (s *userService) ValidateToken(ctx context.Context, token string) (*utils.JWTClaims, error) {
 	if token == "" {
-		return nil, fmt.Errorf("token is required")
+		return nil, apperror.Unauthenticated("token is required")
+	}
+
+	claims, err := utils.ValidateJWT(token, s.tokens.Keys)
+	if err != nil {
+		return nil, &apperror.Error{Kind: apperror.KindUnauthenticated, Reason: "INVALID_TOKEN", Message: "invalid token", Err: err}
 	}
 
-	claims, err := utils.ValidateJWT(token, s.jwtSecret)
+	// Access tokens die with their session
+	if claims.SessionID == "" {
+		return nil, apperror.Unauthenticated("invalid token: missing session")
+	}
+	active, err := s.tokenRepo.IsFamilyActive(ctx, claims.SessionID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid token: %w", err)
+		return nil, err
+	}
+	if !active {
+		return nil, apperror.Unauthenticated("invalid token: session revoked")
 	}
 
 	return claims, nil
 }
 
+// GetPublicKeys returns the keys other services need to verify access tokens
+func (s *userService) GetPublicKeys() []utils.JWK {
+	return s.tokens.Keys.PublicKeys()
+}
+
+// DeleteUser soft-deletes a user and their products; users may delete themselves, admins anyone
+func (s *userService) DeleteUser(ctx context.Context, caller model.Caller, id int64) error {
+	if id <= 0 {
+		return apperror.Invalid("id", "id is required")
+	}
+	if !caller.IsAdmin() && caller.UserID != id {
+		return ErrPermissionDenied
+	}
+
+	return s.userRepo.Delete(ctx, id)
+}
+
+// RestoreUser undoes a soft delete together with the products deleted with the user; admins only
+func (s *userService) RestoreUser(ctx context.Context, caller model.Caller, id int64) (*model.User, error) {
+	if !caller.IsAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	if id <= 0 {
+		return nil, apperror.Invalid("id", "id is required")
+	}
+
+	if err := s.userRepo.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetByID(ctx, id)
+}
+
 // Validation helpers
 func (s *userService) validateRegisterRequest(req *model.RegisterRequest) error {
+	var v violations
+
 	if req.Username == "" {
-		return fmt.Errorf("username is required")
-	}
-	if len(req.Username) < 3 || len(req.Username) > 50 {
-		return fmt.Errorf("username must be between 3 and 50 characters")
+		v.add("username", "username is required")
+	} else {
+		v.check(len(req.Username) >= 3 && len(req.Username) <= 50, "username", "username must be between 3 and 50 characters")
 	}
 
 	if req.Email == "" {
-		return fmt.Errorf("email is required")
-	}
-	if !utils.IsValidEmail(req.Email) {
-		return fmt.Errorf("invalid email format")
+		v.add("email", "email is required")
+	} else {
+		v.check(utils.IsValidEmail(req.Email), "email", "invalid email format")
 	}
 
 	if req.Password == "" {
-		return fmt.Errorf("password is required")
-	}
-	if len(req.Password) < 6 {
-		return fmt.Errorf("password must be at least 6 characters")
+		v.add("password", "password is required")
+	} else {
+		v.check(len(req.Password) >= 6, "password", "password must be at least 6 characters")
 	}
 
 	if req.FullName == "" {
-		return fmt.Errorf("full name is required")
+		v.add("full_name", "full name is required")
+	} else {
+		v.check(len(req.FullName) >= 2 && len(req.FullName) <= 100, "full_name", "full name must be between 2 and 100 characters")
 	}
-	if len(req.FullName) < 2 || len(req.FullName) > 100 {
-		return fmt.Errorf("full name must be between 2 and 100 characters")
+
+	return v.err()
+}
+
+func (s *userService) validateUpdateProfileRequest(req *model.UpdateProfileRequest, fields []string) error {
+	var v violations
+
+	for _, field := range fields {
+		switch field {
+		case "username":
+			v.check(len(req.Username) >= 3 && len(req.Username) <= 50, "username", "username must be between 3 and 50 characters")
+		case "email":
+			v.check(utils.IsValidEmail(req.Email), "email", "invalid email format")
+		case "full_name":
+			v.check(len(req.FullName) >= 2 && len(req.FullName) <= 100, "full_name", "full name must be between 2 and 100 characters")
+		}
 	}
 
-	return nil
+	return v.err()
 }
 
 func (s *userService) validateLoginRequest(req *model.LoginRequest) error {
+	var v violations
+
 	if req.Email == "" {
-		return fmt.Errorf("email is required")
-	}
-	if !utils.IsValidEmail(req.Email) {
-		return fmt.Errorf("invalid email format")
+		v.add("email", "email is required")
+	} else {
+		v.check(utils.IsValidEmail(req.Email), "email", "invalid email format")
 	}
 
-	if req.Password == "" {
-		return fmt.Errorf("password is required")
-	}
+	v.check(req.Password != "", "password", "password is required")
 
-	return nil
+	return v.err()
 }
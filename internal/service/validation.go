@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+)
+
+// violations collects field errors so a request is rejected with all of them at once
+type violations []apperror.FieldViolation
+
+// add records a violation of field
+func (v *violations) add(field, description string) {
+	*v = append(*v, apperror.FieldViolation{Field: field, Description: description})
+}
+
+// check records a violation of field unless ok holds
+func (v *violations) check(ok bool, field, description string) {
+	if !ok {
+		v.add(field, description)
+	}
+}
+
+// err returns a Validation error, or nil when nothing was recorded
+func (v violations) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return apperror.Validation(v...)
+}
+
+// addPrefixed records the violations carried by err under prefix, so the
+// fields of a batch item read as e.g. "products[3].name"
+func (v *violations) addPrefixed(prefix string, err error) {
+	appErr, ok := apperror.As(err)
+	if !ok {
+		return
+	}
+	for _, fv := range appErr.Violations {
+		v.add(prefix+fv.Field, fv.Description)
+	}
+}
+
+// normalizeCurrency upper-cases a currency code, defaulting an empty one
+func normalizeCurrency(m *model.Money) {
+	m.Currency = strings.ToUpper(strings.TrimSpace(m.Currency))
+	if m.Currency == "" {
+		m.Currency = model.DefaultCurrency
+	}
+}
+
+// checkCurrency records a violation unless m carries an ISO 4217 code
+func (v *violations) checkCurrency(field string, m model.Money) {
+	v.check(model.IsValidCurrency(m.Currency), field+".currency_code", "currency_code must be a three-letter ISO 4217 code")
+}
+
+// resolveUpdateMask checks update_mask paths against the fields a request may
+// change and returns the fields to write, in updatable order. An empty mask
+// means every updatable field, so clients that predate masks replace all.
+func resolveUpdateMask(paths []string, updatable []string) ([]string, error) {
+	if len(paths) == 0 {
+		return updatable, nil
+	}
+
+	var v violations
+	requested := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		v.check(slices.Contains(updatable, p), "update_mask", fmt.Sprintf("field %q cannot be updated", p))
+		requested[p] = true
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(requested))
+	for _, f := range updatable {
+		if requested[f] {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/pkg/utils"
+)
+
+// WebhookService defines business logic for seller webhooks
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, caller model.Caller, req *model.CreateWebhookRequest) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context, caller model.Caller) ([]*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, caller model.Caller, id int64) error
+	ListDeliveries(ctx context.Context, caller model.Caller, req *model.ListDeliveriesRequest) (*model.DeliveryPage, error)
+	GetDelivery(ctx context.Context, caller model.Caller, id int64) (*model.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, caller model.Caller, id int64) (*model.WebhookDelivery, error)
+	// Publish queues event for every webhook it matches; the outbox relay
+	// calls it, so it doubles as a publisher.Publisher
+	Publish(ctx context.Context, event *model.OutboxEvent) error
+}
+
+const (
+	maxWebhooksPerUser = 10
+	minSecretLength    = 16
+	maxSecretLength    = 255
+)
+
+// ErrDeliveryPending is returned when replaying a delivery that is still being retried
+var ErrDeliveryPending = apperror.Conflict("DELIVERY_PENDING", "delivery is still pending")
+
+// WebhookConfig controls which endpoints webhooks may be registered for
+type WebhookConfig struct {
+	// AllowPrivateNetworks accepts loopback, private and link-local
+	// endpoints; only for local development
+	AllowPrivateNetworks bool
+}
+
+type webhookService struct {
+	webhooks   repository.WebhookRepository
+	deliveries repository.WebhookDeliveryRepository
+	products   repository.ProductRepository
+	cfg        WebhookConfig
+}
+
+// NewWebhookService creates a new instance of WebhookService
+func NewWebhookService(webhooks repository.WebhookRepository, deliveries repository.WebhookDeliveryRepository, products repository.ProductRepository, cfg WebhookConfig) WebhookService {
+	return &webhookService{webhooks: webhooks, deliveries: deliveries, products: products, cfg: cfg}
+}
+
+// CreateWebhook registers an endpoint for the caller's product events. The
+// returned webhook carries its secret; later reads do not.
+func (s *webhookService) CreateWebhook(ctx context.Context, caller model.Caller, req *model.CreateWebhookRequest) (*model.Webhook, error) {
+	if caller.UserID == 0 {
+		return nil, ErrUserRequired
+	}
+	webhook := &model.Webhook{
+		UserID:     caller.UserID,
+		URL:        strings.TrimSpace(req.URL),
+		Secret:     req.Secret,
+		EventTypes: compactStrings(req.EventTypes),
+		ProductIDs: compactIDs(req.ProductIDs),
+	}
+	if err := s.validateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.webhooks.ListByUserID(ctx, caller.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhooksPerUser {
+		return nil, apperror.Conflict("WEBHOOK_LIMIT", fmt.Sprintf("at most %d webhooks per user", maxWebhooksPerUser))
+	}
+
+	if webhook.Secret == "" {
+		secret, err := utils.GenerateRandomString(32)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Secret = "whsec_" + secret
+	}
+
+	if err := s.webhooks.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// ListWebhooks lists the caller's webhooks
+func (s *webhookService) ListWebhooks(ctx context.Context, caller model.Caller) ([]*model.Webhook, error) {
+	return s.webhooks.ListByUserID(ctx, caller.UserID)
+}
+
+// DeleteWebhook removes a webhook along with its deliveries
+func (s *webhookService) DeleteWebhook(ctx context.Context, caller model.Caller, id int64) error {
+	if _, err := s.getOwnWebhook(ctx, caller, id); err != nil {
+		return err
+	}
+	return s.webhooks.Delete(ctx, id)
+}
+
+// ListDeliveries retrieves a page of a webhook's deliveries, newest first
+func (s *webhookService) ListDeliveries(ctx context.Context, caller model.Caller, req *model.ListDeliveriesRequest) (*model.DeliveryPage, error) {
+	var v violations
+	v.check(req.PageSize >= 0 && req.PageSize <= maxPageSize, "page_size", fmt.Sprintf("page_size must be between 0 and %d", maxPageSize))
+	v.check(req.Status == "" || slices.Contains([]string{model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead}, req.Status),
+		"status", "status must be pending, delivered or dead")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPageSize
+	}
+
+	if _, err := s.getOwnWebhook(ctx, caller, req.WebhookID); err != nil {
+		return nil, err
+	}
+	return s.deliveries.ListByWebhookID(ctx, req)
+}
+
+// GetDelivery retrieves a delivery with the log of its attempts
+func (s *webhookService) GetDelivery(ctx context.Context, caller model.Caller, id int64) (*model.WebhookDelivery, error) {
+	d, err := s.getOwnDelivery(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.AttemptLog, err = s.deliveries.ListAttempts(ctx, d.ID); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ReplayDelivery sends a delivered or dead delivery again, with a fresh
+// round of retries
+func (s *webhookService) ReplayDelivery(ctx context.Context, caller model.Caller, id int64) (*model.WebhookDelivery, error) {
+	d, err := s.getOwnDelivery(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status == model.DeliveryPending {
+		return nil, ErrDeliveryPending
+	}
+
+	if err := s.deliveries.Replay(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.deliveries.GetByID(ctx, id)
+}
+
+// Publish fans a product event out to the owner's matching webhooks.
+// Events about other aggregates, or products deleted since, are dropped.
+func (s *webhookService) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	if event.AggregateType != model.AggregateProduct || !slices.Contains(model.WebhookEventTypes, event.Type) {
+		return nil
+	}
+
+	product, err := s.products.GetByID(ctx, event.AggregateID)
+	if apperror.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := s.webhooks.ListMatching(ctx, product.UserID, event.Type, event.AggregateID)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	deliveries := make([]*model.WebhookDelivery, 0, len(webhooks))
+	for _, w := range webhooks {
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			WebhookID: w.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Payload:   body,
+		})
+	}
+	return s.deliveries.Enqueue(ctx, deliveries...)
+}
+
+// getOwnWebhook loads a webhook the caller may manage
+func (s *webhookService) getOwnWebhook(ctx context.Context, caller model.Caller, id int64) (*model.Webhook, error) {
+	if id <= 0 {
+		return nil, apperror.Invalid("id", "id is required")
+	}
+
+	webhook, err := s.webhooks.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.UserID != caller.UserID && !caller.IsAdmin() {
+		return nil, ErrPermissionDenied
+	}
+	return webhook, nil
+}
+
+// getOwnDelivery loads a delivery of a webhook the caller may manage
+func (s *webhookService) getOwnDelivery(ctx context.Context, caller model.Caller, id int64) (*model.WebhookDelivery, error) {
+	if id <= 0 {
+		return nil, apperror.Invalid("id", "id is required")
+	}
+
+	d, err := s.deliveries.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getOwnWebhook(ctx, caller, d.WebhookID); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// validateWebhook checks a webhook about to be registered
+func (s *webhookService) validateWebhook(ctx context.Context, w *model.Webhook) error {
+	var v violations
+
+	u, err := url.Parse(w.URL)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		v.check(s.cfg.AllowPrivateNetworks || publicHost(ctx, u.Hostname()),
+			"url", "url must not point at a loopback, private or link-local address")
+	} else {
+		v.add("url", "url must be an absolute http or https URL")
+	}
+	v.check(w.Secret == "" || (len(w.Secret) >= minSecretLength && len(w.Secret) <= maxSecretLength),
+		"secret", fmt.Sprintf("secret must be between %d and %d characters", minSecretLength, maxSecretLength))
+	for i, t := range w.EventTypes {
+		v.check(slices.Contains(model.WebhookEventTypes, t), fmt.Sprintf("event_types[%d]", i),
+			fmt.Sprintf("event type must be one of %s", strings.Join(model.WebhookEventTypes, ", ")))
+	}
+	for i, id := range w.ProductIDs {
+		v.check(id > 0, fmt.Sprintf("product_ids[%d]", i), "product id must be positive")
+	}
+
+	return v.err()
+}
+
+// publicHost reports whether host is, or resolves only to, public
+// addresses. A name that does not resolve yet is let through; the
+// deliverer checks every address it connects to.
+func publicHost(ctx context.Context, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return utils.IsPublicIP(ip)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return true
+	}
+	for _, addr := range addrs {
+		if !utils.IsPublicIP(addr.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+// compactStrings trims values and drops blanks and duplicates, keeping order
+func compactStrings(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && !slices.Contains(out, value) {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+// compactIDs drops duplicate ids, keeping order
+func compactIDs(ids []int64) []int64 {
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
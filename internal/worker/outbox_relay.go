@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/publisher"
+	"grpc-exmpl/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxRelayConfig controls how the relay polls and retries
+type OutboxRelayConfig struct {
+	Interval   time.Duration
+	BatchSize  int
+	Lease      time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// OutboxRelay publishes outbox events and marks them delivered. A failed
+// event is retried with exponential backoff; later events about the same
+// aggregate wait for it, so consumers see each aggregate's events in order.
+type OutboxRelay struct {
+	outbox    repository.OutboxRepository
+	publisher publisher.Publisher
+	cfg       OutboxRelayConfig
+}
+
+// NewOutboxRelay creates a new OutboxRelay
+func NewOutboxRelay(outbox repository.OutboxRepository, pub publisher.Publisher, cfg OutboxRelayConfig) *OutboxRelay {
+	return &OutboxRelay{outbox: outbox, publisher: pub, cfg: cfg}
+}
+
+// Run relays every interval until ctx is cancelled
+func (w *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	logrus.Infof("Outbox relay started (interval %s)", w.cfg.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Info("Outbox relay stopped")
+			return
+		case <-ticker.C:
+			// Keep going while full batches suggest a backlog
+			for w.Relay(ctx) == w.cfg.BatchSize && ctx.Err() == nil {
+			}
+		}
+	}
+}
+
+// Relay publishes one batch of due events and returns how many it claimed
+func (w *OutboxRelay) Relay(ctx context.Context) int {
+	events, err := w.outbox.Claim(ctx, w.cfg.BatchSize, w.cfg.Lease)
+	if err != nil {
+		logrus.Errorf("Failed to claim outbox events: %v", err)
+		return 0
+	}
+
+	// blocked holds the retry time of aggregates whose earlier event failed
+	blocked := make(map[aggregateKey]time.Time)
+	for _, e := range events {
+		key := aggregateKey{e.AggregateType, e.AggregateID}
+		if at, ok := blocked[key]; ok {
+			w.retry(ctx, e, "waiting for an earlier event of the same "+e.AggregateType, at)
+			continue
+		}
+
+		if err := w.publisher.Publish(ctx, e); err != nil {
+			at := time.Now().Add(backoff(e.Attempts, w.cfg.MinBackoff, w.cfg.MaxBackoff))
+			blocked[key] = at
+			logrus.Warnf("Failed to publish %s event %d (attempt %d): %v", e.Type, e.ID, e.Attempts, err)
+			w.retry(ctx, e, err.Error(), at)
+			continue
+		}
+
+		if err := w.outbox.MarkDelivered(ctx, e.ID); err != nil {
+			// The lease runs out and the event goes again; consumers dedupe by ID
+			logrus.Errorf("Failed to mark outbox event %d delivered: %v", e.ID, err)
+		}
+	}
+	return len(events)
+}
+
+// retry reschedules an event, logging rather than returning failures since
+// an unrecorded retry just waits for the lease to run out
+func (w *OutboxRelay) retry(ctx context.Context, e *model.OutboxEvent, reason string, at time.Time) {
+	if err := w.outbox.Retry(ctx, e.ID, reason, at); err != nil {
+		logrus.Errorf("Failed to reschedule outbox event %d: %v", e.ID, err)
+	}
+}
+
+// backoff returns the delay before retrying after the given number of
+// attempts: first after one, doubling with each further one up to limit
+func backoff(attempts int, first, limit time.Duration) time.Duration {
+	delay := first
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+type aggregateKey struct {
+	typ string
+	id  int64
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// ProductFeedRelay turns product_events notifications into watcher
+// wake-ups and prunes events older than the retention period, after which
+// their resume tokens expire.
+type ProductFeedRelay struct {
+	listener  *pq.Listener
+	feed      *service.ProductFeed
+	events    repository.ProductEventRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewProductFeedRelay creates a new ProductFeedRelay
+func NewProductFeedRelay(listener *pq.Listener, feed *service.ProductFeed, events repository.ProductEventRepository, retention, interval time.Duration) *ProductFeedRelay {
+	return &ProductFeedRelay{listener: listener, feed: feed, events: events, retention: retention, interval: interval}
+}
+
+// Run relays notifications and prunes every interval until ctx is cancelled
+func (w *ProductFeedRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	defer w.listener.Close()
+
+	logrus.Infof("Product feed relay started (retention %s)", w.retention)
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Info("Product feed relay stopped")
+			return
+		case <-w.listener.Notify:
+			// A nil notification follows a reconnect; waking watchers lets
+			// them pick up anything committed while the connection was down
+			w.feed.Notify()
+		case <-ticker.C:
+			w.Prune(ctx)
+		}
+	}
+}
+
+// Prune removes events that happened before now minus the retention period
+func (w *ProductFeedRelay) Prune(ctx context.Context) {
+	pruned, err := w.events.Prune(ctx, time.Now().Add(-w.retention))
+	if err != nil {
+		logrus.Errorf("Failed to prune product events: %v", err)
+	} else if pruned > 0 {
+		logrus.Infof("Pruned %d product events", pruned)
+	}
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"grpc-exmpl/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Purger periodically hard-deletes products and users that have been
+// soft-deleted for longer than the retention period, after which they can
+// no longer be restored.
+type Purger struct {
+	products  repository.ProductRepository
+	users     repository.UserRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewPurger creates a new Purger
+func NewPurger(products repository.ProductRepository, users repository.UserRepository, retention, interval time.Duration) *Purger {
+	return &Purger{products: products, users: users, retention: retention, interval: interval}
+}
+
+// Run purges every interval until ctx is cancelled
+func (w *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logrus.Infof("Purger started (retention %s, interval %s)", w.retention, w.interval)
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Info("Purger stopped")
+			return
+		case <-ticker.C:
+			w.Purge(ctx)
+		}
+	}
+}
+
+// Purge removes everything soft-deleted before now minus the retention period
+func (w *Purger) Purge(ctx context.Context) {
+	cutoff := time.Now().Add(-w.retention)
+
+	// Users first: their products go with them through the foreign key
+	users, err := w.users.Purge(ctx, cutoff)
+	if err != nil {
+		logrus.Errorf("Failed to purge deleted users: %v", err)
+	} else if users > 0 {
+		logrus.Infof("Purged %d deleted users", users)
+	}
+
+	products, err := w.products.Purge(ctx, cutoff)
+	if err != nil {
+		logrus.Errorf("Failed to purge deleted products: %v", err)
+	} else if products > 0 {
+		logrus.Infof("Purged %d deleted products", products)
+	}
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"grpc-exmpl/internal/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReservationSweeper periodically releases expired stock reservations so
+// abandoned holds do not keep stock out of sale.
+type ReservationSweeper struct {
+	products service.ProductService
+	interval time.Duration
+}
+
+// NewReservationSweeper creates a new ReservationSweeper
+func NewReservationSweeper(products service.ProductService, interval time.Duration) *ReservationSweeper {
+	return &ReservationSweeper{products: products, interval: interval}
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (w *ReservationSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logrus.Infof("Reservation sweeper started (interval %s)", w.interval)
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Info("Reservation sweeper stopped")
+			return
+		case <-ticker.C:
+			w.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep releases every reservation that has expired by now
+func (w *ReservationSweeper) Sweep(ctx context.Context) {
+	released, err := w.products.ReleaseExpiredReservations(ctx, time.Now())
+	if err != nil {
+		logrus.Errorf("Failed to release expired reservations: %v", err)
+		return
+	}
+	if released > 0 {
+		logrus.Infof("Released %d expired stock reservations", released)
+	}
+}
@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/pkg/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookDelivererConfig controls how webhook deliveries are sent and retried
+type WebhookDelivererConfig struct {
+	Interval  time.Duration
+	BatchSize int
+	// Lease must outlast Timeout, or a slow endpoint gets a second copy
+	Lease       time.Duration
+	Timeout     time.Duration
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	MaxAttempts int
+	// AllowPrivateNetworks lets deliveries reach loopback, private and
+	// link-local addresses; only for local development
+	AllowPrivateNetworks bool
+}
+
+// WebhookDeliverer POSTs queued deliveries to their webhooks, signed with
+// the webhook's secret, and logs every attempt. A delivery that keeps
+// failing is retried with exponential backoff until MaxAttempts, then left
+// dead for the owner to inspect and replay.
+type WebhookDeliverer struct {
+	deliveries repository.WebhookDeliveryRepository
+	client     *http.Client
+	cfg        WebhookDelivererConfig
+}
+
+// NewWebhookDeliverer creates a new WebhookDeliverer
+func NewWebhookDeliverer(deliveries repository.WebhookDeliveryRepository, cfg WebhookDelivererConfig) *WebhookDeliverer {
+	return &WebhookDeliverer{
+		deliveries: deliveries,
+		client:     newWebhookClient(cfg),
+		cfg:        cfg,
+	}
+}
+
+// newWebhookClient builds the client deliveries are sent with. Unless
+// private networks are allowed it connects only to public addresses, checked
+// after every name resolution, and never through a proxy, which would make
+// the connection on its behalf. Redirects are not followed, so an endpoint
+// cannot bounce a delivery to another host; the 3xx counts as a failure.
+func newWebhookClient(cfg WebhookDelivererConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = utils.PublicDialControl
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run delivers every interval until ctx is cancelled
+func (w *WebhookDeliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	logrus.Infof("Webhook deliverer started (interval %s)", w.cfg.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Info("Webhook deliverer stopped")
+			return
+		case <-ticker.C:
+			for w.Deliver(ctx) == w.cfg.BatchSize && ctx.Err() == nil {
+			}
+		}
+	}
+}
+
+// Deliver sends one batch of due deliveries concurrently and returns how
+// many it claimed
+func (w *WebhookDeliverer) Deliver(ctx context.Context) int {
+	deliveries, err := w.deliveries.Claim(ctx, w.cfg.BatchSize, w.cfg.Lease)
+	if err != nil {
+		logrus.Errorf("Failed to claim webhook deliveries: %v", err)
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, d)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries)
+}
+
+// deliver makes one attempt at d and records its outcome
+func (w *WebhookDeliverer) deliver(ctx context.Context, d *model.WebhookDelivery) {
+	attempt := &model.WebhookAttempt{Attempt: d.Attempts, AttemptedAt: time.Now()}
+	code, err := w.send(ctx, d)
+	attempt.StatusCode = code
+	attempt.Duration = time.Since(attempt.AttemptedAt)
+
+	switch {
+	case err == nil:
+		now := time.Now()
+		d.Status = model.DeliveryDelivered
+		d.DeliveredAt = &now
+		d.NextAttemptAt = now
+	case d.Attempts >= w.cfg.MaxAttempts:
+		attempt.Error = err.Error()
+		d.Status = model.DeliveryDead
+		d.NextAttemptAt = time.Now()
+		logrus.Warnf("Webhook delivery %d is dead after %d attempts: %v", d.ID, d.Attempts, err)
+	default:
+		attempt.Error = err.Error()
+		d.NextAttemptAt = time.Now().Add(backoff(d.Attempts, w.cfg.MinBackoff, w.cfg.MaxBackoff))
+	}
+
+	if err := w.deliveries.RecordAttempt(ctx, d, attempt); err != nil && !apperror.IsNotFound(err) {
+		// The lease runs out and the delivery goes again
+		logrus.Errorf("Failed to record attempt at webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// send POSTs the delivery payload and reports the response status. Any
+// 2xx answer counts as delivered.
+func (w *WebhookDeliverer) send(ctx context.Context, d *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "grpc-exmpl-webhooks/1")
+	req.Header.Set("X-Webhook-ID", strconv.FormatInt(d.WebhookID, 10))
+	req.Header.Set("X-Delivery-ID", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Event-ID", strconv.FormatInt(d.EventID, 10))
+	req.Header.Set("X-Event-Type", d.EventType)
+	req.Header.Set("X-Webhook-Signature", utils.SignWebhook(d.Webhook.Secret, time.Now(), d.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_products_name;
+DROP INDEX IF EXISTS idx_products_user_id;
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
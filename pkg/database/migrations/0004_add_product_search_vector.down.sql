@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_products_name ON products(name);
+DROP INDEX IF EXISTS idx_products_search_vector;
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
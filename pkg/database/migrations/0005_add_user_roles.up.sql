@@ -0,0 +1,2 @@
+-- Every user gets a role list; existing users become plain users.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{user}';
@@ -0,0 +1,9 @@
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_total_amount_whole_cents;
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_unit_price_whole_cents;
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_unit_price_non_negative;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_price_whole_cents;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_price_non_negative;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_currency_code;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_currency_code;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE products DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,14 @@
+-- Prices become exact amounts in a currency; existing rows were all priced in USD.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Currencies are ISO 4217 alphabetic codes.
+ALTER TABLE products ADD CONSTRAINT products_currency_code CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE orders ADD CONSTRAINT orders_currency_code CHECK (currency ~ '^[A-Z]{3}$');
+
+-- Amounts are whole cents and never negative.
+ALTER TABLE products ADD CONSTRAINT products_price_non_negative CHECK (price >= 0);
+ALTER TABLE products ADD CONSTRAINT products_price_whole_cents CHECK (price = round(price, 2));
+ALTER TABLE order_items ADD CONSTRAINT order_items_unit_price_non_negative CHECK (unit_price >= 0);
+ALTER TABLE order_items ADD CONSTRAINT order_items_unit_price_whole_cents CHECK (unit_price = round(unit_price, 2));
+ALTER TABLE orders ADD CONSTRAINT orders_total_amount_whole_cents CHECK (total_amount = round(total_amount, 2));
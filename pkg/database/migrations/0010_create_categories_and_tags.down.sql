@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS product_tags;
+DROP TABLE IF EXISTS tags;
+ALTER TABLE products DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
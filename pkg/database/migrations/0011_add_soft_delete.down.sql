@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_products_deleted_at;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+-- Rows that were only soft-deleted would reappear; remove them for good.
+DELETE FROM products WHERE deleted_at IS NOT NULL;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
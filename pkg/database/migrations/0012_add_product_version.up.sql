@@ -0,0 +1,16 @@
+-- Optimistic concurrency: every write to a product row bumps its version,
+-- so UpdateProduct can refuse edits based on a stale read. A trigger keeps
+-- stock changes from checkouts and reservations in the count too.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_product_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_bump_version ON products;
+CREATE TRIGGER products_bump_version
+    BEFORE UPDATE ON products
+    FOR EACH ROW EXECUTE FUNCTION bump_product_version();
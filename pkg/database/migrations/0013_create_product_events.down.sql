@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS products_record_event ON products;
+DROP FUNCTION IF EXISTS record_product_event();
+DROP TABLE IF EXISTS product_events;
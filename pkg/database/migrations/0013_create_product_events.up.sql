@@ -0,0 +1,47 @@
+-- Change feed behind WatchProducts. Every insert or update of a product
+-- records an event and notifies the product_events channel when its
+-- transaction commits, so watchers wake up without polling. Events are read
+-- in (txid, id) order, which is neither commit nor time order: a txid is
+-- taken at a transaction's first write. Readers therefore stop at the
+-- oldest transaction still running, so one that commits late is never
+-- skipped, and re-poll while events wait behind it; see
+-- ProductEventRepository.
+CREATE TABLE IF NOT EXISTS product_events (
+    id BIGSERIAL PRIMARY KEY,
+    txid BIGINT NOT NULL DEFAULT txid_current(),
+    product_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    event_type VARCHAR(16) NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_events_position ON product_events(txid, id);
+CREATE INDEX IF NOT EXISTS idx_product_events_occurred_at ON product_events(occurred_at);
+
+CREATE OR REPLACE FUNCTION record_product_event() RETURNS trigger AS $$
+DECLARE
+    kind VARCHAR(16);
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        kind := 'created';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        kind := 'deleted';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        -- A restored product reappears to watchers as newly created
+        kind := 'created';
+    ELSIF NEW.deleted_at IS NOT NULL THEN
+        RETURN NULL;
+    ELSE
+        kind := 'updated';
+    END IF;
+
+    INSERT INTO product_events (product_id, user_id, event_type) VALUES (NEW.id, NEW.user_id, kind);
+    PERFORM pg_notify('product_events', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_record_event ON products;
+CREATE TRIGGER products_record_event
+    AFTER INSERT OR UPDATE ON products
+    FOR EACH ROW EXECUTE FUNCTION record_product_event();
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,9 +21,9 @@ type Config struct {
 	MaxLifetime  time.Duration
 }
 
-// NewPostgresConnection creates a new PostgreSQL database connection
-func NewPostgresConnection(config *Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
+// DSN returns the lib/pq connection string for config
+func (config *Config) DSN() string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		config.Host,
 		config.Port,
@@ -32,8 +32,11 @@ func NewPostgresConnection(config *Config) (*sql.DB, error) {
 		config.Database,
 		config.SSLMode,
 	)
+}
 
-	db, err := sql.Open("postgres", dsn)
+// NewPostgresConnection creates a new PostgreSQL database connection
+func NewPostgresConnection(config *Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", config.DSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -52,6 +55,22 @@ func NewPostgresConnection(config *Config) (*sql.DB, error) {
 	return db, nil
 }
 
+// NewListener opens a dedicated connection that LISTENs on channel. The
+// listener reconnects by itself and delivers a nil notification after each
+// reconnect, since notifications sent meanwhile are lost.
+func NewListener(config *Config, channel string) (*pq.Listener, error) {
+	listener := pq.NewListener(config.DSN(), time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logrus.Warnf("Listener on %s: %v", channel, err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+	return listener, nil
+}
+
 // CloseConnection closes the database connection
 func CloseConnection(db *sql.DB) error {
 	if db != nil {
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadDelay lets a rotation that rewrites several files settle before
+// they are read
+const reloadDelay = 100 * time.Millisecond
+
+// TLSFiles names the PEM files a CertReloader serves. ClientCAFile is
+// optional; without it client certificates are not requested.
+type TLSFiles struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// CertReloader serves a server certificate and client CA bundle loaded
+// from PEM files, and picks up new files without a restart. Handshakes
+// already in progress keep the configuration they started with.
+type CertReloader struct {
+	files      TLSFiles
+	clientAuth tls.ClientAuthType
+	current    atomic.Pointer[tls.Config]
+	watcher    *fsnotify.Watcher
+}
+
+// NewCertReloader loads the files once. clientAuth applies only when a
+// client CA bundle is configured.
+func NewCertReloader(files TLSFiles, clientAuth tls.ClientAuthType) (*CertReloader, error) {
+	r := &CertReloader{files: files, clientAuth: clientAuth}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On failure the previous certificate stays
+// in use.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+	}
+	if r.files.ClientCAFile != "" {
+		data, err := os.ReadFile(r.files.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", r.files.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = r.clientAuth
+	}
+
+	r.current.Store(config)
+	return nil
+}
+
+// TLSConfig returns a server configuration that always hands out the most
+// recently loaded certificate and client CAs
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Watch reloads the files whenever they change, until Close is called.
+// The parent directories are watched rather than the files themselves, so
+// files replaced by a rename, as in Kubernetes secret volumes, are seen.
+func (r *CertReloader) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch certificates: %w", err)
+	}
+
+	dirs := make(map[string]bool)
+	for _, file := range []string{r.files.CertFile, r.files.KeyFile, r.files.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	r.watcher = watcher
+	go r.watch(watcher)
+	return nil
+}
+
+func (r *CertReloader) watch(watcher *fsnotify.Watcher) {
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			timer.Reset(reloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logrus.WithError(err).Warn("Certificate watcher error")
+		case <-timer.C:
+			if err := r.Reload(); err != nil {
+				logrus.WithError(err).Error("Failed to reload TLS certificates, keeping the previous ones")
+				continue
+			}
+			logrus.Info("TLS certificates reloaded")
+		}
+	}
+}
+
+// Close stops watching the files
+func (r *CertReloader) Close() error {
+	if r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
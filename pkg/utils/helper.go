@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,9 +17,12 @@ import (
 
 // JWT Claims
 type JWTClaims struct {
-	UserID   int64  `json:"user_id"`
-	Email    string `json:"email"`
-	Username string `json:"username"`
+	UserID   int64    `json:"user_id"`
+	Email    string   `json:"email"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles,omitempty"`
+	// SessionID identifies the refresh token family the access token belongs to
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -34,22 +42,18 @@ func CheckPasswordHash(password, hash string) bool {
 }
 
 // Generate JWT token
-func GenerateJWT(userID int64, email, username, secretKey string) (string, error) {
-	claims := JWTClaims{
-		UserID:   userID,
-		Email:    email,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "grpc-exmpl",
-			Subject:   fmt.Sprintf("%d", userID),
-		},
+// The registered claims are filled in from ttl and claims.UserID.
+func GenerateJWT(claims JWTClaims, ttl time.Duration, keys *KeySet) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    "grpc-exmpl",
+		Subject:   fmt.Sprintf("%d", claims.UserID),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secretKey))
+	tokenString, err := keys.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -58,13 +62,8 @@ func GenerateJWT(userID int64, email, username, secretKey string) (string, error
 }
 
 // Validate JWT token
-func ValidateJWT(tokenString, secretKey string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
+func ValidateJWT(tokenString string, keys *KeySet) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, keys.keyFunc)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -86,3 +85,52 @@ func GenerateRandomString(length int) (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(bytes)[:length], nil
 }
+
+// Hash an opaque token for storage
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign a webhook body, producing the "t=<unix time>,v1=<hex HMAC-SHA256>"
+// signature header value. The timestamp is part of the signed message so a
+// captured request cannot be replayed later with a fresh time.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + webhookMAC(secret, t, body)
+}
+
+// Verify a webhook signature header, rejecting timestamps more than
+// tolerance away from now
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("malformed signature header")
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(sig), []byte(webhookMAC(secret, t, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func webhookMAC(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
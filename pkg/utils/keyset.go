@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyConfig describes one signing key loaded from PEM files.
+//
+// Active keys sign new tokens and need PrivateKeyFile. Retired keys only
+// verify tokens issued before a rotation, so PublicKeyFile is enough.
+type KeyConfig struct {
+	ID             string
+	PrivateKeyFile string
+	PublicKeyFile  string
+	Active         bool
+}
+
+// signingKey is a single verification key, optionally able to sign.
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeySet holds the key used to sign new tokens and every key still
+// accepted for verification, indexed by key ID.
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// JWK is the public half of a verification key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// NewHMACKeySet creates a key set that signs and verifies with a shared secret
+func NewHMACKeySet(secret string) *KeySet {
+	key := &signingKey{
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+	return &KeySet{active: key, keys: map[string]*signingKey{"": key}}
+}
+
+// LoadKeySet loads RS256 or EdDSA keys from PEM files. Exactly one key must be active.
+func LoadKeySet(configs []KeyConfig) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*signingKey, len(configs))}
+
+	for _, cfg := range configs {
+		if cfg.ID == "" {
+			return nil, fmt.Errorf("key id is required")
+		}
+		if _, dup := ks.keys[cfg.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", cfg.ID)
+		}
+
+		key, err := loadSigningKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key %q: %w", cfg.ID, err)
+		}
+
+		if cfg.Active {
+			if ks.active != nil {
+				return nil, fmt.Errorf("multiple active keys: %q and %q", ks.active.id, cfg.ID)
+			}
+			if key.private == nil {
+				return nil, fmt.Errorf("active key %q has no private key", cfg.ID)
+			}
+			ks.active = key
+		}
+		ks.keys[cfg.ID] = key
+	}
+
+	if ks.active == nil {
+		return nil, fmt.Errorf("no active signing key configured")
+	}
+
+	return ks, nil
+}
+
+// PublicKeys returns the verification keys in JWK form; shared secrets are never exposed
+func (ks *KeySet) PublicKeys() []JWK {
+	var jwks []JWK
+	for _, key := range ks.keys {
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "RSA",
+				Kid: key.id,
+				Alg: key.method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "OKP",
+				Kid: key.id,
+				Alg: key.method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
+
+// sign creates a signed token with the active key, tagging it with its kid
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	if ks.active.id != "" {
+		token.Header["kid"] = ks.active.id
+	}
+	return token.SignedString(ks.active.private)
+}
+
+// keyFunc selects the verification key named by the token's kid header
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+func loadSigningKey(cfg KeyConfig) (*signingKey, error) {
+	key := &signingKey{id: cfg.ID}
+
+	switch {
+	case cfg.PrivateKeyFile != "":
+		priv, err := readPrivateKey(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.private = priv
+		key.public = priv.Public()
+	case cfg.PublicKeyFile != "":
+		pub, err := readPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.public = pub
+	default:
+		return nil, fmt.Errorf("private_key_file or public_key_file is required")
+	}
+
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.public)
+	}
+
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key type %T", path, key)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
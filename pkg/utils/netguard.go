@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// ErrNonPublicAddress is returned when a connection to an internal address is refused
+var ErrNonPublicAddress = errors.New("refusing to connect to a non-public address")
+
+// IsPublicIP reports whether ip may be reached on behalf of a user: it is
+// not loopback, private, link-local, unspecified or multicast
+func IsPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		// 0.0.0.0/8 reaches the local host on Linux
+		return false
+	}
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// PublicDialControl is a net.Dialer Control function that refuses to
+// connect to addresses IsPublicIP rejects. It runs after name resolution,
+// so it also catches host names that resolve, or are rebound, to internal
+// addresses.
+func PublicDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w %s", ErrNonPublicAddress, host)
+	}
+	return nil
+}
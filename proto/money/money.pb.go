@@ -0,0 +1,8 @@
+package money
+
+// Money is an exact amount in a currency, modelled on google.type.Money.
+type Money struct {
+	CurrencyCode string
+	Units        int64
+	Nanos        int32
+}
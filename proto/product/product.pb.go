@@ -3,9 +3,13 @@ package product
 import (
 	"context"
 
+	"grpc-exmpl/proto/money"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 // ProductData represents the product entity.
@@ -13,20 +17,26 @@ type ProductData struct {
 	Id          int64
 	Name        string
 	Description string
-	Price       float64
+	Price       *money.Money
 	Stock       int32
 	UserId      int64
 	CreatedAt   string
 	UpdatedAt   string
+	CategoryId  int64
+	Tags        []string
+	DeletedAt   string
+	Version     int64
 }
 
 // CreateProductRequest parameters.
 type CreateProductRequest struct {
 	Name        string
 	Description string
-	Price       float64
+	Price       *money.Money
 	Stock       int32
 	UserId      int64
+	CategoryId  int64
+	Tags        []string
 }
 
 // CreateProductResponse result.
@@ -50,14 +60,51 @@ type GetProductResponse struct {
 
 // ListProductsRequest query.
 type ListProductsRequest struct {
-	UserId int64
+	UserId         int64
+	PageSize       int32
+	PageToken      string
+	MinPrice       *money.Money
+	MaxPrice       *money.Money
+	MinStock       *int32
+	MaxStock       *int32
+	NameContains   string
+	OrderBy        string
+	CategoryId     int64
+	IncludeDeleted bool
 }
 
 // ListProductsResponse result.
 type ListProductsResponse struct {
-	Success  bool
-	Message  string
-	Products []*ProductData
+	Success       bool
+	Message       string
+	Products      []*ProductData
+	NextPageToken string
+	TotalCount    int64
+}
+
+// SearchProductsRequest query.
+type SearchProductsRequest struct {
+	Query     string
+	UserId    int64
+	PageSize  int32
+	PageToken string
+}
+
+// ProductSearchResult is a ranked search match.
+type ProductSearchResult struct {
+	Product              *ProductData
+	Rank                 float64
+	NameHighlight        string
+	DescriptionHighlight string
+}
+
+// SearchProductsResponse result.
+type SearchProductsResponse struct {
+	Success       bool
+	Message       string
+	Results       []*ProductSearchResult
+	NextPageToken string
+	TotalCount    int64
 }
 
 // UpdateProductRequest parameters.
@@ -65,8 +112,12 @@ type UpdateProductRequest struct {
 	Id          int64
 	Name        string
 	Description string
-	Price       float64
+	Price       *money.Money
 	Stock       int32
+	CategoryId  int64
+	Tags        []string
+	Version     int64
+	UpdateMask  *fieldmaskpb.FieldMask
 }
 
 // UpdateProductResponse result.
@@ -87,13 +138,177 @@ type DeleteProductResponse struct {
 	Message string
 }
 
+// RestoreProductRequest parameters.
+type RestoreProductRequest struct {
+	Id int64
+}
+
+// RestoreProductResponse result.
+type RestoreProductResponse struct {
+	Success bool
+	Message string
+	Product *ProductData
+}
+
+// ProductResult is the outcome of one item of a batch call.
+type ProductResult struct {
+	Id      int64
+	Product *ProductData
+	Status  *spb.Status
+}
+
+// BatchCreateProductsRequest parameters.
+type BatchCreateProductsRequest struct {
+	Products     []*CreateProductRequest
+	AllOrNothing bool
+}
+
+// BatchCreateProductsResponse result.
+type BatchCreateProductsResponse struct {
+	Success bool
+	Message string
+	Results []*ProductResult
+}
+
+// BatchGetProductsRequest parameters.
+type BatchGetProductsRequest struct {
+	Ids []int64
+}
+
+// BatchGetProductsResponse result.
+type BatchGetProductsResponse struct {
+	Success bool
+	Message string
+	Results []*ProductResult
+}
+
+// BatchDeleteProductsRequest parameters.
+type BatchDeleteProductsRequest struct {
+	Ids []int64
+}
+
+// BatchDeleteProductsResponse result.
+type BatchDeleteProductsResponse struct {
+	Success bool
+	Message string
+	Results []*ProductResult
+}
+
+// AdjustStockRequest parameters.
+type AdjustStockRequest struct {
+	ProductId int64
+	Delta     int32
+}
+
+// AdjustStockResponse result.
+type AdjustStockResponse struct {
+	Success bool
+	Message string
+	Stock   int32
+}
+
+// StockReservationData represents a hold on product stock.
+type StockReservationData struct {
+	Id        int64
+	ProductId int64
+	UserId    int64
+	Quantity  int32
+	Status    string
+	ExpiresAt string
+	CreatedAt string
+}
+
+// ReserveStockRequest parameters.
+type ReserveStockRequest struct {
+	ProductId  int64
+	Quantity   int32
+	TtlSeconds int32
+}
+
+// ReserveStockResponse result.
+type ReserveStockResponse struct {
+	Success     bool
+	Message     string
+	Reservation *StockReservationData
+}
+
+// ReleaseStockRequest parameters.
+type ReleaseStockRequest struct {
+	ReservationId int64
+}
+
+// ReleaseStockResponse result.
+type ReleaseStockResponse struct {
+	Success bool
+	Message string
+}
+
+// CommitReservationRequest parameters.
+type CommitReservationRequest struct {
+	ReservationId int64
+}
+
+// CommitReservationResponse result.
+type CommitReservationResponse struct {
+	Success bool
+	Message string
+}
+
+// ImportError is one imported item that could not be created.
+type ImportError struct {
+	Index  int64
+	Status *spb.Status
+}
+
+// ImportProductsProgress acknowledges imported items.
+type ImportProductsProgress struct {
+	Received int64
+	Created  int64
+	Failed   int64
+	Errors   []*ImportError
+	Done     bool
+}
+
+// ExportProductsRequest parameters.
+type ExportProductsRequest struct {
+	UserId int64
+}
+
+// WatchProductsRequest parameters.
+type WatchProductsRequest struct {
+	UserId      int64
+	ProductIds  []int64
+	ResumeToken string
+}
+
+// ProductEvent is one change in the product feed.
+type ProductEvent struct {
+	Type        string
+	ProductId   int64
+	Product     *ProductData
+	ResumeToken string
+	OccurredAt  string
+}
+
 // ProductServiceClient is the client API for ProductService.
 type ProductServiceClient interface {
 	CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*CreateProductResponse, error)
 	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*GetProductResponse, error)
 	ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error)
+	SearchProducts(ctx context.Context, in *SearchProductsRequest, opts ...grpc.CallOption) (*SearchProductsResponse, error)
 	UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*UpdateProductResponse, error)
 	DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*DeleteProductResponse, error)
+	RestoreProduct(ctx context.Context, in *RestoreProductRequest, opts ...grpc.CallOption) (*RestoreProductResponse, error)
+	BatchCreateProducts(ctx context.Context, in *BatchCreateProductsRequest, opts ...grpc.CallOption) (*BatchCreateProductsResponse, error)
+	BatchGetProducts(ctx context.Context, in *BatchGetProductsRequest, opts ...grpc.CallOption) (*BatchGetProductsResponse, error)
+	BatchDeleteProducts(ctx context.Context, in *BatchDeleteProductsRequest, opts ...grpc.CallOption) (*BatchDeleteProductsResponse, error)
+	AdjustStock(ctx context.Context, in *AdjustStockRequest, opts ...grpc.CallOption) (*AdjustStockResponse, error)
+	ReserveStock(ctx context.Context, in *ReserveStockRequest, opts ...grpc.CallOption) (*ReserveStockResponse, error)
+	ReleaseStock(ctx context.Context, in *ReleaseStockRequest, opts ...grpc.CallOption) (*ReleaseStockResponse, error)
+	CommitReservation(ctx context.Context, in *CommitReservationRequest, opts ...grpc.CallOption) (*CommitReservationResponse, error)
+	ImportProducts(ctx context.Context, opts ...grpc.CallOption) (ProductService_ImportProductsClient, error)
+	ExportProducts(ctx context.Context, in *ExportProductsRequest, opts ...grpc.CallOption) (ProductService_ExportProductsClient, error)
+	WatchProducts(ctx context.Context, in *WatchProductsRequest, opts ...grpc.CallOption) (ProductService_WatchProductsClient, error)
 }
 
 type productServiceClient struct{ cc grpc.ClientConnInterface }
@@ -129,6 +344,15 @@ func (c *productServiceClient) ListProducts(ctx context.Context, in *ListProduct
 	return out, nil
 }
 
+func (c *productServiceClient) SearchProducts(ctx context.Context, in *SearchProductsRequest, opts ...grpc.CallOption) (*SearchProductsResponse, error) {
+	out := new(SearchProductsResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/SearchProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *productServiceClient) UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*UpdateProductResponse, error) {
 	out := new(UpdateProductResponse)
 	err := c.cc.Invoke(ctx, "/product.ProductService/UpdateProduct", in, out, opts...)
@@ -147,13 +371,194 @@ func (c *productServiceClient) DeleteProduct(ctx context.Context, in *DeleteProd
 	return out, nil
 }
 
+func (c *productServiceClient) RestoreProduct(ctx context.Context, in *RestoreProductRequest, opts ...grpc.CallOption) (*RestoreProductResponse, error) {
+	out := new(RestoreProductResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/RestoreProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) BatchCreateProducts(ctx context.Context, in *BatchCreateProductsRequest, opts ...grpc.CallOption) (*BatchCreateProductsResponse, error) {
+	out := new(BatchCreateProductsResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/BatchCreateProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) BatchGetProducts(ctx context.Context, in *BatchGetProductsRequest, opts ...grpc.CallOption) (*BatchGetProductsResponse, error) {
+	out := new(BatchGetProductsResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/BatchGetProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) BatchDeleteProducts(ctx context.Context, in *BatchDeleteProductsRequest, opts ...grpc.CallOption) (*BatchDeleteProductsResponse, error) {
+	out := new(BatchDeleteProductsResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/BatchDeleteProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) AdjustStock(ctx context.Context, in *AdjustStockRequest, opts ...grpc.CallOption) (*AdjustStockResponse, error) {
+	out := new(AdjustStockResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/AdjustStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) ReserveStock(ctx context.Context, in *ReserveStockRequest, opts ...grpc.CallOption) (*ReserveStockResponse, error) {
+	out := new(ReserveStockResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/ReserveStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) ReleaseStock(ctx context.Context, in *ReleaseStockRequest, opts ...grpc.CallOption) (*ReleaseStockResponse, error) {
+	out := new(ReleaseStockResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/ReleaseStock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) CommitReservation(ctx context.Context, in *CommitReservationRequest, opts ...grpc.CallOption) (*CommitReservationResponse, error) {
+	out := new(CommitReservationResponse)
+	err := c.cc.Invoke(ctx, "/product.ProductService/CommitReservation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) ImportProducts(ctx context.Context, opts ...grpc.CallOption) (ProductService_ImportProductsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ProductService_ServiceDesc.Streams[0], "/product.ProductService/ImportProducts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &productServiceImportProductsClient{stream}, nil
+}
+
+// ProductService_ImportProductsClient is the client side of the ImportProducts stream.
+type ProductService_ImportProductsClient interface {
+	Send(*ProductData) error
+	Recv() (*ImportProductsProgress, error)
+	grpc.ClientStream
+}
+
+type productServiceImportProductsClient struct {
+	grpc.ClientStream
+}
+
+func (x *productServiceImportProductsClient) Send(m *ProductData) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *productServiceImportProductsClient) Recv() (*ImportProductsProgress, error) {
+	m := new(ImportProductsProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *productServiceClient) ExportProducts(ctx context.Context, in *ExportProductsRequest, opts ...grpc.CallOption) (ProductService_ExportProductsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ProductService_ServiceDesc.Streams[1], "/product.ProductService/ExportProducts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &productServiceExportProductsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// ProductService_ExportProductsClient is the client side of the ExportProducts stream.
+type ProductService_ExportProductsClient interface {
+	Recv() (*ProductData, error)
+	grpc.ClientStream
+}
+
+type productServiceExportProductsClient struct {
+	grpc.ClientStream
+}
+
+func (x *productServiceExportProductsClient) Recv() (*ProductData, error) {
+	m := new(ProductData)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *productServiceClient) WatchProducts(ctx context.Context, in *WatchProductsRequest, opts ...grpc.CallOption) (ProductService_WatchProductsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ProductService_ServiceDesc.Streams[2], "/product.ProductService/WatchProducts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &productServiceWatchProductsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// ProductService_WatchProductsClient is the client side of the WatchProducts stream.
+type ProductService_WatchProductsClient interface {
+	Recv() (*ProductEvent, error)
+	grpc.ClientStream
+}
+
+type productServiceWatchProductsClient struct {
+	grpc.ClientStream
+}
+
+func (x *productServiceWatchProductsClient) Recv() (*ProductEvent, error) {
+	m := new(ProductEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ProductServiceServer defines the server API for ProductService service.
 type ProductServiceServer interface {
 	CreateProduct(context.Context, *CreateProductRequest) (*CreateProductResponse, error)
 	GetProduct(context.Context, *GetProductRequest) (*GetProductResponse, error)
 	ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error)
+	SearchProducts(context.Context, *SearchProductsRequest) (*SearchProductsResponse, error)
 	UpdateProduct(context.Context, *UpdateProductRequest) (*UpdateProductResponse, error)
 	DeleteProduct(context.Context, *DeleteProductRequest) (*DeleteProductResponse, error)
+	RestoreProduct(context.Context, *RestoreProductRequest) (*RestoreProductResponse, error)
+	BatchCreateProducts(context.Context, *BatchCreateProductsRequest) (*BatchCreateProductsResponse, error)
+	BatchGetProducts(context.Context, *BatchGetProductsRequest) (*BatchGetProductsResponse, error)
+	BatchDeleteProducts(context.Context, *BatchDeleteProductsRequest) (*BatchDeleteProductsResponse, error)
+	AdjustStock(context.Context, *AdjustStockRequest) (*AdjustStockResponse, error)
+	ReserveStock(context.Context, *ReserveStockRequest) (*ReserveStockResponse, error)
+	ReleaseStock(context.Context, *ReleaseStockRequest) (*ReleaseStockResponse, error)
+	CommitReservation(context.Context, *CommitReservationRequest) (*CommitReservationResponse, error)
+	ImportProducts(ProductService_ImportProductsServer) error
+	ExportProducts(*ExportProductsRequest, ProductService_ExportProductsServer) error
+	WatchProducts(*WatchProductsRequest, ProductService_WatchProductsServer) error
 }
 
 // UnimplementedProductServiceServer can be embedded for forward compatible implementations.
@@ -168,12 +573,49 @@ func (UnimplementedProductServiceServer) GetProduct(context.Context, *GetProduct
 func (UnimplementedProductServiceServer) ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListProducts not implemented")
 }
+func (UnimplementedProductServiceServer) SearchProducts(context.Context, *SearchProductsRequest) (*SearchProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchProducts not implemented")
+}
 func (UnimplementedProductServiceServer) UpdateProduct(context.Context, *UpdateProductRequest) (*UpdateProductResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateProduct not implemented")
 }
 func (UnimplementedProductServiceServer) DeleteProduct(context.Context, *DeleteProductRequest) (*DeleteProductResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteProduct not implemented")
 }
+func (UnimplementedProductServiceServer) RestoreProduct(context.Context, *RestoreProductRequest) (*RestoreProductResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreProduct not implemented")
+}
+func (UnimplementedProductServiceServer) BatchCreateProducts(context.Context, *BatchCreateProductsRequest) (*BatchCreateProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchCreateProducts not implemented")
+}
+func (UnimplementedProductServiceServer) BatchGetProducts(context.Context, *BatchGetProductsRequest) (*BatchGetProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetProducts not implemented")
+}
+func (UnimplementedProductServiceServer) BatchDeleteProducts(context.Context, *BatchDeleteProductsRequest) (*BatchDeleteProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchDeleteProducts not implemented")
+}
+func (UnimplementedProductServiceServer) AdjustStock(context.Context, *AdjustStockRequest) (*AdjustStockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdjustStock not implemented")
+}
+func (UnimplementedProductServiceServer) ReserveStock(context.Context, *ReserveStockRequest) (*ReserveStockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveStock not implemented")
+}
+func (UnimplementedProductServiceServer) ReleaseStock(context.Context, *ReleaseStockRequest) (*ReleaseStockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseStock not implemented")
+}
+func (UnimplementedProductServiceServer) CommitReservation(context.Context, *CommitReservationRequest) (*CommitReservationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitReservation not implemented")
+}
+
+func (UnimplementedProductServiceServer) ImportProducts(ProductService_ImportProductsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportProducts not implemented")
+}
+func (UnimplementedProductServiceServer) ExportProducts(*ExportProductsRequest, ProductService_ExportProductsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportProducts not implemented")
+}
+func (UnimplementedProductServiceServer) WatchProducts(*WatchProductsRequest, ProductService_WatchProductsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchProducts not implemented")
+}
 
 func RegisterProductServiceServer(s grpc.ServiceRegistrar, srv ProductServiceServer) {
 	s.RegisterService(&ProductService_ServiceDesc, srv)
@@ -224,6 +666,21 @@ func _ProductService_ListProducts_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProductService_SearchProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).SearchProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/SearchProducts"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).SearchProducts(ctx, req.(*SearchProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ProductService_UpdateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateProductRequest)
 	if err := dec(in); err != nil {
@@ -254,6 +711,197 @@ func _ProductService_DeleteProduct_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProductService_RestoreProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).RestoreProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/RestoreProduct"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).RestoreProduct(ctx, req.(*RestoreProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_BatchCreateProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchCreateProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).BatchCreateProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/BatchCreateProducts"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).BatchCreateProducts(ctx, req.(*BatchCreateProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_BatchGetProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).BatchGetProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/BatchGetProducts"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).BatchGetProducts(ctx, req.(*BatchGetProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_BatchDeleteProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchDeleteProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).BatchDeleteProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/BatchDeleteProducts"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).BatchDeleteProducts(ctx, req.(*BatchDeleteProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_AdjustStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdjustStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).AdjustStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/AdjustStock"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).AdjustStock(ctx, req.(*AdjustStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_ReserveStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).ReserveStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/ReserveStock"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).ReserveStock(ctx, req.(*ReserveStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_ReleaseStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).ReleaseStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/ReleaseStock"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).ReleaseStock(ctx, req.(*ReleaseStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_CommitReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitReservationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).CommitReservation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/product.ProductService/CommitReservation"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).CommitReservation(ctx, req.(*CommitReservationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_ImportProducts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ProductServiceServer).ImportProducts(&productServiceImportProductsServer{stream})
+}
+
+// ProductService_ImportProductsServer is the server side of the ImportProducts stream.
+type ProductService_ImportProductsServer interface {
+	Send(*ImportProductsProgress) error
+	Recv() (*ProductData, error)
+	grpc.ServerStream
+}
+
+type productServiceImportProductsServer struct {
+	grpc.ServerStream
+}
+
+func (x *productServiceImportProductsServer) Send(m *ImportProductsProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *productServiceImportProductsServer) Recv() (*ProductData, error) {
+	m := new(ProductData)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ProductService_ExportProducts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportProductsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProductServiceServer).ExportProducts(m, &productServiceExportProductsServer{stream})
+}
+
+// ProductService_ExportProductsServer is the server side of the ExportProducts stream.
+type ProductService_ExportProductsServer interface {
+	Send(*ProductData) error
+	grpc.ServerStream
+}
+
+type productServiceExportProductsServer struct {
+	grpc.ServerStream
+}
+
+func (x *productServiceExportProductsServer) Send(m *ProductData) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ProductService_WatchProducts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchProductsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProductServiceServer).WatchProducts(m, &productServiceWatchProductsServer{stream})
+}
+
+// ProductService_WatchProductsServer is the server side of the WatchProducts stream.
+type ProductService_WatchProductsServer interface {
+	Send(*ProductEvent) error
+	grpc.ServerStream
+}
+
+type productServiceWatchProductsServer struct {
+	grpc.ServerStream
+}
+
+func (x *productServiceWatchProductsServer) Send(m *ProductEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // ProductService_ServiceDesc describes the ProductService service.
 var ProductService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "product.ProductService",
@@ -262,9 +910,35 @@ var ProductService_ServiceDesc = grpc.ServiceDesc{
 		{MethodName: "CreateProduct", Handler: _ProductService_CreateProduct_Handler},
 		{MethodName: "GetProduct", Handler: _ProductService_GetProduct_Handler},
 		{MethodName: "ListProducts", Handler: _ProductService_ListProducts_Handler},
+		{MethodName: "SearchProducts", Handler: _ProductService_SearchProducts_Handler},
 		{MethodName: "UpdateProduct", Handler: _ProductService_UpdateProduct_Handler},
 		{MethodName: "DeleteProduct", Handler: _ProductService_DeleteProduct_Handler},
+		{MethodName: "RestoreProduct", Handler: _ProductService_RestoreProduct_Handler},
+		{MethodName: "BatchCreateProducts", Handler: _ProductService_BatchCreateProducts_Handler},
+		{MethodName: "BatchGetProducts", Handler: _ProductService_BatchGetProducts_Handler},
+		{MethodName: "BatchDeleteProducts", Handler: _ProductService_BatchDeleteProducts_Handler},
+		{MethodName: "AdjustStock", Handler: _ProductService_AdjustStock_Handler},
+		{MethodName: "ReserveStock", Handler: _ProductService_ReserveStock_Handler},
+		{MethodName: "ReleaseStock", Handler: _ProductService_ReleaseStock_Handler},
+		{MethodName: "CommitReservation", Handler: _ProductService_CommitReservation_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportProducts",
+			Handler:       _ProductService_ImportProducts_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportProducts",
+			Handler:       _ProductService_ExportProducts_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchProducts",
+			Handler:       _ProductService_WatchProducts_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/product/product.proto",
 }
@@ -1,7 +1,7 @@
 #!/usr/bin/env bash
 
-# ./scripts/migrate.sh
-# Build and run the standalone migration command
+# ./scripts/migrate.sh [up | down N | status | goto V | create NAME]
+# Build and run the standalone migration command (defaults to "up")
 
 set -euo pipefail
 
@@ -9,7 +9,7 @@ set -euo pipefail
 go build -o migrate-tool ./cmd/migrate
 
 # Execute migrations
-./migrate-tool
+./migrate-tool "${@:-up}"
 
 # Clean up binary
 rm -f migrate-tool
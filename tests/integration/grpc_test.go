@@ -1,18 +1,29 @@
 package integration
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	apigrpc "grpc-exmpl/api/grpc"
+	"grpc-exmpl/internal/config"
 	"grpc-exmpl/internal/service"
+	"grpc-exmpl/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
 )
 
 func TestGRPCServerStartStop(t *testing.T) {
-	userSvc := service.NewUserService(nil, "secret")
-	prodSvc := service.NewProductService(nil)
+	userSvc := service.NewUserService(nil, nil, nil, nil, service.TokenConfig{Keys: utils.NewHMACKeySet("secret")})
+	prodSvc := service.NewProductService(nil, nil, nil, nil, service.InventoryConfig{}, service.BatchConfig{})
+	watcher := service.NewProductWatcher(nil, nil, service.NewProductFeed(), service.WatchConfig{})
+	orderSvc := service.NewOrderService(nil, nil, nil, nil)
+	categorySvc := service.NewCategoryService(nil)
+	webhookSvc := service.NewWebhookService(nil, nil, nil, service.WebhookConfig{})
 
-	srv := apigrpc.NewServer(userSvc, prodSvc, "0")
+	srv := apigrpc.NewServer(userSvc, prodSvc, watcher, orderSvc, categorySvc, webhookSvc, config.ServerConfig{Port: "0"}, config.AuthConfig{})
 
 	done := make(chan struct{})
 	go func() {
@@ -25,4 +36,57 @@ func TestGRPCServerStartStop(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 	srv.Stop()
 	<-done
+
+	// A second signal during shutdown must not panic
+	srv.Stop()
+}
+
+func TestGRPCServerStopDrainsThenCutsOffStreams(t *testing.T) {
+	userSvc := service.NewUserService(nil, nil, nil, nil, service.TokenConfig{Keys: utils.NewHMACKeySet("secret")})
+	prodSvc := service.NewProductService(nil, nil, nil, nil, service.InventoryConfig{}, service.BatchConfig{})
+	watcher := service.NewProductWatcher(nil, nil, service.NewProductFeed(), service.WatchConfig{})
+	orderSvc := service.NewOrderService(nil, nil, nil, nil)
+	categorySvc := service.NewCategoryService(nil)
+	webhookSvc := service.NewWebhookService(nil, nil, nil, service.WebhookConfig{})
+
+	port := freePort(t)
+	const shutdownTimeout = 300 * time.Millisecond
+	srv := apigrpc.NewServer(userSvc, prodSvc, watcher, orderSvc, categorySvc, webhookSvc,
+		config.ServerConfig{Port: port, ShutdownTimeout: shutdownTimeout},
+		config.AuthConfig{Policies: []config.MethodPolicy{{Method: "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", Access: "public"}}})
+
+	done := make(chan struct{})
+	go func() {
+		if err := srv.Start(); err != nil {
+			t.Errorf("server start: %v", err)
+		}
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient("127.0.0.1:"+port, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// A stream the client never closes keeps GracefulStop waiting
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("reflection: %v", err)
+	}
+	_ = stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("expected the stream to be served, got %v", err)
+	}
+
+	start := time.Now()
+	srv.Stop()
+	<-done
+	if elapsed := time.Since(start); elapsed < shutdownTimeout || elapsed > shutdownTimeout+2*time.Second {
+		t.Fatalf("expected Stop to wait out the shutdown timeout, took %s", elapsed)
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Fatalf("expected the stream to be cut off")
+	}
 }
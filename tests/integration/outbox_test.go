@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/pkg/database"
+)
+
+// testAggregate keeps the events these tests add apart from real ones
+const testAggregate = "integration-test"
+
+// openTestDB connects to the database named by TEST_DATABASE_DSN and
+// migrates it, or skips the test when none is configured
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM outbox WHERE aggregate_type = $1`, testAggregate)
+	})
+	return db
+}
+
+func TestOutboxConcurrentClaimsKeepAggregateOrder(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo := repository.NewOutboxRepository(db)
+
+	for i := range 50 {
+		aggregateID := time.Now().UnixNano() + int64(i)
+		first := &model.OutboxEvent{Type: model.EventProductCreated, AggregateType: testAggregate, AggregateID: aggregateID, Payload: json.RawMessage(`{}`)}
+		second := &model.OutboxEvent{Type: model.EventProductUpdated, AggregateType: testAggregate, AggregateID: aggregateID, Payload: json.RawMessage(`{}`)}
+		if err := repo.Add(ctx, first, second); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+
+		// Two relays claim at once; only the first event may be handed out
+		// until it is delivered, whichever relay gets it
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			claimed []int64
+			start   = make(chan struct{})
+		)
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				events, err := repo.Claim(ctx, 100, time.Minute)
+				if err != nil {
+					t.Errorf("Claim error: %v", err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, e := range events {
+					if e.AggregateType == testAggregate && e.AggregateID == aggregateID {
+						claimed = append(claimed, e.ID)
+					}
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if len(claimed) != 1 || claimed[0] != first.ID {
+			t.Fatalf("iteration %d: expected only event %d to be claimed, got %v", i, first.ID, claimed)
+		}
+
+		if err := repo.MarkDelivered(ctx, first.ID); err != nil {
+			t.Fatalf("MarkDelivered error: %v", err)
+		}
+		events, err := repo.Claim(ctx, 100, time.Minute)
+		if err != nil {
+			t.Fatalf("Claim error: %v", err)
+		}
+		found := false
+		for _, e := range events {
+			found = found || e.ID == second.ID
+		}
+		if !found {
+			t.Fatalf("iteration %d: event %d not claimable after %d was delivered", i, second.ID, first.ID)
+		}
+		if err := repo.MarkDelivered(ctx, second.ID); err != nil {
+			t.Fatalf("MarkDelivered error: %v", err)
+		}
+	}
+}
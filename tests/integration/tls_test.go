@@ -0,0 +1,184 @@
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	apigrpc "grpc-exmpl/api/grpc"
+	"grpc-exmpl/internal/config"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+)
+
+// issueCert creates a certificate for cn signed by ca, or a self-signed CA
+// when ca is nil
+func issueCert(t *testing.T, cn string, ca *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parent, parentKey := tmpl, interface{}(key)
+	if ca == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		parent, parentKey = ca.Leaf, ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate error: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writePEM writes cert and, when keyFile is set, its key
+func writePEM(t *testing.T, cert tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if keyFile == "" {
+		return
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+}
+
+func TestServerAuthenticatesServicesByClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCert(t, "test-ca", nil)
+	serverCert := issueCert(t, "server", &ca)
+	tlsConfig := config.TLSConfig{
+		Enabled:      true,
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   "request",
+	}
+	writePEM(t, ca, tlsConfig.ClientCAFile, "")
+	writePEM(t, serverCert, tlsConfig.CertFile, tlsConfig.KeyFile)
+
+	userSvc := service.NewUserService(nil, nil, nil, nil, service.TokenConfig{Keys: utils.NewHMACKeySet("secret")})
+	prodSvc := service.NewProductService(nil, nil, nil, nil, service.InventoryConfig{}, service.BatchConfig{})
+	watcher := service.NewProductWatcher(nil, nil, service.NewProductFeed(), service.WatchConfig{})
+	orderSvc := service.NewOrderService(nil, nil, nil, nil)
+	categorySvc := service.NewCategoryService(nil)
+	webhookSvc := service.NewWebhookService(nil, nil, nil, service.WebhookConfig{})
+
+	port := freePort(t)
+	srv := apigrpc.NewServer(userSvc, prodSvc, watcher, orderSvc, categorySvc, webhookSvc,
+		config.ServerConfig{Port: port, ShutdownTimeout: time.Second, TLS: tlsConfig},
+		config.AuthConfig{Services: []config.ServiceIdentity{{Name: "orders-worker", Roles: []string{"service"}}}})
+
+	done := make(chan struct{})
+	go func() {
+		if err := srv.Start(); err != nil {
+			t.Errorf("server start: %v", err)
+		}
+		close(done)
+	}()
+	defer func() {
+		srv.Stop()
+		<-done
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	listServices := func(clientCerts ...tls.Certificate) error {
+		creds := credentials.NewTLS(&tls.Config{
+			RootCAs: roots,
+			// Present the certificate even when the server's CA list does
+			// not name its issuer
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if len(clientCerts) == 0 {
+					return &tls.Certificate{}, nil
+				}
+				return &clientCerts[0], nil
+			},
+		})
+		conn, err := grpc.NewClient("127.0.0.1:"+port, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		info, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			return err
+		}
+		_ = info.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+		_, err = info.Recv()
+		return err
+	}
+
+	if err := listServices(issueCert(t, "orders-worker", &ca)); err != nil {
+		t.Fatalf("expected the service certificate to authenticate, got %v", err)
+	}
+	if err := listServices(issueCert(t, "stranger", &ca)); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for an unknown service, got %v", err)
+	}
+	if err := listServices(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without a certificate or token, got %v", err)
+	}
+
+	// Certificates from another CA fail the handshake
+	if err := listServices(issueCert(t, "orders-worker", nil)); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected the handshake to fail for an untrusted certificate, got %v", err)
+	}
+
+	// Browser protocols share the TLS listener, over HTTP/2 and HTTP/1.1,
+	// and the client certificate still authenticates the service
+	worker := issueCert(t, "orders-worker", &ca)
+	for _, forceH2 := range []bool{true, false} {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{worker}}, ForceAttemptHTTP2: forceH2}
+		if !forceH2 {
+			transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		resp, err := (&http.Client{Transport: transport}).Post("https://127.0.0.1:"+port+"/user.UserService/GetPublicKeys", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("Connect call over TLS: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"keys"`) || (resp.ProtoMajor == 2) != forceH2 {
+			t.Fatalf("Connect call over TLS failed: %s %d %s", resp.Proto, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+
+	apigrpc "grpc-exmpl/api/grpc"
+	"grpc-exmpl/internal/config"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+)
+
+// freePort finds a port nothing is listening on
+func freePort(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer lis.Close()
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	return port
+}
+
+func TestServerSharesListenerAcrossProtocols(t *testing.T) {
+	userSvc := service.NewUserService(nil, nil, nil, nil, service.TokenConfig{Keys: utils.NewHMACKeySet("secret")})
+	prodSvc := service.NewProductService(nil, nil, nil, nil, service.InventoryConfig{}, service.BatchConfig{})
+	watcher := service.NewProductWatcher(nil, nil, service.NewProductFeed(), service.WatchConfig{})
+	orderSvc := service.NewOrderService(nil, nil, nil, nil)
+	categorySvc := service.NewCategoryService(nil)
+	webhookSvc := service.NewWebhookService(nil, nil, nil, service.WebhookConfig{})
+
+	port := freePort(t)
+	srv := apigrpc.NewServer(userSvc, prodSvc, watcher, orderSvc, categorySvc, webhookSvc, config.ServerConfig{Port: port, ShutdownTimeout: time.Second},
+		config.AuthConfig{Policies: []config.MethodPolicy{{Method: "/user.UserService/GetPublicKeys", Access: "public"}}})
+
+	done := make(chan struct{})
+	go func() {
+		if err := srv.Start(); err != nil {
+			t.Errorf("server start: %v", err)
+		}
+		close(done)
+	}()
+	defer func() {
+		srv.Stop()
+		<-done
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	addr := "127.0.0.1:" + port
+
+	// Native gRPC over HTTP/2
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	info, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("reflection: %v", err)
+	}
+	_ = info.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+	// Reflection requires a token, so reaching the auth interceptor is
+	// enough to show native gRPC is served
+	if _, err := info.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated over gRPC, got %v", err)
+	}
+
+	// Connect and gRPC-Web over HTTP/1.1 and over HTTP/2 with prior knowledge
+	var h2 http.Protocols
+	h2.SetUnencryptedHTTP2(true)
+	clients := map[string]*http.Client{
+		"HTTP/1.1": http.DefaultClient,
+		"HTTP/2":   {Transport: &http.Transport{Protocols: &h2}},
+	}
+	var client *http.Client
+	var reused bool
+	post := func(contentType string, body []byte) (int, string) {
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused }}
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace),
+			http.MethodPost, "http://"+addr+"/user.UserService/GetPublicKeys", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", contentType, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	for proto, c := range clients {
+		client = c
+		// Twice, to show the connection stays usable after the first request
+		for i := range 2 {
+			if code, body := post("application/json", []byte("{}")); code != http.StatusOK || !strings.Contains(body, `"keys"`) {
+				t.Fatalf("Connect call over %s failed: %d %s", proto, code, body)
+			}
+			if code, body := post("application/grpc-web+json", []byte{0, 0, 0, 0, 2, '{', '}'}); code != http.StatusOK || !strings.Contains(body, "grpc-status: 0") {
+				t.Fatalf("gRPC-Web call over %s failed: %d %q", proto, code, body)
+			}
+			if i > 0 && !reused {
+				t.Fatalf("expected the %s connection to be reused", proto)
+			}
+		}
+	}
+}
@@ -14,6 +14,13 @@ var counter int32
 type Stub struct {
 	QueryFunc func(query string, args []driver.NamedValue) ([][]driver.Value, error)
 	ExecFunc  func(query string, args []driver.NamedValue) (lastInsertID int64, rowsAffected int64, err error)
+
+	// Transaction counters, updated atomically.
+	Begins    int32
+	Commits   int32
+	Rollbacks int32
+	// Isolation is the level requested by the most recent BeginTx.
+	Isolation driver.IsolationLevel
 }
 
 // New creates a new sql.DB backed by a stub driver.
@@ -34,7 +41,28 @@ type conn struct{ stub *Stub }
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) { return nil, fmt.Errorf("not implemented") }
 func (c *conn) Close() error                              { return nil }
-func (c *conn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("not implemented") }
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx accepts any isolation level so callers can request one explicitly.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	atomic.AddInt32(&c.stub.Begins, 1)
+	c.stub.Isolation = opts.Isolation
+	return tx{c.stub}, nil
+}
+
+// tx is a no-op transaction; statements run through the stub as usual.
+type tx struct{ stub *Stub }
+
+func (t tx) Commit() error {
+	atomic.AddInt32(&t.stub.Commits, 1)
+	return nil
+}
+func (t tx) Rollback() error {
+	atomic.AddInt32(&t.stub.Rollbacks, 1)
+	return nil
+}
 
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	if c.stub.QueryFunc == nil {
@@ -57,14 +85,25 @@ var _ driver.Driver = (*Stub)(nil)
 var _ driver.Conn = (*conn)(nil)
 var _ driver.QueryerContext = (*conn)(nil)
 var _ driver.ExecerContext = (*conn)(nil)
+var _ driver.ConnBeginTx = (*conn)(nil)
 
 type rows struct {
 	values [][]driver.Value
 	idx    int
 }
 
-func (r *rows) Columns() []string { return []string{} }
-func (r *rows) Close() error      { return nil }
+func (r *rows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{}
+	}
+	cols := make([]string, len(r.values[0]))
+	for i := range cols {
+		cols[i] = fmt.Sprintf("col%d", i)
+	}
+	return cols
+}
+
+func (r *rows) Close() error { return nil }
 func (r *rows) Next(dest []driver.Value) error {
 	if r.idx >= len(r.values) {
 		return io.EOF
@@ -73,6 +112,7 @@ func (r *rows) Next(dest []driver.Value) error {
 	for i := range row {
 		dest[i] = row[i]
 	}
+	r.idx++
 	return nil
 }
 
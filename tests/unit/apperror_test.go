@@ -0,0 +1,71 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func TestToStatusValidationDetails(t *testing.T) {
+	svc := service.NewProductService(passthroughTx{}, &fakeProductRepo{}, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+	_, err := svc.CreateProduct(context.Background(), &model.CreateProductRequest{Name: " ", Price: model.Money{}, Stock: 1, UserID: 1})
+
+	st := apperror.ToStatus(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", st.Code())
+	}
+
+	var fields []string
+	var reason string
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		case *errdetails.ErrorInfo:
+			reason = d.Reason
+		}
+	}
+	if len(fields) != 2 || fields[0] != "name" || fields[1] != "price" {
+		t.Fatalf("unexpected field violations: %v", fields)
+	}
+	if reason != "INVALID_ARGUMENT" {
+		t.Fatalf("unexpected reason: %q", reason)
+	}
+}
+
+func TestToStatusCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{apperror.NotFound("product"), codes.NotFound},
+		{fmt.Errorf("wrapped: %w", apperror.AlreadyExists("user", "email")), codes.AlreadyExists},
+		{service.ErrPermissionDenied, codes.PermissionDenied},
+		{apperror.Conflict("STALE", "stale"), codes.FailedPrecondition},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		if code := apperror.ToStatus(tt.err).Code(); code != tt.code {
+			t.Errorf("%v: expected %v, got %v", tt.err, tt.code, code)
+		}
+	}
+}
+
+func TestToStatusHidesInternalErrors(t *testing.T) {
+	err := fmt.Errorf("failed to get product: %w", errors.New(`pq: relation "products" does not exist`))
+
+	st := apperror.ToStatus(err)
+	if st.Code() != codes.Internal || st.Message() != "internal error" {
+		t.Fatalf("internal error leaked: %v %q", st.Code(), st.Message())
+	}
+}
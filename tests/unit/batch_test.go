@@ -0,0 +1,211 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/tests/testdb"
+)
+
+// batchProductRepo keeps products in memory and rejects any insert naming
+// badCategory, the way the category foreign key would.
+type batchProductRepo struct {
+	fakeProductRepo
+	products    map[int64]*model.Product
+	nextID      int64
+	badCategory int64
+	inserts     int
+}
+
+func (b *batchProductRepo) CreateBatch(ctx context.Context, ps []*model.Product) error {
+	b.inserts++
+	for _, p := range ps {
+		if p.CategoryID == b.badCategory {
+			return apperror.Invalid("category_id", "category does not exist")
+		}
+	}
+	for _, p := range ps {
+		b.nextID++
+		p.ID = b.nextID
+		b.products[p.ID] = p
+	}
+	return nil
+}
+
+func (b *batchProductRepo) GetByIDs(ctx context.Context, ids []int64) ([]*model.Product, error) {
+	var out []*model.Product
+	for _, id := range ids {
+		if p, ok := b.products[id]; ok {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (b *batchProductRepo) DeleteBatch(ctx context.Context, ids []int64) ([]int64, error) {
+	for _, id := range ids {
+		delete(b.products, id)
+	}
+	return ids, nil
+}
+
+func newBatchRepo() *batchProductRepo {
+	return &batchProductRepo{products: map[int64]*model.Product{}, badCategory: 99}
+}
+
+func TestBatchCreateProductsReportsPerItem(t *testing.T) {
+	ctx := context.Background()
+	repo := newBatchRepo()
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	results, err := svc.BatchCreateProducts(ctx, &model.BatchCreateProductsRequest{Products: []*model.CreateProductRequest{
+		{Name: "a", Price: usd(100), UserID: 1},
+		{Name: "", Price: usd(100), UserID: 1},
+		{Name: "c", Price: usd(100), UserID: 1, CategoryID: 99},
+		{Name: "d", Price: usd(100), UserID: 1, Tags: []string{"Sale"}},
+	}})
+	if err != nil {
+		t.Fatalf("BatchCreateProducts error: %v", err)
+	}
+
+	if results[0].Err != nil || results[0].ID == 0 || results[3].Err != nil || results[3].ID == 0 {
+		t.Fatalf("valid items were not created: %+v %+v", results[0], results[3])
+	}
+	if apperror.KindOf(results[1].Err) != apperror.KindValidation {
+		t.Fatalf("expected a validation error for the nameless item, got %v", results[1].Err)
+	}
+	if apperror.KindOf(results[2].Err) != apperror.KindValidation || results[2].Product != nil {
+		t.Fatalf("expected the bad category to fail only its item, got %+v", results[2])
+	}
+	if len(repo.products) != 2 {
+		t.Fatalf("expected 2 stored products, got %d", len(repo.products))
+	}
+	if tags := repo.tagged[results[3].ID]; len(tags) != 1 || tags[0] != "sale" {
+		t.Fatalf("tags not written for batch item: %v", repo.tagged)
+	}
+}
+
+func TestBatchCreateProductsAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	repo := newBatchRepo()
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	_, err := svc.BatchCreateProducts(ctx, &model.BatchCreateProductsRequest{
+		AllOrNothing: true,
+		Products: []*model.CreateProductRequest{
+			{Name: "a", Price: usd(100), UserID: 1},
+			{Name: "b", Price: usd(0), UserID: 1},
+		},
+	})
+	appErr, ok := apperror.As(err)
+	if !ok || len(appErr.Violations) != 1 || appErr.Violations[0].Field != "products[1].price" {
+		t.Fatalf("expected a products[1].price violation, got %v", err)
+	}
+	if repo.inserts != 0 || len(repo.products) != 0 {
+		t.Fatal("products were written despite an invalid item")
+	}
+
+	_, err = svc.BatchCreateProducts(ctx, &model.BatchCreateProductsRequest{
+		AllOrNothing: true,
+		Products: []*model.CreateProductRequest{
+			{Name: "a", Price: usd(100), UserID: 1},
+			{Name: "b", Price: usd(100), UserID: 1, CategoryID: 99},
+		},
+	})
+	if apperror.KindOf(err) != apperror.KindValidation || repo.inserts != 1 {
+		t.Fatalf("expected the insert error without per-item retries, got %v after %d inserts", err, repo.inserts)
+	}
+}
+
+func TestBatchSizeLimit(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewProductService(passthroughTx{}, newBatchRepo(), nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{MaxSize: 2})
+
+	if _, err := svc.BatchGetProducts(ctx, []int64{1, 2, 3}); apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected a validation error for an oversized batch, got %v", err)
+	}
+	if _, err := svc.BatchGetProducts(ctx, nil); apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected a validation error for an empty batch, got %v", err)
+	}
+	_, err := svc.BatchGetProducts(ctx, []int64{4, 4})
+	if appErr, ok := apperror.As(err); !ok || appErr.Violations[0].Field != "ids[1]" {
+		t.Fatalf("expected a duplicate id violation, got %v", err)
+	}
+}
+
+func TestBatchGetAndDeleteProducts(t *testing.T) {
+	ctx := context.Background()
+	repo := newBatchRepo()
+	repo.products[1] = &model.Product{ID: 1, Name: "mine", UserID: 2}
+	repo.products[2] = &model.Product{ID: 2, Name: "theirs", UserID: 3}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	got, err := svc.BatchGetProducts(ctx, []int64{2, 5, 1})
+	if err != nil {
+		t.Fatalf("BatchGetProducts error: %v", err)
+	}
+	if got[0].Product.Name != "theirs" || !apperror.IsNotFound(got[1].Err) || got[2].Product.Name != "mine" {
+		t.Fatalf("results not in request order: %+v %+v %+v", got[0], got[1], got[2])
+	}
+
+	deleted, err := svc.BatchDeleteProducts(ctx, model.Caller{UserID: 2}, []int64{1, 2, 5})
+	if err != nil {
+		t.Fatalf("BatchDeleteProducts error: %v", err)
+	}
+	if deleted[0].Err != nil || !errors.Is(deleted[1].Err, service.ErrPermissionDenied) || !apperror.IsNotFound(deleted[2].Err) {
+		t.Fatalf("unexpected delete results: %v, %v, %v", deleted[0].Err, deleted[1].Err, deleted[2].Err)
+	}
+	if _, ok := repo.products[2]; !ok {
+		t.Fatal("another user's product was deleted")
+	}
+}
+
+func TestProductRepositoryBatchQueries(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	now := time.Now()
+	var queries []string
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		queries = append(queries, query)
+		switch {
+		case strings.Contains(query, "INSERT INTO products"):
+			return [][]driver.Value{{int64(10), int64(1)}, {int64(11), int64(1)}}, nil
+		case strings.Contains(query, "product_tags"):
+			return nil, nil
+		default:
+			return [][]driver.Value{{int64(10), "A", "", float64(1), "USD", int64(1), int64(1), int64(0), now, now, int64(1)}}, nil
+		}
+	}
+
+	ps := []*model.Product{{Name: "a", Price: usd(100)}, {Name: "b", Price: usd(200)}}
+	if err := repo.CreateBatch(ctx, ps); err != nil {
+		t.Fatalf("CreateBatch error: %v", err)
+	}
+	if ps[0].ID != 10 || ps[1].ID != 11 {
+		t.Fatalf("ids not assigned in input order: %d, %d", ps[0].ID, ps[1].ID)
+	}
+	if len(queries) != 1 || !strings.Contains(queries[0], "unnest(") || !strings.Contains(queries[0], "ORDER BY ord") {
+		t.Fatalf("expected a single ordered unnest insert, got %v", queries)
+	}
+
+	got, err := repo.GetByIDs(ctx, []int64{10, 12})
+	if err != nil {
+		t.Fatalf("GetByIDs error: %v", err)
+	}
+	if len(got) != 1 || !strings.Contains(queries[1], "id = ANY($1)") || !strings.Contains(queries[1], "deleted_at IS NULL") {
+		t.Fatalf("unexpected lookup %q returning %d products", queries[1], len(got))
+	}
+}
@@ -0,0 +1,152 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/tests/testdb"
+)
+
+type fakeCategoryRepo struct {
+	categories map[int64]*model.Category
+}
+
+func (f *fakeCategoryRepo) Create(ctx context.Context, c *model.Category) error {
+	c.ID = int64(len(f.categories) + 1)
+	f.categories[c.ID] = c
+	return nil
+}
+func (f *fakeCategoryRepo) GetByID(ctx context.Context, id int64) (*model.Category, error) {
+	c, ok := f.categories[id]
+	if !ok {
+		return nil, apperror.NotFound("category")
+	}
+	cp := *c
+	return &cp, nil
+}
+func (f *fakeCategoryRepo) List(ctx context.Context, rootID int64) ([]*model.Category, error) {
+	return nil, nil
+}
+func (f *fakeCategoryRepo) Update(ctx context.Context, c *model.Category) error {
+	f.categories[c.ID] = c
+	return nil
+}
+func (f *fakeCategoryRepo) Delete(ctx context.Context, id int64) error {
+	delete(f.categories, id)
+	return nil
+}
+
+func TestCategoryServiceAdminOnlyAndSlugs(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewCategoryService(&fakeCategoryRepo{categories: map[int64]*model.Category{}})
+	admin := model.Caller{UserID: 1, Roles: []string{model.RoleAdmin}}
+
+	if _, err := svc.CreateCategory(ctx, model.Caller{UserID: 2}, &model.CreateCategoryRequest{Name: "Shoes"}); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied, got %v", err)
+	}
+
+	c, err := svc.CreateCategory(ctx, admin, &model.CreateCategoryRequest{Name: " Running Shoes & Boots "})
+	if err != nil || c.Slug != "running-shoes-boots" || c.Name != "Running Shoes & Boots" {
+		t.Fatalf("unexpected category: %+v (%v)", c, err)
+	}
+
+	_, err = svc.CreateCategory(ctx, admin, &model.CreateCategoryRequest{Name: "Hats", Slug: "Hats!"})
+	if apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected invalid slug to be rejected, got %v", err)
+	}
+
+	_, err = svc.UpdateCategory(ctx, admin, &model.UpdateCategoryRequest{ID: c.ID, Name: "Shoes", ParentID: c.ID})
+	if !errors.Is(err, repository.ErrCategoryCycle) {
+		t.Fatalf("expected ErrCategoryCycle, got %v", err)
+	}
+}
+
+func TestCategoryRepositoryUpdateRefusesCycles(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewCategoryRepository(db)
+
+	stub.ExecFunc = func(query string, args []driver.NamedValue) (int64, int64, error) {
+		if !strings.Contains(query, "WITH RECURSIVE subtree") {
+			t.Fatalf("cycle guard missing from query: %s", query)
+		}
+		return 0, 0, nil
+	}
+	exists := true
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		return [][]driver.Value{{exists}}, nil
+	}
+
+	c := &model.Category{ID: 1, ParentID: 3, Name: "Shoes", Slug: "shoes"}
+	if err := repo.Update(ctx, c); !errors.Is(err, repository.ErrCategoryCycle) {
+		t.Fatalf("expected ErrCategoryCycle, got %v", err)
+	}
+	exists = false
+	if err := repo.Update(ctx, c); !apperror.IsNotFound(err) {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestProductRepositoryListByCategoryIncludesDescendants(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	var listQuery string
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "COUNT(*)") {
+			return [][]driver.Value{{int64(0)}}, nil
+		}
+		listQuery = query
+		return nil, nil
+	}
+
+	req := &model.ListProductsRequest{UserID: 1, PageSize: 10, CategoryID: 5, SortBy: model.ProductSortCreatedAt}
+	if _, err := repo.ListByUserID(ctx, req); err != nil {
+		t.Fatalf("ListByUserID error: %v", err)
+	}
+	if !strings.Contains(listQuery, "WITH RECURSIVE subtree") || !strings.Contains(listQuery, "c.parent_id = s.id") {
+		t.Fatalf("category filter does not walk descendants: %s", listQuery)
+	}
+}
+
+func TestProductServiceNormalizesTags(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	p, err := svc.CreateProduct(ctx, &model.CreateProductRequest{
+		Name:       "Boot",
+		Price:      usd(100),
+		UserID:     1,
+		CategoryID: 2,
+		Tags:       []string{" Sale", "sale", "", "NEW"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProduct error: %v", err)
+	}
+	if tags := repo.tagged[p.ID]; len(tags) != 2 || tags[0] != "sale" || tags[1] != "new" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+	if p.CategoryID != 2 {
+		t.Fatalf("category not assigned: %+v", p)
+	}
+
+	_, err = svc.CreateProduct(ctx, &model.CreateProductRequest{Name: "Boot", Price: usd(100), UserID: 1, Tags: []string{strings.Repeat("x", 65)}})
+	if apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected oversized tag to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/tests/testdb"
+)
+
+func TestUpdateProductWithMaskTouchesOnlyListedFields(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Price: usd(100), Stock: 1, UserID: 2, Version: 1}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	// Name and price are blank, which would fail validation without the mask
+	upd := &model.UpdateProductRequest{ID: 1, Stock: 7, Version: 1, UpdateMask: []string{"stock"}}
+	p, err := svc.UpdateProduct(ctx, model.Caller{UserID: 2}, upd)
+	if err != nil {
+		t.Fatalf("UpdateProduct error: %v", err)
+	}
+	if p.Stock != 7 || p.Name != "old" || p.Price != usd(100) {
+		t.Fatalf("unexpected product after masked update: %+v", p)
+	}
+	if len(repo.updated) != 1 || repo.updated[0] != "stock" {
+		t.Fatalf("expected only stock to be written, got %v", repo.updated)
+	}
+	if repo.tagged != nil {
+		t.Fatalf("tags were rewritten without being in the mask: %v", repo.tagged)
+	}
+}
+
+func TestUpdateProductRejectsUnknownMaskPath(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Price: usd(100), UserID: 2, Version: 1}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	upd := &model.UpdateProductRequest{ID: 1, Version: 1, UpdateMask: []string{"stock", "user_id"}}
+	_, err := svc.UpdateProduct(ctx, model.Caller{UserID: 2}, upd)
+	appErr, ok := apperror.As(err)
+	if !ok || len(appErr.Violations) != 1 || appErr.Violations[0].Field != "update_mask" {
+		t.Fatalf("expected an update_mask violation, got %v", err)
+	}
+}
+
+func TestProductRepositoryUpdateBuildsSetFromMask(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	var query string
+	stub.QueryFunc = func(q string, args []driver.NamedValue) ([][]driver.Value, error) {
+		query = q
+		return [][]driver.Value{{int64(4)}}, nil
+	}
+
+	p := &model.Product{ID: 1, Stock: 3, Price: usd(500), Version: 3}
+	if err := repo.Update(ctx, p, []string{"stock", "price"}); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if !strings.Contains(query, "SET stock = $3, price = $4, currency = $5, updated_at = $6") {
+		t.Fatalf("unexpected SET clause: %s", query)
+	}
+	if strings.Contains(query, "name =") {
+		t.Fatalf("unmasked column written: %s", query)
+	}
+	if p.Version != 4 {
+		t.Fatalf("expected version 4, got %d", p.Version)
+	}
+}
+
+func TestUpdateProfileWithMask(t *testing.T) {
+	ctx := context.Background()
+	users := &fakeUserRepo{users: map[int64]*model.User{
+		1: {ID: 1, Username: "alice", Email: "alice@example.com", FullName: "Alice"},
+	}}
+	svc := service.NewUserService(passthroughTx{}, users, newFakeTokenRepo(), &fakeOutbox{}, service.TokenConfig{})
+	caller := model.Caller{UserID: 1}
+
+	u, err := svc.UpdateProfile(ctx, caller, &model.UpdateProfileRequest{FullName: "Alice Liddell", UpdateMask: []string{"full_name"}})
+	if err != nil {
+		t.Fatalf("UpdateProfile error: %v", err)
+	}
+	if u.FullName != "Alice Liddell" || u.Email != "alice@example.com" || u.Username != "alice" {
+		t.Fatalf("unexpected profile: %+v", u)
+	}
+
+	_, err = svc.UpdateProfile(ctx, caller, &model.UpdateProfileRequest{Email: "not-an-email", UpdateMask: []string{"email"}})
+	if appErr, ok := apperror.As(err); !ok || appErr.Violations[0].Field != "email" {
+		t.Fatalf("expected an email violation, got %v", err)
+	}
+
+	_, err = svc.UpdateProfile(ctx, caller, &model.UpdateProfileRequest{UpdateMask: []string{"password"}})
+	if appErr, ok := apperror.As(err); !ok || appErr.Violations[0].Field != "update_mask" {
+		t.Fatalf("expected an update_mask violation, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-exmpl/api/gateway"
+	"grpc-exmpl/internal/config"
+	handler "grpc-exmpl/internal/handler/grpc"
+	"grpc-exmpl/internal/middleware"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/pkg/utils"
+	pbproduct "grpc-exmpl/proto/product"
+	pbuser "grpc-exmpl/proto/user"
+
+	"google.golang.org/grpc/codes"
+)
+
+// newTestAuth builds the real auth interceptor, with every method
+// authenticated, and returns a valid token for user 1
+func newTestAuth(t *testing.T) (*middleware.AuthMiddleware, service.UserService, string) {
+	t.Helper()
+	policies, err := middleware.NewPolicyTable(config.AuthConfig{DefaultAccess: "authenticated"})
+	if err != nil {
+		t.Fatalf("NewPolicyTable error: %v", err)
+	}
+	tokens := newFakeTokenRepo()
+	tokens.Create(context.Background(), &model.RefreshToken{FamilyID: "session"})
+	keys := utils.NewHMACKeySet("secret")
+	userSvc := service.NewUserService(passthroughTx{}, nil, tokens, &fakeOutbox{}, service.TokenConfig{Keys: keys})
+
+	token, err := utils.GenerateJWT(utils.JWTClaims{UserID: 1, Username: "alice", Roles: []string{"user"}, SessionID: "session"}, time.Minute, keys)
+	if err != nil {
+		t.Fatalf("GenerateJWT error: %v", err)
+	}
+	return middleware.NewAuthMiddleware(userSvc, policies), userSvc, token
+}
+
+// newGatewayFixture serves the product and user routes behind the real
+// auth interceptor
+func newGatewayFixture(t *testing.T, repo *fakeProductRepo) (*httptest.Server, string) {
+	t.Helper()
+	auth, userSvc, token := newTestAuth(t)
+	productSvc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	gw := gateway.New(auth.UnaryInterceptor)
+	if err := gw.Register(&pbproduct.ProductService_ServiceDesc, handler.NewProductHandler(productSvc, nil), gateway.ProductRoutes); err != nil {
+		t.Fatalf("Register products error: %v", err)
+	}
+	if err := gw.Register(&pbuser.UserService_ServiceDesc, handler.NewUserHandler(userSvc), gateway.UserRoutes); err != nil {
+		t.Fatalf("Register users error: %v", err)
+	}
+
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+	return srv, token
+}
+
+func doGateway(t *testing.T, method, url, token, body string) (int, http.Header, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest error: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("response is not a JSON object: %s", data)
+	}
+	return resp.StatusCode, resp.Header, out
+}
+
+func TestGatewayForwardsAuthorization(t *testing.T) {
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "Lamp", UserID: 1, Price: model.Money{Amount: 1250, Currency: "USD"}, Stock: 3, Version: 2}}
+	srv, token := newGatewayFixture(t, repo)
+
+	code, header, body := doGateway(t, http.MethodGet, srv.URL+"/v1/products/1", "", "")
+	if code != http.StatusUnauthorized || body["code"] != float64(codes.Unauthenticated) {
+		t.Fatalf("expected 401 Unauthenticated, got %d %v", code, body)
+	}
+	if header.Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("expected WWW-Authenticate challenge, got %q", header.Get("WWW-Authenticate"))
+	}
+
+	code, header, body = doGateway(t, http.MethodGet, srv.URL+"/v1/products/1", token, "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", code, body)
+	}
+	if header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected content type %q", header.Get("Content-Type"))
+	}
+	product, _ := body["product"].(map[string]interface{})
+	if product["id"] != "1" || product["userId"] != "1" || product["stock"] != float64(3) || product["version"] != "2" {
+		t.Fatalf("unexpected product JSON: %v", product)
+	}
+	if price, _ := product["price"].(map[string]interface{}); price["currencyCode"] != "USD" || price["units"] != "12" || price["nanos"] != float64(500000000) {
+		t.Fatalf("unexpected price JSON: %v", product["price"])
+	}
+	if tags, ok := product["tags"].([]interface{}); !ok || len(tags) != 0 {
+		t.Fatalf("expected empty tags array, got %v", product["tags"])
+	}
+}
+
+func TestGatewayBindsPathQueryAndBody(t *testing.T) {
+	repo := &fakeProductRepo{}
+	srv, token := newGatewayFixture(t, repo)
+
+	code, _, body := doGateway(t, http.MethodGet, srv.URL+"/v1/products?user_id=1&pageSize=5&min_stock=2&min_price.currency_code=USD&min_price.units=3&name_contains=lamp", token, "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", code, body)
+	}
+	listed := repo.listed
+	if listed == nil || listed.UserID != 1 || listed.PageSize != 5 || listed.NameContains != "lamp" {
+		t.Fatalf("query not bound: %+v", listed)
+	}
+	if listed.MinStock == nil || *listed.MinStock != 2 || listed.MaxStock != nil {
+		t.Fatalf("optional stock bounds not bound: %v %v", listed.MinStock, listed.MaxStock)
+	}
+	if listed.MinPrice == nil || listed.MinPrice.Amount != 300 || listed.MinPrice.Currency != "USD" {
+		t.Fatalf("nested price bound not bound: %+v", listed.MinPrice)
+	}
+
+	code, _, body = doGateway(t, http.MethodPost, srv.URL+"/v1/products", token, `{"name":"Desk","stock":"4","price":{"currency_code":"USD","units":"20"},"tags":["Wood"]}`)
+	if code != http.StatusOK || body["success"] != true {
+		t.Fatalf("expected created product, got %d %v", code, body)
+	}
+	if c := repo.created; c == nil || c.Name != "Desk" || c.Stock != 4 || c.Price.Amount != 2000 || c.UserID != 1 {
+		t.Fatalf("body not bound: %+v", c)
+	}
+}
+
+func TestGatewayMapsErrors(t *testing.T) {
+	srv, token := newGatewayFixture(t, &fakeProductRepo{})
+
+	code, _, body := doGateway(t, http.MethodPost, srv.URL+"/v1/products", token, `{"name":""}`)
+	if code != http.StatusBadRequest || body["code"] != float64(codes.InvalidArgument) {
+		t.Fatalf("expected 400 InvalidArgument, got %d %v", code, body)
+	}
+	if details, _ := body["details"].([]interface{}); len(details) == 0 {
+		t.Fatalf("expected error details, got %v", body)
+	}
+
+	code, _, body = doGateway(t, http.MethodPost, srv.URL+"/v1/products", token, `{"stock":"many"}`)
+	if code != http.StatusBadRequest || !strings.Contains(body["message"].(string), "stock") {
+		t.Fatalf("expected 400 for malformed body, got %d %v", code, body)
+	}
+
+	code, _, body = doGateway(t, http.MethodGet, srv.URL+"/v1/products/abc", token, "")
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed path, got %d %v", code, body)
+	}
+
+	code, _, _ = doGateway(t, http.MethodGet, srv.URL+"/v1/nowhere", token, "")
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+
+	code, _, _ = doGateway(t, http.MethodPut, srv.URL+"/v1/products/1", token, "{}")
+	if code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", code)
+	}
+
+	for c, want := range map[codes.Code]int{
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.FailedPrecondition: http.StatusBadRequest,
+		codes.Internal:           http.StatusInternalServerError,
+	} {
+		if got := gateway.HTTPStatusFromCode(c); got != want {
+			t.Fatalf("HTTPStatusFromCode(%v) = %d, want %d", c, got, want)
+		}
+	}
+}
+
+func TestGatewayRoutesMatchProtoAnnotations(t *testing.T) {
+	rule := regexp.MustCompile(`rpc (\w+)\([^)]*\) returns \([^)]*\) \{\s*option \(google\.api\.http\) = \{\s*(\w+): "([^"]*)"\s*(?:body: "([^"]*)")?`)
+
+	for file, routes := range map[string][]gateway.Route{
+		"../../proto/user/user.proto":       gateway.UserRoutes,
+		"../../proto/product/product.proto": gateway.ProductRoutes,
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ReadFile error: %v", err)
+		}
+
+		var annotated, served []string
+		for _, m := range rule.FindAllStringSubmatch(string(data), -1) {
+			annotated = append(annotated, strings.Join([]string{m[1], strings.ToUpper(m[2]), m[3], m[4]}, " "))
+		}
+		for _, r := range routes {
+			served = append(served, strings.Join([]string{r.RPC, r.Method, r.Pattern, r.Body}, " "))
+		}
+		sort.Strings(annotated)
+		sort.Strings(served)
+
+		if strings.Join(annotated, "\n") != strings.Join(served, "\n") {
+			t.Fatalf("%s annotations and routes differ:\n%s\nvs\n%s", file, strings.Join(annotated, "\n"), strings.Join(served, "\n"))
+		}
+	}
+}
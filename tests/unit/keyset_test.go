@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grpc-exmpl/pkg/utils"
+)
+
+func writePrivateKey(t *testing.T, dir, name string, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return path
+}
+
+func writePublicKey(t *testing.T, dir, name string, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return path
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+
+	oldKeys, err := utils.LoadKeySet([]utils.KeyConfig{
+		{ID: "old", PrivateKeyFile: writePrivateKey(t, dir, "old.pem", rsaKey), Active: true},
+	})
+	if err != nil {
+		t.Fatalf("LoadKeySet error: %v", err)
+	}
+	oldToken, err := utils.GenerateJWT(utils.JWTClaims{UserID: 1}, time.Minute, oldKeys)
+	if err != nil {
+		t.Fatalf("GenerateJWT error: %v", err)
+	}
+
+	// Rotate: new EdDSA key signs, the RSA key is kept for verification only
+	rotated, err := utils.LoadKeySet([]utils.KeyConfig{
+		{ID: "new", PrivateKeyFile: writePrivateKey(t, dir, "new.pem", edPriv), Active: true},
+		{ID: "old", PublicKeyFile: writePublicKey(t, dir, "old.pub.pem", &rsaKey.PublicKey)},
+	})
+	if err != nil {
+		t.Fatalf("LoadKeySet error: %v", err)
+	}
+
+	if claims, err := utils.ValidateJWT(oldToken, rotated); err != nil || claims.UserID != 1 {
+		t.Fatalf("token signed by retired key rejected: %v", err)
+	}
+
+	newToken, err := utils.GenerateJWT(utils.JWTClaims{UserID: 2}, time.Minute, rotated)
+	if err != nil {
+		t.Fatalf("GenerateJWT error: %v", err)
+	}
+	if _, err := utils.ValidateJWT(newToken, oldKeys); err == nil {
+		t.Fatal("expected token with unknown kid to be rejected")
+	}
+
+	jwks := rotated.PublicKeys()
+	if len(jwks) != 2 || jwks[0].Kid != "new" || jwks[0].Alg != "EdDSA" || jwks[1].Kid != "old" || jwks[1].Alg != "RS256" {
+		t.Fatalf("unexpected JWKS: %+v", jwks)
+	}
+	if jwks[0].X != base64.RawURLEncoding.EncodeToString(edPub) {
+		t.Fatalf("unexpected Ed25519 public key: %s", jwks[0].X)
+	}
+
+	if len(utils.NewHMACKeySet("secret").PublicKeys()) != 0 {
+		t.Fatal("shared secret must not be published")
+	}
+
+	if _, err := utils.LoadKeySet([]utils.KeyConfig{
+		{ID: "old", PublicKeyFile: filepath.Join(dir, "old.pub.pem"), Active: true},
+	}); err == nil {
+		t.Fatal("expected error for active key without private key")
+	}
+}
@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/config"
+	"grpc-exmpl/internal/middleware"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthMiddlewarePolicies(t *testing.T) {
+	policies, err := middleware.NewPolicyTable(config.AuthConfig{
+		DefaultAccess: "authenticated",
+		Policies: []config.MethodPolicy{
+			{Method: "/svc/Public", Access: "public"},
+			{Method: "/svc/Admin", Access: "roles", Roles: []string{"admin"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyTable error: %v", err)
+	}
+	tokens := newFakeTokenRepo()
+	tokens.Create(context.Background(), &model.RefreshToken{FamilyID: "session"})
+	userKeys := utils.NewHMACKeySet("secret")
+	userSvc := service.NewUserService(passthroughTx{}, nil, tokens, &fakeOutbox{}, service.TokenConfig{Keys: userKeys})
+	auth := middleware.NewAuthMiddleware(userSvc, policies)
+
+	call := func(method string, roles []string) error {
+		ctx := context.Background()
+		if roles != nil {
+			claims := utils.JWTClaims{UserID: 1, Username: "alice", Roles: roles, SessionID: "session"}
+			token, err := utils.GenerateJWT(claims, time.Minute, userKeys)
+			if err != nil {
+				t.Fatalf("GenerateJWT error: %v", err)
+			}
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		}
+		_, err := auth.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		return err
+	}
+
+	if err := call("/svc/Public", nil); err != nil {
+		t.Fatalf("public method rejected: %v", err)
+	}
+	if code := status.Code(call("/svc/Other", nil)); code != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", code)
+	}
+	if err := call("/svc/Other", []string{"user"}); err != nil {
+		t.Fatalf("authenticated method rejected: %v", err)
+	}
+	if code := status.Code(call("/svc/Admin", []string{"user"})); code != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", code)
+	}
+	if err := call("/svc/Admin", []string{"admin"}); err != nil {
+		t.Fatalf("admin method rejected: %v", err)
+	}
+
+	if _, err := middleware.NewPolicyTable(config.AuthConfig{
+		Policies: []config.MethodPolicy{{Method: "/svc/Admin", Access: "roles"}},
+	}); err == nil {
+		t.Fatal("expected error for role policy without roles")
+	}
+}
+
+func TestDeadlineMiddleware(t *testing.T) {
+	deadlines := middleware.NewDeadlineMiddleware(time.Minute, []config.MethodTimeout{
+		{Method: "/svc/Fast", Timeout: time.Second},
+	})
+
+	remaining := func(ctx context.Context, method string) time.Duration {
+		var left time.Duration
+		_, err := deadlines.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				deadline, ok := ctx.Deadline()
+				if !ok {
+					t.Fatalf("%s: no deadline applied", method)
+				}
+				left = time.Until(deadline)
+				return nil, nil
+			})
+		if err != nil {
+			t.Fatalf("interceptor error: %v", err)
+		}
+		return left
+	}
+
+	if left := remaining(context.Background(), "/svc/Other"); left <= time.Second || left > time.Minute {
+		t.Fatalf("expected default deadline, got %v", left)
+	}
+	if left := remaining(context.Background(), "/svc/Fast"); left > time.Second {
+		t.Fatalf("expected per-method deadline, got %v", left)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if left := remaining(ctx, "/svc/Fast"); left <= time.Minute {
+		t.Fatalf("client deadline overridden, got %v", left)
+	}
+}
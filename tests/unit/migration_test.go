@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"grpc-exmpl/pkg/database"
+	"grpc-exmpl/tests/testdb"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"m/0002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"m/0001_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"m/0001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+
+	migrations, err := database.LoadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatalf("LoadMigrations error: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "b" {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Fatalf("unexpected checksums: %q %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+
+	delete(fsys, "m/0002_b.down.sql")
+	if _, err := database.LoadMigrations(fsys, "m"); err == nil {
+		t.Fatal("expected error for migration without down file")
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := database.CreateMigration(dir, "Create Widgets"); err != nil {
+		t.Fatalf("CreateMigration error: %v", err)
+	}
+	paths, err := database.CreateMigration(dir, "add-widget-color")
+	if err != nil {
+		t.Fatalf("CreateMigration error: %v", err)
+	}
+	if !strings.HasSuffix(paths[0], "0002_add_widget_color.up.sql") || !strings.HasSuffix(paths[1], "0002_add_widget_color.down.sql") {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+}
+
+func TestMigratorUp(t *testing.T) {
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator error: %v", err)
+	}
+	migrations, err := database.LoadMigrations(os.DirFS("../../pkg/database/migrations"), ".")
+	if err != nil {
+		t.Fatalf("LoadMigrations error: %v", err)
+	}
+
+	// The first migration is already applied
+	applied := []driver.Value{migrations[0].Version, migrations[0].Checksum, time.Now()}
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		return [][]driver.Value{applied}, nil
+	}
+	var execs []string
+	stub.ExecFunc = func(query string, args []driver.NamedValue) (int64, int64, error) {
+		execs = append(execs, strings.TrimSpace(query))
+		return 0, 1, nil
+	}
+
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("Up error: %v", err)
+	}
+	if !strings.HasPrefix(execs[0], "SELECT pg_advisory_lock") || !strings.HasPrefix(execs[len(execs)-1], "SELECT pg_advisory_unlock") {
+		t.Fatalf("migrations not run under advisory lock: %v", execs)
+	}
+	var recorded int
+	for _, q := range execs {
+		if strings.HasPrefix(q, "INSERT INTO schema_migrations") {
+			recorded++
+		}
+		if q == strings.TrimSpace(migrations[0].Up) {
+			t.Fatal("applied migration was run again")
+		}
+	}
+	if recorded != len(migrations)-1 {
+		t.Fatalf("expected %d recorded migrations, got %d", len(migrations)-1, recorded)
+	}
+
+	// An applied migration edited afterwards is refused
+	applied[1] = strings.Repeat("0", 64)
+	if err := m.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+)
+
+// usd builds a US dollar amount from cents
+func usd(cents int64) model.Money {
+	return model.Money{Amount: cents, Currency: "USD"}
+}
+
+func TestParseDecimal(t *testing.T) {
+	cases := map[string]int64{
+		"12.34":  1234,
+		"9.9":    990,
+		"10":     1000,
+		"0.05":   5,
+		"3.1000": 310,
+		"-1.50":  -150,
+	}
+	for in, want := range cases {
+		if got, err := model.ParseDecimal(in); err != nil || got != want {
+			t.Fatalf("ParseDecimal(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "1.005", "abc", "1.2.3"} {
+		if _, err := model.ParseDecimal(in); err == nil {
+			t.Fatalf("ParseDecimal(%q) should fail", in)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	if got := usd(1005).Decimal(); got != "10.05" {
+		t.Fatalf("Decimal() = %q", got)
+	}
+	if got := usd(-5).Decimal(); got != "-0.05" {
+		t.Fatalf("Decimal() = %q", got)
+	}
+
+	// 0.1 + 0.2 must be exactly 0.3, which float64 prices could not promise
+	total, err := usd(10).Add(usd(20))
+	if err != nil || total != usd(30) {
+		t.Fatalf("Add() = %v, %v", total, err)
+	}
+	if _, err := usd(10).Add(model.Money{Amount: 10, Currency: "EUR"}); err == nil {
+		t.Fatalf("expected adding different currencies to fail")
+	}
+	if got := usd(333).Times(3); got != usd(999) {
+		t.Fatalf("Times() = %v", got)
+	}
+}
+
+func TestProductServiceValidatesMoney(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	p, err := svc.CreateProduct(ctx, &model.CreateProductRequest{Name: "Book", Price: model.Money{Amount: 250, Currency: "eur"}, UserID: 1})
+	if err != nil || p.Price.Currency != "EUR" {
+		t.Fatalf("expected normalized EUR price, got %+v (%v)", p, err)
+	}
+	p, err = svc.CreateProduct(ctx, &model.CreateProductRequest{Name: "Book", Price: model.Money{Amount: 250}, UserID: 1})
+	if err != nil || p.Price.Currency != model.DefaultCurrency {
+		t.Fatalf("expected default currency, got %+v (%v)", p, err)
+	}
+
+	_, err = svc.CreateProduct(ctx, &model.CreateProductRequest{Name: "Book", Price: model.Money{Amount: -1, Currency: "DOLLARS"}, UserID: 1})
+	appErr, ok := apperror.As(err)
+	if !ok || len(appErr.Violations) != 2 {
+		t.Fatalf("expected price and currency violations, got %v", err)
+	}
+
+	minPrice, maxPrice := usd(100), model.Money{Amount: 500, Currency: "EUR"}
+	_, err = svc.ListProductsByUser(ctx, model.Caller{}, &model.ListProductsRequest{UserID: 1, MinPrice: &minPrice, MaxPrice: &maxPrice})
+	if apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected mixed-currency bounds to be rejected, got %v", err)
+	}
+}
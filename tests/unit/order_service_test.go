@@ -0,0 +1,107 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+)
+
+type fakeOrderRepo struct {
+	orders map[int64]*model.Order
+}
+
+func (f *fakeOrderRepo) Create(ctx context.Context, o *model.Order) error {
+	o.ID = int64(len(f.orders) + 1)
+	o.Status = model.OrderPlaced
+	f.orders[o.ID] = o
+	return nil
+}
+func (f *fakeOrderRepo) GetByID(ctx context.Context, id int64) (*model.Order, error) {
+	o, ok := f.orders[id]
+	if !ok {
+		return nil, apperror.NotFound("order")
+	}
+	return o, nil
+}
+func (f *fakeOrderRepo) ListByUserID(ctx context.Context, req *model.ListOrdersRequest) (*model.OrderPage, error) {
+	page := &model.OrderPage{}
+	for _, o := range f.orders {
+		if o.UserID == req.UserID {
+			page.Orders = append(page.Orders, o)
+		}
+	}
+	return page, nil
+}
+func (f *fakeOrderRepo) Cancel(ctx context.Context, id int64) error {
+	o := f.orders[id]
+	if o.Status != model.OrderPlaced {
+		return repository.ErrOrderNotCancellable
+	}
+	o.Status = model.OrderCancelled
+	return nil
+}
+
+func TestOrderServiceCreateValidation(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewOrderService(passthroughTx{}, &fakeOrderRepo{orders: map[int64]*model.Order{}}, &fakeProductRepo{}, &fakeOutbox{})
+	buyer := model.Caller{UserID: 1}
+
+	_, err := svc.CreateOrder(ctx, buyer, &model.CreateOrderRequest{Items: []model.OrderLine{
+		{ProductID: 1, Quantity: 1},
+		{ProductID: 1, Quantity: 2},
+		{ProductID: 2, Quantity: 0},
+	}})
+	appErr, ok := apperror.As(err)
+	if !ok || appErr.Kind != apperror.KindValidation || len(appErr.Violations) != 2 {
+		t.Fatalf("expected two field violations, got %v", err)
+	}
+
+	if _, err := svc.CreateOrder(ctx, buyer, &model.CreateOrderRequest{}); err == nil {
+		t.Fatalf("expected empty order to be rejected")
+	}
+
+	worker := model.Caller{Service: "orders-worker", Roles: []string{model.RoleAdmin}}
+	_, err = svc.CreateOrder(ctx, worker, &model.CreateOrderRequest{Items: []model.OrderLine{{ProductID: 1, Quantity: 1}}})
+	if !errors.Is(err, service.ErrUserRequired) || apperror.KindOf(err) != apperror.KindPermissionDenied {
+		t.Fatalf("expected ErrUserRequired for a service caller, got %v", err)
+	}
+}
+
+func TestOrderServiceOwnershipAndCancel(t *testing.T) {
+	ctx := context.Background()
+	products := &fakeProductRepo{stored: &model.Product{ID: 3, Name: "item", Price: usd(400), Stock: 5, UserID: 9}}
+	svc := service.NewOrderService(passthroughTx{}, &fakeOrderRepo{orders: map[int64]*model.Order{}}, products, &fakeOutbox{})
+	buyer := model.Caller{UserID: 1}
+
+	order, err := svc.CreateOrder(ctx, buyer, &model.CreateOrderRequest{Items: []model.OrderLine{{ProductID: 3, Quantity: 2}}})
+	if err != nil {
+		t.Fatalf("CreateOrder error: %v", err)
+	}
+	if order.UserID != 1 || order.TotalAmount != usd(800) || products.stored.Stock != 3 {
+		t.Fatalf("unexpected order or stock: %+v, stock %d", order, products.stored.Stock)
+	}
+
+	stranger := model.Caller{UserID: 2}
+	if _, err := svc.GetOrder(ctx, stranger, order.ID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := svc.CancelOrder(ctx, stranger, order.ID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied on cancel, got %v", err)
+	}
+
+	cancelled, err := svc.CancelOrder(ctx, buyer, order.ID)
+	if err != nil || cancelled.Status != model.OrderCancelled {
+		t.Fatalf("expected cancelled order, got %+v (%v)", cancelled, err)
+	}
+	if products.stored.Stock != 5 {
+		t.Fatalf("expected stock returned on cancel, got %d", products.stored.Stock)
+	}
+	if _, err := svc.CancelOrder(ctx, buyer, order.ID); !errors.Is(err, repository.ErrOrderNotCancellable) {
+		t.Fatalf("expected ErrOrderNotCancellable, got %v", err)
+	}
+}
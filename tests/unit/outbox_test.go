@@ -0,0 +1,264 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/publisher"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/internal/worker"
+	"grpc-exmpl/tests/testdb"
+)
+
+func TestProductServiceRecordsEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{}
+	outbox := &fakeOutbox{}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, outbox, service.InventoryConfig{}, service.BatchConfig{})
+
+	p, err := svc.CreateProduct(ctx, &model.CreateProductRequest{Name: "lamp", Price: usd(100), Stock: 3, UserID: 2})
+	if err != nil {
+		t.Fatalf("CreateProduct error: %v", err)
+	}
+	p.Version = 1
+	repo.stored = p
+
+	upd := &model.UpdateProductRequest{ID: p.ID, Stock: 5, Version: p.Version, UpdateMask: []string{"stock"}}
+	if _, err := svc.UpdateProduct(ctx, model.Caller{UserID: 2}, upd); err != nil {
+		t.Fatalf("UpdateProduct error: %v", err)
+	}
+	if _, err := svc.AdjustStock(ctx, model.Caller{UserID: 2}, p.ID, -4); err != nil {
+		t.Fatalf("AdjustStock error: %v", err)
+	}
+
+	want := []string{model.EventProductCreated, model.EventProductUpdated, model.EventStockChanged, model.EventStockChanged}
+	if got := outbox.types(); !slices.Equal(got, want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+
+	var stock model.StockChangedEvent
+	if err := json.Unmarshal(outbox.events[2].Payload, &stock); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if stock.Delta != 2 || stock.Stock != 5 || stock.Reason != model.StockReasonUpdate {
+		t.Fatalf("unexpected stock change from update: %+v", stock)
+	}
+	if err := json.Unmarshal(outbox.events[3].Payload, &stock); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if stock.Delta != -4 || stock.Stock != 1 || stock.Reason != model.StockReasonAdjustment {
+		t.Fatalf("unexpected stock change from adjustment: %+v", stock)
+	}
+}
+
+func TestUpdateProductWithoutStockChangeRecordsOnlyUpdate(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Price: usd(100), Stock: 1, UserID: 2, Version: 1}}
+	outbox := &fakeOutbox{}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, outbox, service.InventoryConfig{}, service.BatchConfig{})
+
+	upd := &model.UpdateProductRequest{ID: 1, Name: "new", Version: 1, UpdateMask: []string{"name"}}
+	if _, err := svc.UpdateProduct(ctx, model.Caller{UserID: 2}, upd); err != nil {
+		t.Fatalf("UpdateProduct error: %v", err)
+	}
+	if got := outbox.types(); !slices.Equal(got, []string{model.EventProductUpdated}) {
+		t.Fatalf("expected a single ProductUpdated, got %v", got)
+	}
+
+	var changed model.ProductChangedEvent
+	if err := json.Unmarshal(outbox.events[0].Payload, &changed); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if changed.Product.Name != "new" || !slices.Equal(changed.Fields, []string{"name"}) {
+		t.Fatalf("unexpected payload: %+v", changed)
+	}
+}
+
+// newUserRepo reports unknown emails and usernames as not found, which
+// Register relies on to tell a free address from a lookup failure
+type newUserRepo struct {
+	fakeUserRepo
+}
+
+func (n *newUserRepo) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return nil, apperror.NotFound("user")
+}
+func (n *newUserRepo) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return nil, apperror.NotFound("user")
+}
+
+func TestRegisterRecordsUserRegistered(t *testing.T) {
+	ctx := context.Background()
+	outbox := &fakeOutbox{}
+	users := &newUserRepo{fakeUserRepo{users: map[int64]*model.User{}}}
+	svc := service.NewUserService(passthroughTx{}, users, newFakeTokenRepo(), outbox, service.TokenConfig{})
+
+	u, err := svc.Register(ctx, &model.RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "secret1", FullName: "Alice"})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if len(outbox.events) != 1 || outbox.events[0].Type != model.EventUserRegistered || outbox.events[0].AggregateID != u.ID {
+		t.Fatalf("expected one UserRegistered event for user %d, got %+v", u.ID, outbox.events)
+	}
+	if bytes.Contains(outbox.events[0].Payload, []byte("password")) {
+		t.Fatalf("password leaked into the event: %s", outbox.events[0].Payload)
+	}
+}
+
+// relayOutbox serves a fixed batch and records how each event was settled
+type relayOutbox struct {
+	fakeOutbox
+	batch     []*model.OutboxEvent
+	delivered []int64
+	retries   map[int64]time.Time
+}
+
+func (r *relayOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxEvent, error) {
+	batch := r.batch
+	r.batch = nil
+	return batch, nil
+}
+func (r *relayOutbox) MarkDelivered(ctx context.Context, id int64) error {
+	r.delivered = append(r.delivered, id)
+	return nil
+}
+func (r *relayOutbox) Retry(ctx context.Context, id int64, lastErr string, at time.Time) error {
+	r.retries[id] = at
+	return nil
+}
+
+type failingPublisher struct {
+	failID    int64
+	published []int64
+}
+
+func (f *failingPublisher) Publish(ctx context.Context, e *model.OutboxEvent) error {
+	if e.ID == f.failID {
+		return errors.New("broker unavailable")
+	}
+	f.published = append(f.published, e.ID)
+	return nil
+}
+
+func TestOutboxRelayKeepsAggregateOrder(t *testing.T) {
+	outbox := &relayOutbox{
+		retries: map[int64]time.Time{},
+		batch: []*model.OutboxEvent{
+			{ID: 1, AggregateType: model.AggregateProduct, AggregateID: 7, Attempts: 3},
+			{ID: 2, AggregateType: model.AggregateProduct, AggregateID: 8, Attempts: 1},
+			{ID: 3, AggregateType: model.AggregateProduct, AggregateID: 7, Attempts: 1},
+		},
+	}
+	pub := &failingPublisher{failID: 1}
+	relay := worker.NewOutboxRelay(outbox, pub, worker.OutboxRelayConfig{
+		BatchSize:  10,
+		MinBackoff: time.Second,
+		MaxBackoff: 3 * time.Second,
+	})
+
+	start := time.Now()
+	if n := relay.Relay(context.Background()); n != 3 {
+		t.Fatalf("expected 3 claimed events, got %d", n)
+	}
+
+	if !slices.Equal(pub.published, []int64{2}) || !slices.Equal(outbox.delivered, []int64{2}) {
+		t.Fatalf("expected only event 2 published and delivered, got %v / %v", pub.published, outbox.delivered)
+	}
+	// Third attempt: 1s doubled twice is 4s, capped at 3s
+	retryAt, ok := outbox.retries[1]
+	if !ok || retryAt.Before(start.Add(3*time.Second)) || retryAt.After(time.Now().Add(3*time.Second)) {
+		t.Fatalf("expected event 1 retried after the capped backoff, got %v", outbox.retries)
+	}
+	if outbox.retries[3] != retryAt {
+		t.Fatalf("event 3 was not held back behind event 1: %v", outbox.retries)
+	}
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var got model.OutboxEvent
+	var header http.Header
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	pub := publisher.NewWebhookPublisher(srv.URL, time.Second)
+	event := &model.OutboxEvent{ID: 42, Type: model.EventStockChanged, AggregateType: model.AggregateProduct, AggregateID: 7, Payload: json.RawMessage(`{"stock":3}`)}
+	if err := pub.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	if header.Get("X-Event-ID") != "42" || header.Get("X-Event-Type") != model.EventStockChanged || header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers: %v", header)
+	}
+	if got.ID != 42 || string(got.Payload) != `{"stock":3}` {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := pub.Publish(context.Background(), event); err == nil {
+		t.Fatal("expected an error for a non-2xx answer")
+	}
+}
+
+func TestWriterPublisherWritesJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	pub := publisher.NewWriterPublisher(&buf)
+	for id := int64(1); id <= 2; id++ {
+		event := &model.OutboxEvent{ID: id, Type: model.EventProductCreated, Payload: json.RawMessage(`{}`)}
+		if err := pub.Publish(context.Background(), event); err != nil {
+			t.Fatalf("Publish error: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"id":2`) || !strings.Contains(lines[1], `"type":"ProductCreated"`) {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestOutboxRepositoryClaimSkipsLockedRows(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewOutboxRepository(db)
+
+	now := time.Now()
+	var query string
+	stub.QueryFunc = func(q string, args []driver.NamedValue) ([][]driver.Value, error) {
+		query = q
+		return [][]driver.Value{
+			{int64(5), model.EventStockChanged, model.AggregateProduct, int64(1), []byte(`{}`), now, int64(1)},
+			{int64(3), model.EventProductCreated, model.AggregateProduct, int64(1), []byte(`{}`), now, int64(1)},
+		}, nil
+	}
+
+	events, err := repo.Claim(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("Claim error: %v", err)
+	}
+	if !strings.Contains(query, "FOR UPDATE SKIP LOCKED") || !strings.Contains(query, "attempts = attempts + 1") {
+		t.Fatalf("claim does not lease rows safely: %s", query)
+	}
+	if len(events) != 2 || events[0].ID != 3 || events[1].ID != 5 {
+		t.Fatalf("claimed events not in id order: %+v", events)
+	}
+}
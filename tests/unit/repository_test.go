@@ -1,16 +1,21 @@
 package unit
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"grpc-exmpl/internal/apperror"
 	"grpc-exmpl/internal/model"
 	"grpc-exmpl/internal/repository"
 	"grpc-exmpl/tests/testdb"
 )
 
 func TestProductRepositoryCreate(t *testing.T) {
+	ctx := context.Background()
 	db, stub, err := testdb.New()
 	if err != nil {
 		t.Fatalf("failed to create stub db: %v", err)
@@ -18,11 +23,11 @@ func TestProductRepositoryCreate(t *testing.T) {
 	repo := repository.NewProductRepository(db)
 
 	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
-		return [][]driver.Value{{int64(1)}}, nil
+		return [][]driver.Value{{int64(1), int64(1)}}, nil
 	}
 
-	p := &model.Product{Name: "Book", Description: "nice", Price: 10, Stock: 2, UserID: 5}
-	if err := repo.Create(p); err != nil {
+	p := &model.Product{Name: "Book", Description: "nice", Price: usd(1000), Stock: 2, UserID: 5}
+	if err := repo.Create(ctx, p); err != nil {
 		t.Fatalf("Create returned error: %v", err)
 	}
 	if p.ID != 1 {
@@ -34,6 +39,7 @@ func TestProductRepositoryCreate(t *testing.T) {
 }
 
 func TestProductRepositoryGetByID(t *testing.T) {
+	ctx := context.Background()
 	db, stub, err := testdb.New()
 	if err != nil {
 		t.Fatalf("failed to create stub db: %v", err)
@@ -42,14 +48,195 @@ func TestProductRepositoryGetByID(t *testing.T) {
 
 	now := time.Now()
 	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
-		return [][]driver.Value{{int64(2), "Item", "desc", float64(9.9), int64(3), int64(1), now, now}}, nil
+		if strings.Contains(query, "product_tags") {
+			return [][]driver.Value{{int64(2), "new"}, {int64(2), "sale"}}, nil
+		}
+		return [][]driver.Value{{int64(2), "Item", "desc", []byte("9.90"), "USD", int64(3), int64(1), int64(4), now, now, int64(1)}}, nil
 	}
 
-	p, err := repo.GetByID(2)
+	p, err := repo.GetByID(ctx, 2)
 	if err != nil {
 		t.Fatalf("GetByID error: %v", err)
 	}
-	if p.Name != "Item" || p.Price != 9.9 {
+	if p.Name != "Item" || p.Price != usd(990) || p.CategoryID != 4 {
 		t.Fatalf("unexpected product: %v", p)
 	}
+	if len(p.Tags) != 2 || p.Tags[1] != "sale" {
+		t.Fatalf("tags not loaded: %v", p.Tags)
+	}
+}
+
+func TestProductRepositoryListByUserIDPagination(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	now := time.Now()
+	var listQuery string
+	var listArgs []driver.NamedValue
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "COUNT(*)") {
+			return [][]driver.Value{{int64(3)}}, nil
+		}
+		if strings.Contains(query, "product_tags") {
+			return nil, nil
+		}
+		listQuery, listArgs = query, args
+		return [][]driver.Value{
+			{int64(3), "C", "", float64(3), "USD", int64(1), int64(1), int64(0), now, now, int64(1), nil},
+			{int64(2), "B", "", float64(2), "USD", int64(1), int64(1), int64(0), now, now, int64(1), nil},
+			{int64(1), "A", "", float64(1), "USD", int64(1), int64(1), int64(0), now, now, int64(1), nil},
+		}, nil
+	}
+
+	req := &model.ListProductsRequest{UserID: 1, PageSize: 2, SortBy: model.ProductSortCreatedAt, SortDesc: true}
+	page, err := repo.ListByUserID(ctx, req)
+	if err != nil {
+		t.Fatalf("ListByUserID error: %v", err)
+	}
+	if len(page.Products) != 2 || page.TotalCount != 3 || page.NextPageToken == "" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	req.PageToken = page.NextPageToken
+	if _, err := repo.ListByUserID(ctx, req); err != nil {
+		t.Fatalf("ListByUserID with token error: %v", err)
+	}
+	if !strings.Contains(listQuery, "(created_at, id) <") {
+		t.Fatalf("keyset condition missing from query: %s", listQuery)
+	}
+	if got := listArgs[len(listArgs)-2].Value; got != int64(2) {
+		t.Fatalf("expected cursor id 2, got %v", got)
+	}
+
+	req.SortBy = model.ProductSortPrice
+	if _, err := repo.ListByUserID(ctx, req); err == nil {
+		t.Fatal("expected error for token issued under a different sort")
+	}
+
+	// Replaying the token for another owner or filter is rejected
+	req.SortBy = model.ProductSortCreatedAt
+	req.UserID = 2
+	if _, err := repo.ListByUserID(ctx, req); apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected InvalidArgument for a token issued to another owner, got %v", err)
+	}
+	req.UserID = 1
+	req.NameContains = "lamp"
+	if _, err := repo.ListByUserID(ctx, req); apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected InvalidArgument for a token issued under other filters, got %v", err)
+	}
+	req.NameContains = ""
+	req.PageSize = 5
+	if _, err := repo.ListByUserID(ctx, req); err != nil {
+		t.Fatalf("changing the page size must keep the token valid, got %v", err)
+	}
+}
+
+func TestProductRepositorySearch(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	now := time.Now()
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "product_tags") {
+			return nil, nil
+		}
+		if args[0].Value != "red shoes" {
+			t.Fatalf("unexpected query arg: %v", args[0].Value)
+		}
+		return [][]driver.Value{
+			{int64(7), "Red shoes", "comfy", float64(30), "USD", int64(2), int64(1), int64(0), now, now, int64(1), float64(0.9), "<b>Red</b> <b>shoes</b>", "comfy", int64(2)},
+			{int64(8), "Red hat", "shoes not included", float64(5), "USD", int64(1), int64(1), int64(0), now, now, int64(1), float64(0.2), "<b>Red</b> hat", "<b>shoes</b>", int64(2)},
+		}, nil
+	}
+
+	res, err := repo.Search(ctx, &model.SearchProductsRequest{Query: "red shoes", PageSize: 1})
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if len(res.Hits) != 1 || res.TotalCount != 2 || res.NextPageToken == "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Hits[0].Product.ID != 7 || res.Hits[0].NameHighlight != "<b>Red</b> <b>shoes</b>" {
+		t.Fatalf("unexpected hit: %+v", res.Hits[0])
+	}
+}
+
+func TestProductRepositoryAdjustStockGuard(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	exists := true
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "SELECT EXISTS") {
+			return [][]driver.Value{{exists}}, nil
+		}
+		if !strings.Contains(query, "stock + $2 >= 0") {
+			t.Fatalf("stock guard missing from query: %s", query)
+		}
+		if args[1].Value.(int64) >= 0 {
+			return [][]driver.Value{{int64(7)}}, nil
+		}
+		return nil, nil
+	}
+
+	if stock, err := repo.AdjustStock(ctx, 1, 2); err != nil || stock != 7 {
+		t.Fatalf("expected stock 7, got %d (%v)", stock, err)
+	}
+	if _, err := repo.AdjustStock(ctx, 1, -100); !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock, got %v", err)
+	}
+	exists = false
+	if _, err := repo.AdjustStock(ctx, 1, -100); !apperror.IsNotFound(err) {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestOrderRepositoryCreateInsertsItems(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewOrderRepository(db)
+
+	var items int
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "UPDATE products") {
+			t.Fatalf("order repository must not touch stock: %s", query)
+		}
+		if strings.Contains(query, "INSERT INTO order_items") {
+			items++
+			if args[0].Value.(int64) != 9 || args[2].Value.(string) != "snapshot" {
+				t.Fatalf("unexpected item args: %v", args)
+			}
+			return [][]driver.Value{{int64(100 + items)}}, nil
+		}
+		return [][]driver.Value{{int64(9)}}, nil
+	}
+
+	order := &model.Order{UserID: 1, TotalAmount: usd(1500), Items: []*model.OrderItem{
+		{ProductID: 1, ProductName: "snapshot", Quantity: 2, UnitPrice: usd(250)},
+		{ProductID: 2, ProductName: "snapshot", Quantity: 1, UnitPrice: usd(1000)},
+	}}
+	if err := repo.Create(ctx, order); err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if order.ID != 9 || order.Status != model.OrderPlaced || items != 2 {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+	if order.Items[1].ID != 102 || order.Items[1].OrderID != 9 {
+		t.Fatalf("item ids not filled: %+v", order.Items[1])
+	}
 }
@@ -1,33 +1,186 @@
 package unit
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
+	"grpc-exmpl/internal/apperror"
 	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
 	"grpc-exmpl/internal/service"
 )
 
+// passthroughTx runs units of work directly, without a database
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
 type fakeProductRepo struct {
 	created *model.Product
 	stored  *model.Product
+	listed  *model.ListProductsRequest
+	tagged  map[int64][]string
+	deleted *model.Product
+	updated []string
+}
+
+func (f *fakeProductRepo) Create(ctx context.Context, p *model.Product) error {
+	f.created = p
+	p.ID = 1
+	return nil
+}
+func (f *fakeProductRepo) CreateBatch(ctx context.Context, ps []*model.Product) error {
+	for i, p := range ps {
+		p.ID = int64(i + 1)
+	}
+	return nil
+}
+func (f *fakeProductRepo) GetByID(ctx context.Context, id int64) (*model.Product, error) {
+	return f.stored, nil
+}
+func (f *fakeProductRepo) GetByIDs(ctx context.Context, ids []int64) ([]*model.Product, error) {
+	return nil, nil
+}
+func (f *fakeProductRepo) ListByUserID(ctx context.Context, req *model.ListProductsRequest) (*model.ProductPage, error) {
+	f.listed = req
+	return &model.ProductPage{}, nil
+}
+func (f *fakeProductRepo) Search(ctx context.Context, req *model.SearchProductsRequest) (*model.ProductSearchResult, error) {
+	return &model.ProductSearchResult{}, nil
+}
+func (f *fakeProductRepo) Export(ctx context.Context, userID int64, fetchSize int, fn func(*model.Product) error) error {
+	return nil
+}
+func (f *fakeProductRepo) Update(ctx context.Context, p *model.Product, fields []string) error {
+	p.Version++
+	f.stored = p
+	f.updated = fields
+	return nil
+}
+func (f *fakeProductRepo) AdjustStock(ctx context.Context, id int64, delta int) (int, error) {
+	if f.stored.Stock+delta < 0 {
+		return 0, repository.ErrInsufficientStock
+	}
+	f.stored.Stock += delta
+	return f.stored.Stock, nil
+}
+func (f *fakeProductRepo) SetTags(ctx context.Context, id int64, tags []string) error {
+	if f.tagged == nil {
+		f.tagged = make(map[int64][]string)
+	}
+	f.tagged[id] = tags
+	return nil
+}
+func (f *fakeProductRepo) Delete(ctx context.Context, id int64) error { return nil }
+func (f *fakeProductRepo) DeleteBatch(ctx context.Context, ids []int64) ([]int64, error) {
+	return ids, nil
+}
+func (f *fakeProductRepo) GetDeleted(ctx context.Context, id int64) (*model.Product, error) {
+	if f.deleted == nil || f.deleted.ID != id {
+		return nil, apperror.NotFound("product")
+	}
+	return f.deleted, nil
+}
+func (f *fakeProductRepo) Restore(ctx context.Context, id int64) error {
+	f.stored, f.deleted = f.deleted, nil
+	f.stored.DeletedAt = nil
+	return nil
+}
+func (f *fakeProductRepo) Purge(ctx context.Context, before time.Time) (int64, error) { return 0, nil }
+
+type fakeReservationRepo struct {
+	reservations map[int64]*model.StockReservation
+	// stock is every product's stock, with reserved units taken out
+	stock int
+}
+
+func (f *fakeReservationRepo) Reserve(ctx context.Context, r *model.StockReservation) (int, error) {
+	r.ID = int64(len(f.reservations) + 1)
+	r.Status = model.ReservationActive
+	f.reservations[r.ID] = r
+	f.stock -= r.Quantity
+	return f.stock, nil
+}
+func (f *fakeReservationRepo) GetByID(ctx context.Context, id int64) (*model.StockReservation, error) {
+	r, ok := f.reservations[id]
+	if !ok {
+		return nil, apperror.NotFound("reservation")
+	}
+	return r, nil
+}
+func (f *fakeReservationRepo) Release(ctx context.Context, id int64) (int, error) {
+	f.reservations[id].Status = model.ReservationReleased
+	f.stock += f.reservations[id].Quantity
+	return f.stock, nil
+}
+func (f *fakeReservationRepo) Commit(ctx context.Context, id int64) (int, error) {
+	f.reservations[id].Status = model.ReservationCommitted
+	return f.stock, nil
+}
+func (f *fakeReservationRepo) ReleaseExpired(ctx context.Context, now time.Time) ([]model.ReleasedStock, error) {
+	totals := make(map[int64]*model.ReleasedStock)
+	var released []model.ReleasedStock
+	for _, r := range f.reservations {
+		if r.Status != model.ReservationActive || r.ExpiresAt.After(now) {
+			continue
+		}
+		r.Status = model.ReservationReleased
+		f.stock += r.Quantity
+		if totals[r.ProductID] == nil {
+			totals[r.ProductID] = &model.ReleasedStock{ProductID: r.ProductID}
+		}
+		totals[r.ProductID].Reservations++
+		totals[r.ProductID].Quantity += r.Quantity
+	}
+	for _, t := range totals {
+		t.Stock = f.stock
+		released = append(released, *t)
+	}
+	return released, nil
 }
 
-func (f *fakeProductRepo) Create(p *model.Product) error                       { f.created = p; p.ID = 1; return nil }
-func (f *fakeProductRepo) GetByID(id int64) (*model.Product, error)            { return f.stored, nil }
-func (f *fakeProductRepo) ListByUserID(userID int64) ([]*model.Product, error) { return nil, nil }
-func (f *fakeProductRepo) Update(p *model.Product) error                       { f.stored = p; return nil }
-func (f *fakeProductRepo) Delete(id int64) error                               { return nil }
+// fakeOutbox records the events services add, ignoring the relay side
+type fakeOutbox struct {
+	events []*model.OutboxEvent
+}
+
+func (f *fakeOutbox) Add(ctx context.Context, events ...*model.OutboxEvent) error {
+	f.events = append(f.events, events...)
+	return nil
+}
+func (f *fakeOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxEvent, error) {
+	return nil, nil
+}
+func (f *fakeOutbox) MarkDelivered(ctx context.Context, id int64) error { return nil }
+func (f *fakeOutbox) Retry(ctx context.Context, id int64, lastErr string, at time.Time) error {
+	return nil
+}
+
+// types lists the recorded event types in order
+func (f *fakeOutbox) types() []string {
+	var out []string
+	for _, e := range f.events {
+		out = append(out, e.Type)
+	}
+	return out
+}
 
 func TestProductServiceCreateValidation(t *testing.T) {
+	ctx := context.Background()
 	repo := &fakeProductRepo{}
-	svc := service.NewProductService(repo)
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
 
-	_, err := svc.CreateProduct(&model.CreateProductRequest{Name: "", Price: 1, Stock: 1, UserID: 1})
+	_, err := svc.CreateProduct(ctx, &model.CreateProductRequest{Name: "", Price: usd(100), Stock: 1, UserID: 1})
 	if err == nil {
 		t.Fatal("expected validation error")
 	}
 
-	p, err := svc.CreateProduct(&model.CreateProductRequest{Name: "Book", Description: "good", Price: 2, Stock: 1, UserID: 3})
+	p, err := svc.CreateProduct(ctx, &model.CreateProductRequest{Name: "Book", Description: "good", Price: usd(200), Stock: 1, UserID: 3})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -37,15 +190,188 @@ func TestProductServiceCreateValidation(t *testing.T) {
 }
 
 func TestProductServiceUpdate(t *testing.T) {
-	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Description: "d", Price: 1, Stock: 1, UserID: 2}}
-	svc := service.NewProductService(repo)
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Description: "d", Price: usd(100), Stock: 1, UserID: 2, Version: 1}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
 
-	upd := &model.UpdateProductRequest{ID: 1, Name: "new", Description: "d2", Price: 2, Stock: 5}
-	res, err := svc.UpdateProduct(upd)
+	upd := &model.UpdateProductRequest{ID: 1, Name: "new", Description: "d2", Price: usd(200), Stock: 5, Version: 1}
+	res, err := svc.UpdateProduct(ctx, model.Caller{UserID: 2}, upd)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if res.Name != "new" || repo.stored.Price != 2 {
+	if res.Name != "new" || repo.stored.Price != usd(200) {
 		t.Fatalf("update did not apply")
 	}
 }
+
+func TestProductServiceListDefaults(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	if _, err := svc.ListProductsByUser(ctx, model.Caller{}, &model.ListProductsRequest{UserID: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.listed.PageSize != 20 || repo.listed.SortBy != model.ProductSortCreatedAt || !repo.listed.SortDesc {
+		t.Fatalf("unexpected defaults: %+v", repo.listed)
+	}
+
+	if _, err := svc.ListProductsByUser(ctx, model.Caller{}, &model.ListProductsRequest{UserID: 1, OrderBy: "price asc"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.listed.SortBy != model.ProductSortPrice || repo.listed.SortDesc {
+		t.Fatalf("order_by not applied: %+v", repo.listed)
+	}
+
+	for _, req := range []*model.ListProductsRequest{
+		{UserID: 1, PageSize: 1000},
+		{UserID: 1, OrderBy: "password"},
+		{UserID: 1, OrderBy: "price sideways"},
+	} {
+		if _, err := svc.ListProductsByUser(ctx, model.Caller{}, req); err == nil {
+			t.Fatalf("expected validation error for %+v", req)
+		}
+	}
+}
+
+func TestProductServiceRejectsForeignOwner(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Price: usd(100), Stock: 1, UserID: 2, Version: 1}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	upd := &model.UpdateProductRequest{ID: 1, Name: "new", Price: usd(200), Stock: 5, Version: 1}
+	if _, err := svc.UpdateProduct(ctx, model.Caller{UserID: 3, Roles: []string{model.RoleUser}}, upd); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied on update, got %v", err)
+	}
+	if err := svc.DeleteProduct(ctx, model.Caller{UserID: 3, Roles: []string{model.RoleUser}}, 1); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied on delete, got %v", err)
+	}
+	if repo.stored.Name != "old" {
+		t.Fatalf("foreign update was applied")
+	}
+
+	admin := model.Caller{UserID: 3, Roles: []string{model.RoleAdmin}}
+	if _, err := svc.UpdateProduct(ctx, admin, upd); err != nil {
+		t.Fatalf("admin update failed: %v", err)
+	}
+}
+
+func TestProductServiceAdjustStock(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "item", Price: usd(100), Stock: 2, UserID: 2}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+	owner := model.Caller{UserID: 2}
+
+	if stock, err := svc.AdjustStock(ctx, owner, 1, 3); err != nil || stock != 5 {
+		t.Fatalf("expected stock 5, got %d (%v)", stock, err)
+	}
+	if _, err := svc.AdjustStock(ctx, owner, 1, -6); !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock, got %v", err)
+	}
+	if _, err := svc.AdjustStock(ctx, model.Caller{UserID: 3}, 1, 1); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := svc.AdjustStock(ctx, owner, 1, 0); apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected validation error for zero delta, got %v", err)
+	}
+}
+
+func TestProductServiceReservations(t *testing.T) {
+	ctx := context.Background()
+	reservations := &fakeReservationRepo{reservations: map[int64]*model.StockReservation{}, stock: 10}
+	outbox := &fakeOutbox{}
+	svc := service.NewProductService(passthroughTx{}, &fakeProductRepo{}, reservations, outbox, service.InventoryConfig{
+		ReservationTTL:    15 * time.Minute,
+		MaxReservationTTL: time.Hour,
+	}, service.BatchConfig{})
+	buyer := model.Caller{UserID: 7}
+
+	res, err := svc.ReserveStock(ctx, buyer, &model.ReserveStockRequest{ProductID: 1, Quantity: 2})
+	if err != nil {
+		t.Fatalf("ReserveStock error: %v", err)
+	}
+	if res.UserID != 7 || time.Until(res.ExpiresAt) < 14*time.Minute {
+		t.Fatalf("unexpected reservation: %+v", res)
+	}
+	if _, err := svc.ReserveStock(ctx, buyer, &model.ReserveStockRequest{ProductID: 1, Quantity: 1, TTL: 2 * time.Hour}); err == nil {
+		t.Fatalf("expected ttl above the maximum to be rejected")
+	}
+
+	// A service authenticated by certificate has no user to hold the reservation
+	worker := model.Caller{Service: "orders-worker", Roles: []string{model.RoleAdmin}}
+	if _, err := svc.ReserveStock(ctx, worker, &model.ReserveStockRequest{ProductID: 1, Quantity: 1}); !errors.Is(err, service.ErrUserRequired) {
+		t.Fatalf("expected ErrUserRequired for a service caller, got %v", err)
+	}
+
+	if err := svc.ReleaseStock(ctx, model.Caller{UserID: 8}, res.ID); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied for another user, got %v", err)
+	}
+	if err := svc.CommitReservation(ctx, buyer, res.ID); err != nil {
+		t.Fatalf("CommitReservation error: %v", err)
+	}
+	if res.Status != model.ReservationCommitted {
+		t.Fatalf("expected committed reservation, got %s", res.Status)
+	}
+
+	released, err := svc.ReserveStock(ctx, buyer, &model.ReserveStockRequest{ProductID: 1, Quantity: 3})
+	if err != nil {
+		t.Fatalf("ReserveStock error: %v", err)
+	}
+	if err := svc.ReleaseStock(ctx, buyer, released.ID); err != nil {
+		t.Fatalf("ReleaseStock error: %v", err)
+	}
+
+	want := []model.StockChangedEvent{
+		{ProductID: 1, Delta: -2, Stock: 8, Reason: model.StockReasonReserved},
+		{ProductID: 1, Delta: 0, Stock: 8, Reason: model.StockReasonCommitted},
+		{ProductID: 1, Delta: -3, Stock: 5, Reason: model.StockReasonReserved},
+		{ProductID: 1, Delta: 3, Stock: 8, Reason: model.StockReasonReleased},
+	}
+	if len(outbox.events) != len(want) {
+		t.Fatalf("expected %d StockChanged events, got %d", len(want), len(outbox.events))
+	}
+	for i, e := range outbox.events {
+		var got model.StockChangedEvent
+		if err := json.Unmarshal(e.Payload, &got); err != nil {
+			t.Fatalf("bad payload: %v", err)
+		}
+		if e.Type != model.EventStockChanged || got != want[i] {
+			t.Fatalf("event %d: expected %+v, got %s %+v", i, want[i], e.Type, got)
+		}
+	}
+}
+
+func TestProductServiceReleaseExpiredReservations(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	reservations := &fakeReservationRepo{stock: 4, reservations: map[int64]*model.StockReservation{
+		1: {ID: 1, ProductID: 5, Quantity: 2, Status: model.ReservationActive, ExpiresAt: now.Add(-time.Minute)},
+		2: {ID: 2, ProductID: 5, Quantity: 1, Status: model.ReservationActive, ExpiresAt: now.Add(-time.Second)},
+		3: {ID: 3, ProductID: 6, Quantity: 4, Status: model.ReservationActive, ExpiresAt: now.Add(time.Minute)},
+	}}
+	outbox := &fakeOutbox{}
+	svc := service.NewProductService(passthroughTx{}, &fakeProductRepo{}, reservations, outbox, service.InventoryConfig{}, service.BatchConfig{})
+
+	released, err := svc.ReleaseExpiredReservations(ctx, now)
+	if err != nil {
+		t.Fatalf("ReleaseExpiredReservations error: %v", err)
+	}
+	if released != 2 {
+		t.Fatalf("expected 2 released reservations, got %d", released)
+	}
+	// One event per product, not per reservation
+	if len(outbox.events) != 1 {
+		t.Fatalf("expected one StockChanged event, got %d", len(outbox.events))
+	}
+	var got model.StockChangedEvent
+	if err := json.Unmarshal(outbox.events[0].Payload, &got); err != nil {
+		t.Fatalf("bad payload: %v", err)
+	}
+	if got != (model.StockChangedEvent{ProductID: 5, Delta: 3, Stock: 7, Reason: model.StockReasonExpired}) {
+		t.Fatalf("unexpected event %+v", got)
+	}
+
+	if _, err := svc.ReleaseExpiredReservations(ctx, now); err != nil || len(outbox.events) != 1 {
+		t.Fatalf("expected nothing more to release, got %v and %d events", err, len(outbox.events))
+	}
+}
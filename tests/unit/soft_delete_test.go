@@ -0,0 +1,203 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/internal/worker"
+	"grpc-exmpl/tests/testdb"
+)
+
+func TestListProductsIncludeDeletedIsAdminOnly(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	req := &model.ListProductsRequest{UserID: 1, IncludeDeleted: true}
+	if _, err := svc.ListProductsByUser(ctx, model.Caller{UserID: 1}, req); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected permission denied for non-admin, got %v", err)
+	}
+
+	admin := model.Caller{UserID: 9, Roles: []string{model.RoleAdmin}}
+	if _, err := svc.ListProductsByUser(ctx, admin, req); err != nil {
+		t.Fatalf("admin listing error: %v", err)
+	}
+	if !repo.listed.IncludeDeleted {
+		t.Fatal("include_deleted was not passed to the repository")
+	}
+}
+
+func TestRestoreProductChecksOwnership(t *testing.T) {
+	ctx := context.Background()
+	deletedAt := time.Now()
+	repo := &fakeProductRepo{deleted: &model.Product{ID: 4, Name: "item", UserID: 2, DeletedAt: &deletedAt}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	if _, err := svc.RestoreProduct(ctx, model.Caller{UserID: 3}, 4); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected permission denied for stranger, got %v", err)
+	}
+
+	p, err := svc.RestoreProduct(ctx, model.Caller{UserID: 2}, 4)
+	if err != nil {
+		t.Fatalf("RestoreProduct error: %v", err)
+	}
+	if p.ID != 4 || p.DeletedAt != nil {
+		t.Fatalf("unexpected restored product: %+v", p)
+	}
+
+	if _, err := svc.RestoreProduct(ctx, model.Caller{UserID: 2}, 4); !apperror.IsNotFound(err) {
+		t.Fatalf("expected not found for a product that is not deleted, got %v", err)
+	}
+}
+
+func TestDeleteAndRestoreUserPermissions(t *testing.T) {
+	ctx := context.Background()
+	users := &fakeUserRepo{users: map[int64]*model.User{
+		1: {ID: 1, Username: "alice"},
+		2: {ID: 2, Username: "bob"},
+	}}
+	svc := service.NewUserService(passthroughTx{}, users, newFakeTokenRepo(), &fakeOutbox{}, service.TokenConfig{})
+	admin := model.Caller{UserID: 9, Roles: []string{model.RoleAdmin}}
+
+	if err := svc.DeleteUser(ctx, model.Caller{UserID: 1}, 2); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected permission denied deleting another user, got %v", err)
+	}
+	if err := svc.DeleteUser(ctx, model.Caller{UserID: 1}, 1); err != nil {
+		t.Fatalf("self delete error: %v", err)
+	}
+
+	if _, err := svc.RestoreUser(ctx, model.Caller{UserID: 1}, 1); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected permission denied for non-admin restore, got %v", err)
+	}
+	u, err := svc.RestoreUser(ctx, admin, 1)
+	if err != nil {
+		t.Fatalf("RestoreUser error: %v", err)
+	}
+	if u.Username != "alice" {
+		t.Fatalf("unexpected restored user: %+v", u)
+	}
+}
+
+func TestProductRepositoryListExcludesDeletedByDefault(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	now := time.Now()
+	var listQuery string
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "COUNT(*)") {
+			return [][]driver.Value{{int64(1)}}, nil
+		}
+		if strings.Contains(query, "product_tags") {
+			return nil, nil
+		}
+		listQuery = query
+		return [][]driver.Value{{int64(1), "A", "", float64(1), "USD", int64(1), int64(1), int64(0), now, now, int64(3), now}}, nil
+	}
+
+	req := &model.ListProductsRequest{UserID: 1, PageSize: 10, SortBy: model.ProductSortCreatedAt}
+	if _, err := repo.ListByUserID(ctx, req); err != nil {
+		t.Fatalf("ListByUserID error: %v", err)
+	}
+	if !strings.Contains(listQuery, "deleted_at IS NULL") {
+		t.Fatalf("deleted rows not excluded: %s", listQuery)
+	}
+
+	req.IncludeDeleted = true
+	page, err := repo.ListByUserID(ctx, req)
+	if err != nil {
+		t.Fatalf("ListByUserID error: %v", err)
+	}
+	if strings.Contains(listQuery, "deleted_at IS NULL") {
+		t.Fatalf("deleted rows excluded despite include_deleted: %s", listQuery)
+	}
+	if page.Products[0].DeletedAt == nil {
+		t.Fatal("deleted_at was not scanned")
+	}
+}
+
+func TestProductRepositoryDeleteIsSoft(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	var execQuery string
+	stub.ExecFunc = func(query string, args []driver.NamedValue) (int64, int64, error) {
+		execQuery = query
+		return 0, 1, nil
+	}
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(execQuery), "UPDATE products SET deleted_at") {
+		t.Fatalf("expected a soft delete, got: %s", execQuery)
+	}
+}
+
+func TestUserRepositoryRestoreNotDeleted(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewUserRepository(db)
+
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		return [][]driver.Value{{int64(0)}}, nil
+	}
+
+	if err := repo.Restore(ctx, 1); !apperror.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+type purgeRecorder struct {
+	fakeProductRepo
+	before time.Time
+}
+
+func (p *purgeRecorder) Purge(ctx context.Context, before time.Time) (int64, error) {
+	p.before = before
+	return 1, nil
+}
+
+type userPurgeRecorder struct {
+	fakeUserRepo
+	before time.Time
+}
+
+func (u *userPurgeRecorder) Purge(ctx context.Context, before time.Time) (int64, error) {
+	u.before = before
+	return 1, nil
+}
+
+func TestPurgerUsesRetentionCutoff(t *testing.T) {
+	products := &purgeRecorder{}
+	users := &userPurgeRecorder{}
+	retention := 48 * time.Hour
+
+	start := time.Now()
+	worker.NewPurger(products, users, retention, time.Hour).Purge(context.Background())
+
+	for name, before := range map[string]time.Time{"products": products.before, "users": users.before} {
+		if before.Before(start.Add(-retention)) || before.After(time.Now().Add(-retention)) {
+			t.Fatalf("%s purged with cutoff %v, want about %v", name, before, start.Add(-retention))
+		}
+	}
+}
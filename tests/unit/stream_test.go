@@ -0,0 +1,150 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/tests/testdb"
+)
+
+// importSource feeds items to ImportProducts the way a client stream would
+func importSource(items []*model.CreateProductRequest) func() (*model.CreateProductRequest, error) {
+	return func() (*model.CreateProductRequest, error) {
+		if len(items) == 0 {
+			return nil, io.EOF
+		}
+		item := items[0]
+		items = items[1:]
+		return item, nil
+	}
+}
+
+func TestImportProductsAcknowledgesEachChunk(t *testing.T) {
+	ctx := context.Background()
+	repo := newBatchRepo()
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{ImportAckEvery: 2})
+
+	items := []*model.CreateProductRequest{
+		{Name: "a", Price: usd(100), UserID: 1},
+		{Name: "b", Price: usd(100), UserID: 1},
+		{Name: "c", Price: usd(100), UserID: 1, CategoryID: 99},
+		{Name: "", Price: usd(100), UserID: 1},
+		{Name: "e", Price: usd(100), UserID: 1},
+	}
+	var acks []*model.ImportProgress
+	report := func(p *model.ImportProgress) error {
+		acks = append(acks, p)
+		return nil
+	}
+
+	if err := svc.ImportProducts(ctx, importSource(items), report); err != nil {
+		t.Fatalf("ImportProducts error: %v", err)
+	}
+
+	if len(acks) != 3 {
+		t.Fatalf("expected acks after 2, 4 and 5 items, got %d", len(acks))
+	}
+	if acks[0].Received != 2 || acks[0].Created != 2 || len(acks[0].Errors) != 0 || acks[0].Done {
+		t.Fatalf("unexpected first ack: %+v", acks[0])
+	}
+	if acks[1].Failed != 2 || len(acks[1].Errors) != 2 || acks[1].Errors[0].Index != 2 || acks[1].Errors[1].Index != 3 {
+		t.Fatalf("unexpected second ack: %+v", acks[1])
+	}
+	last := acks[2]
+	if !last.Done || last.Received != 5 || last.Created != 3 || last.Failed != 2 || len(last.Errors) != 0 {
+		t.Fatalf("unexpected final ack: %+v", last)
+	}
+	if len(repo.products) != 3 {
+		t.Fatalf("expected 3 stored products, got %d", len(repo.products))
+	}
+}
+
+func TestImportProductsStopsOnSourceError(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewProductService(passthroughTx{}, newBatchRepo(), nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	broken := errors.New("stream reset")
+	next := func() (*model.CreateProductRequest, error) { return nil, broken }
+	reported := false
+	err := svc.ImportProducts(ctx, next, func(*model.ImportProgress) error {
+		reported = true
+		return nil
+	})
+	if !errors.Is(err, broken) || reported {
+		t.Fatalf("expected the source error without a final ack, got %v (reported=%v)", err, reported)
+	}
+}
+
+func TestExportProductsRequiresUser(t *testing.T) {
+	svc := service.NewProductService(passthroughTx{}, &fakeProductRepo{}, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	err := svc.ExportProducts(context.Background(), 0, func(*model.Product) error { return nil })
+	if apperror.KindOf(err) != apperror.KindValidation {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
+
+func TestProductRepositoryExportFetchesThroughCursor(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	var execs []string
+	stub.ExecFunc = func(query string, args []driver.NamedValue) (int64, int64, error) {
+		execs = append(execs, strings.TrimSpace(query))
+		return 0, 0, nil
+	}
+
+	now := time.Now()
+	row := func(id int64) []driver.Value {
+		return []driver.Value{id, "A", "", float64(1), "USD", int64(1), int64(7), int64(0), now, now, int64(1)}
+	}
+	fetches := 0
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "product_tags") {
+			return nil, nil
+		}
+		fetches++
+		if !strings.Contains(query, "FETCH FORWARD 2 FROM product_export") {
+			t.Fatalf("unexpected query: %s", query)
+		}
+		switch fetches {
+		case 1:
+			return [][]driver.Value{row(1), row(2)}, nil
+		case 2:
+			return [][]driver.Value{row(3)}, nil
+		}
+		return nil, nil
+	}
+
+	var ids []int64
+	err = repo.Export(ctx, 7, 2, func(p *model.Product) error {
+		ids = append(ids, p.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+
+	if len(ids) != 3 || ids[2] != 3 || fetches != 2 {
+		t.Fatalf("expected 3 products over 2 fetches, got %v over %d", ids, fetches)
+	}
+	if stub.Begins != 1 {
+		t.Fatalf("expected the cursor to run in its own transaction, got %d begins", stub.Begins)
+	}
+	if len(execs) != 2 || !strings.HasPrefix(execs[0], "DECLARE product_export") || execs[1] != "CLOSE product_export" {
+		t.Fatalf("unexpected cursor statements: %v", execs)
+	}
+}
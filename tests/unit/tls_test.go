@@ -0,0 +1,224 @@
+package unit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/config"
+	"grpc-exmpl/internal/middleware"
+	"grpc-exmpl/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// issueCert creates a certificate for cn signed by parent, or self-signed
+// when parent is nil, and returns it with its PEM encodings
+func issueCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, uris ...string) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	for _, u := range uris {
+		parsed, _ := url.Parse(u)
+		tmpl.URIs = append(tmpl.URIs, parsed)
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate error: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+}
+
+func servedCommonName(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	current, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetConfigForClient error: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(current.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate error: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloaderPicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	files := utils.TLSFiles{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	_, _, caPEM, _ := issueCert(t, "ca", nil, nil)
+	_, _, certPEM, keyPEM := issueCert(t, "first", nil, nil)
+	writeFile(t, files.CertFile, certPEM)
+	writeFile(t, files.KeyFile, keyPEM)
+	writeFile(t, files.ClientCAFile, caPEM)
+
+	reloader, err := utils.NewCertReloader(files, tls.RequireAndVerifyClientCert)
+	if err != nil {
+		t.Fatalf("NewCertReloader error: %v", err)
+	}
+	if err := reloader.Watch(); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	defer reloader.Close()
+
+	cfg := reloader.TLSConfig()
+	if cn := servedCommonName(t, cfg); cn != "first" {
+		t.Fatalf("expected first certificate, got %q", cn)
+	}
+	current, _ := cfg.GetConfigForClient(&tls.ClientHelloInfo{})
+	if current.ClientAuth != tls.RequireAndVerifyClientCert || current.ClientCAs == nil {
+		t.Fatalf("expected client certificates to be required, got %v", current.ClientAuth)
+	}
+
+	_, _, certPEM, keyPEM = issueCert(t, "second", nil, nil)
+	writeFile(t, files.CertFile, certPEM)
+	writeFile(t, files.KeyFile, keyPEM)
+	deadline := time.Now().Add(5 * time.Second)
+	for servedCommonName(t, cfg) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatalf("rotated certificate was not picked up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A broken rotation keeps the last good certificate
+	writeFile(t, files.KeyFile, []byte("not a key"))
+	time.Sleep(300 * time.Millisecond)
+	if cn := servedCommonName(t, cfg); cn != "second" {
+		t.Fatalf("expected the previous certificate to stay in use, got %q", cn)
+	}
+
+	if _, err := utils.NewCertReloader(utils.TLSFiles{CertFile: files.CertFile, KeyFile: files.KeyFile}, tls.NoClientCert); err == nil {
+		t.Fatalf("expected an error loading an invalid key")
+	}
+}
+
+// tlsPeerContext is the context of a call made with a verified client
+// certificate
+func tlsPeerContext(cert *x509.Certificate) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.7"), Port: 50000},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}},
+	})
+}
+
+func TestAuthAcceptsVerifiedClientCertificates(t *testing.T) {
+	policies, err := middleware.NewPolicyTable(config.AuthConfig{
+		Policies: []config.MethodPolicy{
+			{Method: "/svc/Admin", Access: "roles", Roles: []string{"admin"}},
+		},
+		Services: []config.ServiceIdentity{
+			{Name: "spiffe://example.org/orders-worker", Roles: []string{"admin"}},
+			{Name: "reporting", Roles: []string{"reader"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyTable error: %v", err)
+	}
+	_, userSvc, token := newTestAuth(t)
+	auth := middleware.NewAuthMiddleware(userSvc, policies)
+
+	ca, caKey, _, _ := issueCert(t, "ca", nil, nil)
+	worker, _, _, _ := issueCert(t, "orders-worker", ca, caKey, "spiffe://example.org/orders-worker")
+	reporting, _, _, _ := issueCert(t, "reporting", ca, caKey)
+	stranger, _, _, _ := issueCert(t, "stranger", ca, caKey)
+
+	call := func(ctx context.Context, method string) (context.Context, error) {
+		var got context.Context
+		_, err := auth.UnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = ctx
+			return nil, nil
+		})
+		return got, err
+	}
+
+	ctx, err := call(tlsPeerContext(worker), "/svc/Admin")
+	if err != nil {
+		t.Fatalf("expected the service to be admitted, got %v", err)
+	}
+	caller, ok := middleware.GetCallerFromContext(ctx)
+	if !ok || caller.Service != "spiffe://example.org/orders-worker" || caller.UserID != 0 || !caller.IsAdmin() {
+		t.Fatalf("unexpected caller %+v", caller)
+	}
+	identity, ok := middleware.GetClientIdentityFromContext(ctx)
+	if !ok || identity.CommonName != "orders-worker" || len(identity.URIs) != 1 {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	// The subject common name also identifies a service
+	if _, err := call(tlsPeerContext(reporting), "/svc/Admin"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for a service without the role, got %v", err)
+	}
+	if _, err := call(tlsPeerContext(reporting), "/svc/Read"); err != nil {
+		t.Fatalf("expected a known service to be authenticated, got %v", err)
+	}
+
+	if _, err := call(tlsPeerContext(stranger), "/svc/Read"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for an unknown certificate, got %v", err)
+	}
+
+	// A bearer token takes precedence over the certificate
+	withToken := metadata.NewIncomingContext(tlsPeerContext(worker), metadata.Pairs("authorization", "Bearer "+token))
+	ctx, err = call(withToken, "/svc/Read")
+	if err != nil {
+		t.Fatalf("expected the token to be accepted, got %v", err)
+	}
+	if caller, _ := middleware.GetCallerFromContext(ctx); caller.UserID != 1 || caller.Service != "" {
+		t.Fatalf("expected the token's user, got %+v", caller)
+	}
+
+	if _, err := middleware.NewPolicyTable(config.AuthConfig{Services: []config.ServiceIdentity{{Roles: []string{"admin"}}}}); err == nil {
+		t.Fatalf("expected an error for a service identity without a name")
+	}
+}
@@ -0,0 +1,167 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/tests/testdb"
+
+	"github.com/lib/pq"
+)
+
+func TestTxManagerRetriesSerializationFailures(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	tm := repository.NewTxManager(db, repository.TxConfig{
+		Isolation:  sql.LevelSerializable,
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	})
+
+	calls := 0
+	err = tm.WithinTx(ctx, func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return &pq.Error{Code: "40001"}
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("expected success on second attempt, got %d calls (%v)", calls, err)
+	}
+	if stub.Rollbacks != 1 || stub.Commits != 1 {
+		t.Fatalf("expected 1 rollback and 1 commit, got %d/%d", stub.Rollbacks, stub.Commits)
+	}
+	if stub.Isolation != driver.IsolationLevel(sql.LevelSerializable) {
+		t.Fatalf("isolation level not applied: %v", stub.Isolation)
+	}
+
+	calls = 0
+	err = tm.WithinTx(ctx, func(ctx context.Context) error {
+		calls++
+		return &pq.Error{Code: "40001"}
+	})
+	if calls != 3 || err == nil {
+		t.Fatalf("expected retries to give up after 3 attempts, got %d (%v)", calls, err)
+	}
+
+	calls = 0
+	boom := errors.New("boom")
+	if err := tm.WithinTx(ctx, func(ctx context.Context) error { calls++; return boom }); !errors.Is(err, boom) || calls != 1 {
+		t.Fatalf("expected other errors to fail without retry, got %d (%v)", calls, err)
+	}
+}
+
+func TestTxManagerNestedCallsJoinOuterTx(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	tm := repository.NewTxManager(db, repository.TxConfig{})
+
+	err = tm.WithinTx(ctx, func(ctx context.Context) error {
+		return tm.WithinTx(ctx, func(ctx context.Context) error { return nil })
+	})
+	if err != nil {
+		t.Fatalf("WithinTx error: %v", err)
+	}
+	if stub.Begins != 1 || stub.Commits != 1 {
+		t.Fatalf("expected a single transaction, got %d begins and %d commits", stub.Begins, stub.Commits)
+	}
+}
+
+func TestParseIsolationLevel(t *testing.T) {
+	cases := map[string]sql.IsolationLevel{
+		"":                sql.LevelDefault,
+		"read_committed":  sql.LevelReadCommitted,
+		"Repeatable Read": sql.LevelRepeatableRead,
+		"serializable":    sql.LevelSerializable,
+	}
+	for in, want := range cases {
+		if got, err := repository.ParseIsolationLevel(in); err != nil || got != want {
+			t.Fatalf("ParseIsolationLevel(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := repository.ParseIsolationLevel("read_uncommitted"); err == nil {
+		t.Fatalf("expected unsupported level to be rejected")
+	}
+}
+
+func TestOrderCheckoutRollsBackOnInsufficientStock(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	svc := service.NewOrderService(
+		repository.NewTxManager(db, repository.TxConfig{}),
+		repository.NewOrderRepository(db),
+		repository.NewProductRepository(db),
+		repository.NewOutboxRepository(db),
+	)
+
+	now := time.Now()
+	prices := map[int64]string{1: "2.50", 2: "10.00"}
+	var taken []int64
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "product_tags"):
+			return nil, nil
+		case strings.Contains(query, "UPDATE products"):
+			id := args[0].Value.(int64)
+			taken = append(taken, id)
+			if id == 3 {
+				return nil, nil
+			}
+			return [][]driver.Value{{int64(5)}}, nil
+		case strings.Contains(query, "SELECT EXISTS"):
+			return [][]driver.Value{{true}}, nil
+		case strings.Contains(query, "FROM products"):
+			id := args[0].Value.(int64)
+			return [][]driver.Value{{id, "product", "d", []byte(prices[id]), "USD", int64(5), int64(7), int64(0), now, now, int64(1)}}, nil
+		default:
+			return [][]driver.Value{{int64(9)}}, nil
+		}
+	}
+
+	buyer := model.Caller{UserID: 1}
+	order, err := svc.CreateOrder(ctx, buyer, &model.CreateOrderRequest{Items: []model.OrderLine{
+		{ProductID: 2, Quantity: 1},
+		{ProductID: 1, Quantity: 2},
+	}})
+	if err != nil {
+		t.Fatalf("CreateOrder error: %v", err)
+	}
+	if order.ID != 9 || order.TotalAmount != usd(1500) || order.Items[0].UnitPrice != usd(250) {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+	if len(taken) != 2 || taken[0] != 1 || taken[1] != 2 {
+		t.Fatalf("stock not taken in product id order: %v", taken)
+	}
+	if stub.Commits != 1 {
+		t.Fatalf("expected checkout to commit, got %d commits", stub.Commits)
+	}
+
+	_, err = svc.CreateOrder(ctx, buyer, &model.CreateOrderRequest{Items: []model.OrderLine{
+		{ProductID: 1, Quantity: 1},
+		{ProductID: 3, Quantity: 1},
+	}})
+	if !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock, got %v", err)
+	}
+	if stub.Rollbacks != 1 || stub.Commits != 1 {
+		t.Fatalf("expected failed checkout to roll back, got %d rollbacks", stub.Rollbacks)
+	}
+}
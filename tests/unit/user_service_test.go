@@ -0,0 +1,258 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/pkg/utils"
+)
+
+type fakeUserRepo struct {
+	users   map[int64]*model.User
+	deleted map[int64]*model.User
+}
+
+func (f *fakeUserRepo) Create(ctx context.Context, u *model.User) error {
+	u.ID = int64(len(f.users) + 1)
+	f.users[u.ID] = u
+	return nil
+}
+func (f *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, u := range f.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+func (f *fakeUserRepo) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	if u, ok := f.users[id]; ok {
+		return u, nil
+	}
+	return nil, apperror.NotFound("user")
+}
+func (f *fakeUserRepo) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+func (f *fakeUserRepo) Update(ctx context.Context, u *model.User, fields []string) error {
+	f.users[u.ID] = u
+	return nil
+}
+func (f *fakeUserRepo) Delete(ctx context.Context, id int64) error {
+	u, ok := f.users[id]
+	if !ok {
+		return apperror.NotFound("user")
+	}
+	if f.deleted == nil {
+		f.deleted = make(map[int64]*model.User)
+	}
+	f.deleted[id] = u
+	delete(f.users, id)
+	return nil
+}
+func (f *fakeUserRepo) Restore(ctx context.Context, id int64) error {
+	u, ok := f.deleted[id]
+	if !ok {
+		return apperror.NotFound("user")
+	}
+	f.users[id] = u
+	delete(f.deleted, id)
+	return nil
+}
+func (f *fakeUserRepo) Purge(ctx context.Context, before time.Time) (int64, error) { return 0, nil }
+
+type fakeTokenRepo struct {
+	byHash    map[string]*model.RefreshToken
+	createErr error
+}
+
+func newFakeTokenRepo() *fakeTokenRepo {
+	return &fakeTokenRepo{byHash: map[string]*model.RefreshToken{}}
+}
+
+func (f *fakeTokenRepo) Create(ctx context.Context, t *model.RefreshToken) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	t.ID = int64(len(f.byHash) + 1)
+	f.byHash[t.TokenHash] = t
+	return nil
+}
+func (f *fakeTokenRepo) GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	if t, ok := f.byHash[hash]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("refresh token not found")
+}
+func (f *fakeTokenRepo) MarkUsed(ctx context.Context, id int64) (bool, error) {
+	for _, t := range f.byHash {
+		if t.ID == id {
+			if t.UsedAt != nil {
+				return false, nil
+			}
+			now := time.Now()
+			t.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (f *fakeTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	for _, t := range f.byHash {
+		if t.FamilyID == familyID {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+func (f *fakeTokenRepo) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	for _, t := range f.byHash {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestUserServiceRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	hash, err := utils.HashPassword("secret1")
+	if err != nil {
+		t.Fatalf("HashPassword error: %v", err)
+	}
+	users := &fakeUserRepo{users: map[int64]*model.User{
+		1: {ID: 1, Email: "alice@example.com", Username: "alice", Password: hash},
+	}}
+	tokens := newFakeTokenRepo()
+	svc := service.NewUserService(passthroughTx{}, users, tokens, &fakeOutbox{}, service.TokenConfig{
+		Keys:       utils.NewHMACKeySet("secret"),
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+
+	login, err := svc.Login(ctx, &model.LoginRequest{Email: "alice@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+
+	rotated, err := svc.RefreshToken(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken error: %v", err)
+	}
+	if rotated.RefreshToken == login.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, err := svc.ValidateToken(ctx, rotated.Token); err != nil {
+		t.Fatalf("rotated access token rejected: %v", err)
+	}
+
+	// Replaying the first token revokes the whole family
+	if _, err := svc.RefreshToken(ctx, login.RefreshToken); err == nil {
+		t.Fatal("expected reuse to be rejected")
+	}
+	if _, err := svc.RefreshToken(ctx, rotated.RefreshToken); err == nil {
+		t.Fatal("expected session to be revoked after reuse")
+	}
+	if _, err := svc.ValidateToken(ctx, rotated.Token); err == nil {
+		t.Fatal("expected access token of revoked session to be rejected")
+	}
+}
+
+// tokenTx rolls the fake token store back when the transaction fails
+type tokenTx struct {
+	tokens *fakeTokenRepo
+}
+
+func (tx tokenTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	saved := make(map[string]model.RefreshToken, len(tx.tokens.byHash))
+	for hash, t := range tx.tokens.byHash {
+		saved[hash] = *t
+	}
+	if err := fn(ctx); err != nil {
+		for hash, t := range tx.tokens.byHash {
+			if s, ok := saved[hash]; ok {
+				*t = s
+			} else {
+				delete(tx.tokens.byHash, hash)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func TestUserServiceRefreshTokenSurvivesFailedRotation(t *testing.T) {
+	ctx := context.Background()
+	hash, err := utils.HashPassword("secret1")
+	if err != nil {
+		t.Fatalf("HashPassword error: %v", err)
+	}
+	users := &fakeUserRepo{users: map[int64]*model.User{
+		1: {ID: 1, Email: "alice@example.com", Username: "alice", Password: hash},
+	}}
+	tokens := newFakeTokenRepo()
+	svc := service.NewUserService(tokenTx{tokens}, users, tokens, &fakeOutbox{}, service.TokenConfig{
+		Keys:       utils.NewHMACKeySet("secret"),
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+
+	login, err := svc.Login(ctx, &model.LoginRequest{Email: "alice@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+
+	// A failure storing the successor must not burn the presented token
+	tokens.createErr = errors.New("connection reset")
+	if _, err := svc.RefreshToken(ctx, login.RefreshToken); err == nil {
+		t.Fatal("expected the store failure to surface")
+	}
+	tokens.createErr = nil
+	if _, err := svc.RefreshToken(ctx, login.RefreshToken); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+
+	// A user deleted since login can no longer refresh
+	login, err = svc.Login(ctx, &model.LoginRequest{Email: "alice@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	delete(users.users, 1)
+	if _, err := svc.RefreshToken(ctx, login.RefreshToken); apperror.KindOf(err) != apperror.KindUnauthenticated {
+		t.Fatalf("expected Unauthenticated for a deleted user, got %v", err)
+	}
+}
+
+func TestUserServiceLogout(t *testing.T) {
+	ctx := context.Background()
+	hash, err := utils.HashPassword("secret1")
+	if err != nil {
+		t.Fatalf("HashPassword error: %v", err)
+	}
+	users := &fakeUserRepo{users: map[int64]*model.User{
+		1: {ID: 1, Email: "alice@example.com", Username: "alice", Password: hash},
+	}}
+	svc := service.NewUserService(passthroughTx{}, users, newFakeTokenRepo(), &fakeOutbox{}, service.TokenConfig{
+		Keys:       utils.NewHMACKeySet("secret"),
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+
+	login, err := svc.Login(ctx, &model.LoginRequest{Email: "alice@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	if err := svc.Logout(ctx, login.RefreshToken); err != nil {
+		t.Fatalf("Logout error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, login.Token); err == nil {
+		t.Fatal("expected access token to be rejected after logout")
+	}
+}
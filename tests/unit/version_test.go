@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/tests/testdb"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUpdateProductRejectsStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Price: usd(100), Stock: 1, UserID: 2, Version: 4}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	upd := &model.UpdateProductRequest{ID: 1, Name: "new", Price: usd(200), Stock: 5, Version: 3}
+	_, err := svc.UpdateProduct(ctx, model.Caller{UserID: 2}, upd)
+	if !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("expected version mismatch, got %v", err)
+	}
+	if repo.stored.Name != "old" {
+		t.Fatal("stale update was applied")
+	}
+
+	st := apperror.ToStatus(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", st.Code())
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Metadata["current_version"] == "4" {
+			return
+		}
+	}
+	t.Fatalf("current_version missing from details: %v", st.Details())
+}
+
+func TestUpdateProductBumpsVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeProductRepo{stored: &model.Product{ID: 1, Name: "old", Price: usd(100), Stock: 1, UserID: 2, Version: 4}}
+	svc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{})
+
+	p, err := svc.UpdateProduct(ctx, model.Caller{UserID: 2}, &model.UpdateProductRequest{ID: 1, Name: "new", Price: usd(200), Stock: 5, Version: 4})
+	if err != nil {
+		t.Fatalf("UpdateProduct error: %v", err)
+	}
+	if p.Version != 5 {
+		t.Fatalf("expected version 5, got %d", p.Version)
+	}
+}
+
+func TestProductRepositoryUpdateVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductRepository(db)
+
+	var updateQuery string
+	var updateArgs []driver.NamedValue
+	stub.QueryFunc = func(query string, args []driver.NamedValue) ([][]driver.Value, error) {
+		if strings.Contains(query, "UPDATE products") {
+			updateQuery, updateArgs = query, args
+			return nil, nil // someone else bumped the version first
+		}
+		return [][]driver.Value{{int64(7)}}, nil
+	}
+
+	p := &model.Product{ID: 1, Name: "new", Price: usd(100), Version: 6}
+	err = repo.Update(ctx, p, []string{"name", "price"})
+	if !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("expected version mismatch, got %v", err)
+	}
+	if !strings.Contains(updateQuery, "version = $2") || updateArgs[1].Value != int64(6) {
+		t.Fatalf("update not guarded by the expected version: %s %v", updateQuery, updateArgs)
+	}
+	if appErr, _ := apperror.As(err); appErr.Metadata["current_version"] != "7" {
+		t.Fatalf("expected current_version 7, got %v", appErr.Metadata)
+	}
+}
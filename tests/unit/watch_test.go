@@ -0,0 +1,238 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/tests/testdb"
+)
+
+type fakeEventRepo struct {
+	mu     sync.Mutex
+	events []*model.ProductEvent
+	head   model.ProductEventCursor
+	// xmin, when set, holds back events from this txid on, as if their
+	// transaction's predecessor were still running
+	xmin int64
+}
+
+func (f *fakeEventRepo) add(e *model.ProductEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, e)
+}
+
+func (f *fakeEventRepo) Head(ctx context.Context) (model.ProductEventCursor, error) {
+	return f.head, nil
+}
+
+func (f *fakeEventRepo) setXmin(xmin int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.xmin = xmin
+}
+
+func (f *fakeEventRepo) ListAfter(ctx context.Context, after model.ProductEventCursor, filter *model.ProductEventFilter, limit int) ([]*model.ProductEvent, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*model.ProductEvent
+	for _, e := range f.events {
+		past := e.TxID > after.TxID || (e.TxID == after.TxID && e.ID > after.ID)
+		if !past || (filter.UserID > 0 && e.UserID != filter.UserID) ||
+			(len(filter.ProductIDs) > 0 && !slices.Contains(filter.ProductIDs, e.ProductID)) {
+			continue
+		}
+		if f.xmin > 0 && e.TxID >= f.xmin {
+			return out, true, nil
+		}
+		copied := *e
+		out = append(out, &copied)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, false, nil
+}
+
+func (f *fakeEventRepo) Exists(ctx context.Context, id int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range f.events {
+		if e.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeEventRepo) Prune(ctx context.Context, before time.Time) (int64, error) { return 0, nil }
+
+// watchUntil runs WatchProducts until n events arrive or the timeout passes
+func watchUntil(t *testing.T, watcher service.ProductWatcher, req *model.WatchProductsRequest, n int, during func()) []*model.ProductEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var got []*model.ProductEvent
+	errc := make(chan error, 1)
+	go func() {
+		errc <- watcher.WatchProducts(ctx, req, func(e *model.ProductEvent) error {
+			got = append(got, e)
+			if len(got) == n {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	if during != nil {
+		during()
+	}
+
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("watch ended with %v after %d events", err, len(got))
+	}
+	return got
+}
+
+func TestWatchProductsResumesAfterToken(t *testing.T) {
+	events := &fakeEventRepo{events: []*model.ProductEvent{
+		{ID: 1, TxID: 10, Type: model.ProductCreated, ProductID: 5, UserID: 2},
+		{ID: 3, TxID: 11, Type: model.ProductUpdated, ProductID: 5, UserID: 2},
+		{ID: 2, TxID: 12, Type: model.ProductDeleted, ProductID: 6, UserID: 3},
+		{ID: 4, TxID: 12, Type: model.ProductUpdated, ProductID: 5, UserID: 2},
+	}}
+	products := newBatchRepo()
+	products.products[5] = &model.Product{ID: 5, Name: "lamp", Price: usd(1500), UserID: 2}
+	watcher := service.NewProductWatcher(events, products, service.NewProductFeed(), service.WatchConfig{})
+
+	all := watchUntil(t, watcher, &model.WatchProductsRequest{ResumeToken: "a.1"}, 3, nil)
+	if all[0].ID != 3 || all[1].ID != 2 || all[2].ID != 4 {
+		t.Fatalf("events not in feed order: %d, %d, %d", all[0].ID, all[1].ID, all[2].ID)
+	}
+	if all[0].Product == nil || all[0].Product.Name != "lamp" || all[1].Product != nil {
+		t.Fatalf("unexpected products attached: %+v, %+v", all[0].Product, all[1].Product)
+	}
+
+	// Resuming from a delivered event's token skips everything up to it
+	resumed := watchUntil(t, watcher, &model.WatchProductsRequest{ResumeToken: all[1].ResumeToken}, 1, nil)
+	if resumed[0].ID != 4 {
+		t.Fatalf("expected to resume at event 4, got %d", resumed[0].ID)
+	}
+
+	owned := watchUntil(t, watcher, &model.WatchProductsRequest{
+		ProductEventFilter: model.ProductEventFilter{UserID: 3},
+		ResumeToken:        "a.1",
+	}, 1, nil)
+	if owned[0].ProductID != 6 {
+		t.Fatalf("owner filter not applied: %+v", owned[0])
+	}
+}
+
+func TestWatchProductsWakesOnNotify(t *testing.T) {
+	events := &fakeEventRepo{head: model.ProductEventCursor{TxID: 20}}
+	events.add(&model.ProductEvent{ID: 1, TxID: 19, Type: model.ProductCreated, ProductID: 5})
+	feed := service.NewProductFeed()
+	// A long poll interval proves the wake-up comes from Notify
+	watcher := service.NewProductWatcher(events, newBatchRepo(), feed, service.WatchConfig{PollInterval: time.Hour})
+
+	got := watchUntil(t, watcher, &model.WatchProductsRequest{}, 1, func() {
+		time.Sleep(50 * time.Millisecond)
+		events.add(&model.ProductEvent{ID: 2, TxID: 20, Type: model.ProductDeleted, ProductID: 5})
+		feed.Notify()
+	})
+	if got[0].ID != 2 {
+		t.Fatalf("expected only the new event, got %d", got[0].ID)
+	}
+}
+
+func TestWatchProductsRereadsHeldBackEvents(t *testing.T) {
+	// Event 2 has committed, but a transaction older than it is still
+	// running and will end without writing products, so no Notify follows
+	events := &fakeEventRepo{head: model.ProductEventCursor{TxID: 20}, xmin: 21}
+	events.add(&model.ProductEvent{ID: 2, TxID: 22, Type: model.ProductUpdated, ProductID: 5})
+	watcher := service.NewProductWatcher(events, newBatchRepo(), service.NewProductFeed(),
+		service.WatchConfig{PollInterval: time.Hour, HeldBackInterval: 10 * time.Millisecond})
+
+	got := watchUntil(t, watcher, &model.WatchProductsRequest{}, 1, func() {
+		time.Sleep(50 * time.Millisecond)
+		events.setXmin(0)
+	})
+	if got[0].ID != 2 {
+		t.Fatalf("expected the held-back event, got %d", got[0].ID)
+	}
+}
+
+func TestWatchProductsRejectsBadTokens(t *testing.T) {
+	ctx := context.Background()
+	events := &fakeEventRepo{}
+	watcher := service.NewProductWatcher(events, newBatchRepo(), service.NewProductFeed(), service.WatchConfig{})
+	send := func(*model.ProductEvent) error { return nil }
+
+	err := watcher.WatchProducts(ctx, &model.WatchProductsRequest{ResumeToken: "a.7"}, send)
+	if !errors.Is(err, service.ErrResumeTokenExpired) {
+		t.Fatalf("expected an expired token, got %v", err)
+	}
+
+	err = watcher.WatchProducts(ctx, &model.WatchProductsRequest{ResumeToken: "garbage"}, send)
+	if appErr, ok := apperror.As(err); !ok || appErr.Violations[0].Field != "resume_token" {
+		t.Fatalf("expected a resume_token violation, got %v", err)
+	}
+}
+
+func TestProductEventRepositoryListAfter(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewProductEventRepository(db)
+
+	var query string
+	var args []driver.NamedValue
+	stub.QueryFunc = func(q string, a []driver.NamedValue) ([][]driver.Value, error) {
+		query, args = q, a
+		return [][]driver.Value{
+			{int64(8), int64(40), "updated", int64(5), int64(2), time.Now(), true},
+			{int64(6), int64(41), "updated", int64(5), int64(2), time.Now(), false},
+			{int64(9), int64(42), "updated", int64(5), int64(2), time.Now(), false},
+		}, nil
+	}
+
+	filter := &model.ProductEventFilter{UserID: 2, ProductIDs: []int64{5}}
+	events, heldBack, err := repo.ListAfter(ctx, model.ProductEventCursor{TxID: 39, ID: 7}, filter, 100)
+	if err != nil {
+		t.Fatalf("ListAfter error: %v", err)
+	}
+	// Events from the snapshot's xmin on are not final yet
+	if len(events) != 1 || events[0].Position() != (model.ProductEventCursor{TxID: 40, ID: 8}) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if !heldBack {
+		t.Fatalf("expected events past xmin to be reported as held back")
+	}
+	for _, want := range []string{
+		"(txid, id) > ($1, $2)",
+		"txid < txid_snapshot_xmin(txid_current_snapshot())",
+		"user_id = $3",
+		"product_id = ANY($4)",
+		"ORDER BY txid, id",
+		"LIMIT $5",
+	} {
+		if !strings.Contains(query, want) {
+			t.Fatalf("query missing %q: %s", want, query)
+		}
+	}
+	if args[0].Value != int64(39) || args[1].Value != int64(7) {
+		t.Fatalf("unexpected cursor args: %v", args)
+	}
+}
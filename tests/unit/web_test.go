@@ -0,0 +1,254 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-exmpl/api/web"
+	"grpc-exmpl/internal/config"
+	handler "grpc-exmpl/internal/handler/grpc"
+	"grpc-exmpl/internal/middleware"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/service"
+	pbproduct "grpc-exmpl/proto/product"
+)
+
+// newWebFixture serves the product service over gRPC-Web and Connect
+// behind the real auth interceptors
+func newWebFixture(t *testing.T, repo *batchProductRepo) (*httptest.Server, string) {
+	t.Helper()
+	auth, _, token := newTestAuth(t)
+	productSvc := service.NewProductService(passthroughTx{}, repo, nil, &fakeOutbox{}, service.InventoryConfig{}, service.BatchConfig{ImportAckEvery: 2})
+
+	h := web.New(auth.UnaryInterceptor, auth.StreamInterceptor)
+	pbproduct.RegisterProductServiceServer(h, handler.NewProductHandler(productSvc, nil))
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv, token
+}
+
+func doWeb(t *testing.T, url, contentType, token string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest error: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s error: %v", url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, data
+}
+
+func envelope(flags byte, data string) []byte {
+	out := make([]byte, 5, 5+len(data))
+	out[0] = flags
+	binary.BigEndian.PutUint32(out[1:], uint32(len(data)))
+	return append(out, data...)
+}
+
+type webFrame struct {
+	flags byte
+	data  string
+}
+
+func readFrames(t *testing.T, body []byte) []webFrame {
+	t.Helper()
+	var frames []webFrame
+	for len(body) > 0 {
+		if len(body) < 5 {
+			t.Fatalf("truncated envelope: %q", body)
+		}
+		n := int(binary.BigEndian.Uint32(body[1:5]))
+		frames = append(frames, webFrame{flags: body[0], data: string(body[5 : 5+n])})
+		body = body[5+n:]
+	}
+	return frames
+}
+
+func stockedRepo() *batchProductRepo {
+	repo := newBatchRepo()
+	repo.stored = &model.Product{ID: 1, Name: "Lamp", UserID: 1, Price: model.Money{Amount: 1250, Currency: "USD"}, Stock: 3}
+	return repo
+}
+
+func TestConnectUnaryForwardsAuthAndMapsErrors(t *testing.T) {
+	srv, token := newWebFixture(t, stockedRepo())
+	url := srv.URL + "/product.ProductService/GetProduct"
+
+	resp, body := doWeb(t, url, "application/json", "", []byte(`{"id":"1"}`))
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(string(body), `"code":"unauthenticated"`) {
+		t.Fatalf("expected 401 unauthenticated, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, body = doWeb(t, url, "application/json", token, []byte(`{"id":"1"}`))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected 200 JSON, got %d %s", resp.StatusCode, body)
+	}
+	var out struct {
+		Product struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"product"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.Product.ID != "1" || out.Product.Name != "Lamp" {
+		t.Fatalf("unexpected response %s (%v)", body, err)
+	}
+
+	resp, body = doWeb(t, url, "application/json", token, []byte(`{"id":"x"}`))
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), `"code":"invalid_argument"`) {
+		t.Fatalf("expected 400 invalid_argument, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, body = doWeb(t, srv.URL+"/product.ProductService/Missing", "application/json", token, []byte(`{}`))
+	if resp.StatusCode != http.StatusNotImplemented || !strings.Contains(string(body), `"code":"unimplemented"`) {
+		t.Fatalf("expected 501 unimplemented, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, _ = doWeb(t, url, "text/plain", token, []byte(`{}`))
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", resp.StatusCode)
+	}
+}
+
+func TestGRPCWebUnaryReportsStatusInTrailerFrame(t *testing.T) {
+	srv, token := newWebFixture(t, stockedRepo())
+	url := srv.URL + "/product.ProductService/GetProduct"
+
+	resp, body := doWeb(t, url, "application/grpc-web+json", token, envelope(0, `{"id":"1"}`))
+	frames := readFrames(t, body)
+	if resp.StatusCode != http.StatusOK || len(frames) != 2 {
+		t.Fatalf("expected a message and a trailer frame, got %d %q", resp.StatusCode, body)
+	}
+	if frames[0].flags != 0 || !strings.Contains(frames[0].data, `"name":"Lamp"`) {
+		t.Fatalf("unexpected message frame %+v", frames[0])
+	}
+	if frames[1].flags != 0x80 || !strings.Contains(frames[1].data, "grpc-status: 0\r\n") {
+		t.Fatalf("unexpected trailer frame %+v", frames[1])
+	}
+
+	// gRPC-Web text mode base64-encodes both directions
+	resp, body = doWeb(t, url, "application/grpc-web-text+json", "",
+		[]byte(base64.StdEncoding.EncodeToString(envelope(0, `{"id":"1"}`))))
+	decoded, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		t.Fatalf("response is not base64: %q", body)
+	}
+	frames = readFrames(t, decoded)
+	if resp.StatusCode != http.StatusOK || len(frames) != 1 || frames[0].flags != 0x80 {
+		t.Fatalf("expected only a trailer frame, got %d %+v", resp.StatusCode, frames)
+	}
+	if !strings.Contains(frames[0].data, "grpc-status: 16\r\n") || !strings.Contains(frames[0].data, "grpc-message: ") {
+		t.Fatalf("expected Unauthenticated trailers, got %q", frames[0].data)
+	}
+}
+
+func TestConnectStreamingRunsStreamInterceptors(t *testing.T) {
+	repo := stockedRepo()
+	srv, token := newWebFixture(t, repo)
+	url := srv.URL + "/product.ProductService/ImportProducts"
+
+	var body []byte
+	for _, name := range []string{"a", "b", "c"} {
+		body = append(body, envelope(0, `{"name":"`+name+`","price":{"currencyCode":"USD","units":"1"}}`)...)
+	}
+
+	resp, out := doWeb(t, url, "application/connect+json", token, body)
+	frames := readFrames(t, out)
+	if resp.StatusCode != http.StatusOK || len(frames) != 3 {
+		t.Fatalf("expected two acks and an end frame, got %d %q", resp.StatusCode, out)
+	}
+	if !strings.Contains(frames[0].data, `"received":"2"`) || !strings.Contains(frames[1].data, `"done":true`) {
+		t.Fatalf("unexpected acks %+v", frames[:2])
+	}
+	if frames[2].flags != 0x02 || frames[2].data != "{}" {
+		t.Fatalf("expected a clean end-stream frame, got %+v", frames[2])
+	}
+	if len(repo.products) != 3 {
+		t.Fatalf("expected 3 imported products, got %d", len(repo.products))
+	}
+
+	// The stream auth interceptor rejects anonymous streams
+	_, out = doWeb(t, url, "application/connect+json", "", body)
+	frames = readFrames(t, out)
+	if len(frames) != 1 || frames[0].flags != 0x02 || !strings.Contains(frames[0].data, `"code":"unauthenticated"`) {
+		t.Fatalf("expected an unauthenticated end-stream frame, got %+v", frames)
+	}
+
+	// Unary content types cannot call streaming methods
+	resp, _ = doWeb(t, url, "application/json", token, []byte(`{}`))
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d", resp.StatusCode)
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	cors := middleware.NewCORSMiddleware(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedHeaders: []string{"X-Request-Id"},
+		MaxAge:         time.Hour,
+	})
+	reached := false
+	h := cors.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	serve := func(method, origin string) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(method, "/product.ProductService/GetProduct", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodOptions, "https://app.example.com")
+	if rec.Code != http.StatusNoContent || reached {
+		t.Fatalf("expected preflight to be answered, got %d (reached=%v)", rec.Code, reached)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Connect-Protocol-Version") ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "X-Request-Id") ||
+		rec.Header().Get("Access-Control-Max-Age") != "3600" {
+		t.Fatalf("unexpected preflight headers %v", rec.Header())
+	}
+
+	if rec := serve(http.MethodOptions, "https://evil.example.com"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected preflight from unknown origin to be refused, got %d", rec.Code)
+	}
+
+	rec = serve(http.MethodPost, "https://app.example.com")
+	if !reached || !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "Grpc-Status") {
+		t.Fatalf("expected request through with exposed headers, got %v", rec.Header())
+	}
+
+	rec = serve(http.MethodPost, "https://evil.example.com")
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("unknown origin must get no CORS headers, got %v", rec.Header())
+	}
+
+	rec = serve(http.MethodPost, "")
+	if !reached || rec.Header().Get("Vary") != "" {
+		t.Fatalf("same-origin request must pass untouched, got %v", rec.Header())
+	}
+}
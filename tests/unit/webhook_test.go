@@ -0,0 +1,408 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-exmpl/internal/apperror"
+	"grpc-exmpl/internal/model"
+	"grpc-exmpl/internal/repository"
+	"grpc-exmpl/internal/service"
+	"grpc-exmpl/internal/worker"
+	"grpc-exmpl/pkg/utils"
+	"grpc-exmpl/tests/testdb"
+)
+
+type fakeWebhookRepo struct {
+	webhooks map[int64]*model.Webhook
+}
+
+func (f *fakeWebhookRepo) Create(ctx context.Context, w *model.Webhook) error {
+	w.ID = int64(len(f.webhooks) + 1)
+	f.webhooks[w.ID] = w
+	return nil
+}
+func (f *fakeWebhookRepo) GetByID(ctx context.Context, id int64) (*model.Webhook, error) {
+	w, ok := f.webhooks[id]
+	if !ok {
+		return nil, apperror.NotFound("webhook")
+	}
+	return w, nil
+}
+func (f *fakeWebhookRepo) ListByUserID(ctx context.Context, userID int64) ([]*model.Webhook, error) {
+	var out []*model.Webhook
+	for id := int64(1); id <= int64(len(f.webhooks)); id++ {
+		if w, ok := f.webhooks[id]; ok && w.UserID == userID {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+func (f *fakeWebhookRepo) Delete(ctx context.Context, id int64) error {
+	delete(f.webhooks, id)
+	return nil
+}
+func (f *fakeWebhookRepo) ListMatching(ctx context.Context, userID int64, eventType string, productID int64) ([]*model.Webhook, error) {
+	all, _ := f.ListByUserID(ctx, userID)
+	var out []*model.Webhook
+	for _, w := range all {
+		if (len(w.EventTypes) == 0 || slices.Contains(w.EventTypes, eventType)) &&
+			(len(w.ProductIDs) == 0 || slices.Contains(w.ProductIDs, productID)) {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+// fakeDeliveryRepo keeps deliveries in memory; Claim hands out every
+// pending one regardless of next_attempt_at
+type fakeDeliveryRepo struct {
+	mu         sync.Mutex
+	deliveries map[int64]*model.WebhookDelivery
+	attempts   []*model.WebhookAttempt
+	webhooks   *fakeWebhookRepo
+}
+
+func newFakeDeliveryRepo(webhooks *fakeWebhookRepo) *fakeDeliveryRepo {
+	return &fakeDeliveryRepo{deliveries: map[int64]*model.WebhookDelivery{}, webhooks: webhooks}
+}
+
+func (f *fakeDeliveryRepo) Enqueue(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	for _, d := range deliveries {
+		d.ID = int64(len(f.deliveries) + 1)
+		d.Status = model.DeliveryPending
+		f.deliveries[d.ID] = d
+	}
+	return nil
+}
+func (f *fakeDeliveryRepo) GetByID(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	d, ok := f.deliveries[id]
+	if !ok {
+		return nil, apperror.NotFound("webhook delivery")
+	}
+	return d, nil
+}
+func (f *fakeDeliveryRepo) ListByWebhookID(ctx context.Context, req *model.ListDeliveriesRequest) (*model.DeliveryPage, error) {
+	return &model.DeliveryPage{}, nil
+}
+func (f *fakeDeliveryRepo) ListAttempts(ctx context.Context, deliveryID int64) ([]*model.WebhookAttempt, error) {
+	var out []*model.WebhookAttempt
+	for _, a := range f.attempts {
+		if a.DeliveryID == deliveryID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+func (f *fakeDeliveryRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	var out []*model.WebhookDelivery
+	for id := int64(1); id <= int64(len(f.deliveries)); id++ {
+		d := f.deliveries[id]
+		if d.Status != model.DeliveryPending {
+			continue
+		}
+		d.Attempts++
+		d.Webhook = f.webhooks.webhooks[d.WebhookID]
+		out = append(out, d)
+	}
+	return out, nil
+}
+func (f *fakeDeliveryRepo) RecordAttempt(ctx context.Context, d *model.WebhookDelivery, a *model.WebhookAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a.DeliveryID = d.ID
+	f.attempts = append(f.attempts, a)
+	d.LastStatusCode, d.LastError = a.StatusCode, a.Error
+	return nil
+}
+func (f *fakeDeliveryRepo) Replay(ctx context.Context, id int64) error {
+	d := f.deliveries[id]
+	d.Status, d.Attempts, d.DeliveredAt = model.DeliveryPending, 0, nil
+	return nil
+}
+
+func newWebhookFixture() (*fakeWebhookRepo, *fakeDeliveryRepo, *fakeProductRepo) {
+	webhooks := &fakeWebhookRepo{webhooks: map[int64]*model.Webhook{}}
+	products := &fakeProductRepo{stored: &model.Product{ID: 7, Name: "lamp", UserID: 2}}
+	return webhooks, newFakeDeliveryRepo(webhooks), products
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	ctx := context.Background()
+	webhooks, deliveries, products := newWebhookFixture()
+	svc := service.NewWebhookService(webhooks, deliveries, products, service.WebhookConfig{})
+	caller := model.Caller{UserID: 2}
+
+	_, err := svc.CreateWebhook(ctx, caller, &model.CreateWebhookRequest{
+		URL:        "ftp://example.com/hook",
+		Secret:     "short",
+		EventTypes: []string{model.EventStockChanged, model.EventUserRegistered},
+	})
+	appErr, ok := apperror.As(err)
+	if !ok || len(appErr.Violations) != 3 {
+		t.Fatalf("expected url, secret and event_types[1] violations, got %v", err)
+	}
+
+	w, err := svc.CreateWebhook(ctx, caller, &model.CreateWebhookRequest{
+		URL:        "https://example.com/hook",
+		EventTypes: []string{model.EventStockChanged, " StockChanged"},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook error: %v", err)
+	}
+	if !strings.HasPrefix(w.Secret, "whsec_") || len(w.EventTypes) != 1 || w.UserID != 2 {
+		t.Fatalf("unexpected webhook: %+v", w)
+	}
+
+	worker := model.Caller{Service: "orders-worker", Roles: []string{model.RoleAdmin}}
+	if _, err := svc.CreateWebhook(ctx, worker, &model.CreateWebhookRequest{URL: "https://example.com/hook"}); !errors.Is(err, service.ErrUserRequired) {
+		t.Fatalf("expected ErrUserRequired for a service caller, got %v", err)
+	}
+}
+
+func TestWebhookPublishQueuesMatchingDeliveries(t *testing.T) {
+	ctx := context.Background()
+	webhooks, deliveries, products := newWebhookFixture()
+	svc := service.NewWebhookService(webhooks, deliveries, products, service.WebhookConfig{})
+
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: "http://a", EventTypes: []string{model.EventStockChanged}})
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: "http://b", ProductIDs: []int64{8}})
+	webhooks.Create(ctx, &model.Webhook{UserID: 3, URL: "http://c"})
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: "http://d"})
+
+	event := &model.OutboxEvent{ID: 40, Type: model.EventStockChanged, AggregateType: model.AggregateProduct, AggregateID: 7, Payload: json.RawMessage(`{"stock":1}`)}
+	if err := svc.Publish(ctx, event); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	userEvent := &model.OutboxEvent{ID: 41, Type: model.EventUserRegistered, AggregateType: model.AggregateUser, AggregateID: 2}
+	if err := svc.Publish(ctx, userEvent); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+
+	var targets []int64
+	for _, d := range deliveries.deliveries {
+		targets = append(targets, d.WebhookID)
+		var sent model.OutboxEvent
+		if err := json.Unmarshal(d.Payload, &sent); err != nil || sent.ID != 40 {
+			t.Fatalf("delivery payload is not the event: %s", d.Payload)
+		}
+	}
+	slices.Sort(targets)
+	if !slices.Equal(targets, []int64{1, 4}) {
+		t.Fatalf("expected deliveries to webhooks 1 and 4, got %v", targets)
+	}
+}
+
+func TestCreateWebhookRejectsInternalAddresses(t *testing.T) {
+	ctx := context.Background()
+	webhooks, deliveries, products := newWebhookFixture()
+	svc := service.NewWebhookService(webhooks, deliveries, products, service.WebhookConfig{})
+	caller := model.Caller{UserID: 2}
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"https://10.1.2.3/hook",
+		"http://192.168.0.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := svc.CreateWebhook(ctx, caller, &model.CreateWebhookRequest{URL: url})
+		if appErr, ok := apperror.As(err); !ok || len(appErr.Violations) != 1 || appErr.Violations[0].Field != "url" {
+			t.Fatalf("expected a url violation for %s, got %v", url, err)
+		}
+	}
+
+	dev := service.NewWebhookService(webhooks, deliveries, products, service.WebhookConfig{AllowPrivateNetworks: true})
+	if _, err := dev.CreateWebhook(ctx, caller, &model.CreateWebhookRequest{URL: "http://127.0.0.1:8080/hook"}); err != nil {
+		t.Fatalf("expected loopback to be allowed for development, got %v", err)
+	}
+}
+
+func TestWebhookDelivererRefusesInternalAddressesAndRedirects(t *testing.T) {
+	ctx := context.Background()
+	webhooks, deliveries, _ := newWebhookFixture()
+
+	var mu sync.Mutex
+	var targetCalls int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		targetCalls++
+	}))
+	defer target.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirector.Close()
+
+	// A webhook registered before the check, or one whose name was rebound
+	// to an internal address, is still refused when connecting
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: target.URL, Secret: "whsec_0123456789abcdef"})
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: redirector.URL, Secret: "whsec_0123456789abcdef"})
+	deliveries.Enqueue(ctx, &model.WebhookDelivery{WebhookID: 1, EventID: 40, EventType: model.EventStockChanged, Payload: json.RawMessage(`{}`)})
+	cfg := worker.WebhookDelivererConfig{BatchSize: 10, Timeout: time.Second, MinBackoff: time.Minute, MaxBackoff: time.Hour, MaxAttempts: 5}
+
+	worker.NewWebhookDeliverer(deliveries, cfg).Deliver(ctx)
+	if d := deliveries.deliveries[1]; d.Status != model.DeliveryPending || !strings.Contains(d.LastError, "non-public address") {
+		t.Fatalf("expected the loopback delivery to be refused, got %+v", d)
+	}
+
+	// Redirects are not followed even where private addresses are allowed
+	deliveries.deliveries[1].Status = model.DeliveryDead
+	deliveries.Enqueue(ctx, &model.WebhookDelivery{WebhookID: 2, EventID: 41, EventType: model.EventStockChanged, Payload: json.RawMessage(`{}`)})
+	cfg.AllowPrivateNetworks = true
+	worker.NewWebhookDeliverer(deliveries, cfg).Deliver(ctx)
+	if d := deliveries.deliveries[2]; d.Status != model.DeliveryPending || d.LastStatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("expected the redirect to count as a failure, got %+v", d)
+	}
+	if targetCalls != 0 {
+		t.Fatalf("expected no request to reach the target, got %d", targetCalls)
+	}
+}
+
+func TestWebhookDelivererSignsRetriesAndDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	webhooks, deliveries, _ := newWebhookFixture()
+
+	const secret = "whsec_0123456789abcdef"
+	var mu sync.Mutex
+	var calls int
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if err := utils.VerifyWebhookSignature(secret, r.Header.Get("X-Webhook-Signature"), body, time.Minute); err != nil {
+			verifyErr = err
+		}
+		if r.Header.Get("X-Event-ID") != "40" || r.Header.Get("X-Event-Type") != model.EventStockChanged {
+			verifyErr = errors.New("missing event headers")
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: srv.URL, Secret: secret})
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: "http://127.0.0.1:1", Secret: secret})
+	deliveries.Enqueue(ctx,
+		&model.WebhookDelivery{WebhookID: 1, EventID: 40, EventType: model.EventStockChanged, Payload: json.RawMessage(`{"id":40}`)},
+		&model.WebhookDelivery{WebhookID: 2, EventID: 40, EventType: model.EventStockChanged, Payload: json.RawMessage(`{"id":40}`)},
+	)
+
+	deliverer := worker.NewWebhookDeliverer(deliveries, worker.WebhookDelivererConfig{
+		BatchSize:   10,
+		Timeout:     time.Second,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  time.Hour,
+		MaxAttempts: 2,
+		// The test server listens on loopback
+		AllowPrivateNetworks: true,
+	})
+
+	start := time.Now()
+	deliverer.Deliver(ctx)
+	first := deliveries.deliveries[1]
+	if first.Status != model.DeliveryPending || first.LastStatusCode != http.StatusInternalServerError || first.NextAttemptAt.Before(start.Add(time.Minute)) {
+		t.Fatalf("expected a retry a minute out after the 500, got %+v", first)
+	}
+
+	deliverer.Deliver(ctx)
+	if verifyErr != nil {
+		t.Fatalf("receiver rejected the request: %v", verifyErr)
+	}
+	if first.Status != model.DeliveryDelivered || first.DeliveredAt == nil {
+		t.Fatalf("expected delivery 1 delivered on the second attempt, got %+v", first)
+	}
+	if dead := deliveries.deliveries[2]; dead.Status != model.DeliveryDead || dead.LastError == "" {
+		t.Fatalf("expected delivery 2 dead after two connection failures, got %+v", dead)
+	}
+
+	log, _ := deliveries.ListAttempts(ctx, 1)
+	if len(log) != 2 || log[0].Attempt != 1 || log[0].StatusCode != 500 || log[1].StatusCode != 200 {
+		t.Fatalf("unexpected attempt log: %+v", log)
+	}
+}
+
+func TestReplayDelivery(t *testing.T) {
+	ctx := context.Background()
+	webhooks, deliveries, products := newWebhookFixture()
+	svc := service.NewWebhookService(webhooks, deliveries, products, service.WebhookConfig{})
+
+	webhooks.Create(ctx, &model.Webhook{UserID: 2, URL: "http://a"})
+	deliveries.Enqueue(ctx, &model.WebhookDelivery{WebhookID: 1, EventID: 40})
+
+	owner := model.Caller{UserID: 2}
+	if _, err := svc.ReplayDelivery(ctx, owner, 1); !errors.Is(err, service.ErrDeliveryPending) {
+		t.Fatalf("expected a pending delivery to be rejected, got %v", err)
+	}
+
+	deliveries.deliveries[1].Status = model.DeliveryDead
+	deliveries.deliveries[1].Attempts = 10
+	if _, err := svc.ReplayDelivery(ctx, model.Caller{UserID: 3}, 1); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Fatalf("expected permission denied for another seller, got %v", err)
+	}
+
+	d, err := svc.ReplayDelivery(ctx, owner, 1)
+	if err != nil {
+		t.Fatalf("ReplayDelivery error: %v", err)
+	}
+	if d.Status != model.DeliveryPending || d.Attempts != 0 {
+		t.Fatalf("expected a fresh pending delivery, got %+v", d)
+	}
+}
+
+func TestVerifyWebhookSignatureRejectsTampering(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	header := utils.SignWebhook("secret-secret-secret", now, body)
+
+	if err := utils.VerifyWebhookSignature("secret-secret-secret", header, body, time.Minute); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := utils.VerifyWebhookSignature("secret-secret-secret", header, []byte(`{"id":2}`), time.Minute); err == nil {
+		t.Fatal("tampered body accepted")
+	}
+	old := utils.SignWebhook("secret-secret-secret", now.Add(-time.Hour), body)
+	if err := utils.VerifyWebhookSignature("secret-secret-secret", old, body, time.Minute); err == nil {
+		t.Fatal("stale signature accepted")
+	}
+}
+
+func TestWebhookRepositoryListMatchingFilters(t *testing.T) {
+	ctx := context.Background()
+	db, stub, err := testdb.New()
+	if err != nil {
+		t.Fatalf("failed to create stub db: %v", err)
+	}
+	repo := repository.NewWebhookRepository(db)
+
+	var query string
+	var args []driver.NamedValue
+	stub.QueryFunc = func(q string, a []driver.NamedValue) ([][]driver.Value, error) {
+		query, args = q, a
+		return nil, nil
+	}
+
+	if _, err := repo.ListMatching(ctx, 2, model.EventStockChanged, 7); err != nil {
+		t.Fatalf("ListMatching error: %v", err)
+	}
+	if !strings.Contains(query, "$2 = ANY(event_types)") || !strings.Contains(query, "cardinality(product_ids) = 0") {
+		t.Fatalf("filters not applied in SQL: %s", query)
+	}
+	if args[0].Value != int64(2) || args[1].Value != model.EventStockChanged || args[2].Value != int64(7) {
+		t.Fatalf("unexpected args: %v", args)
+	}
+}